@@ -0,0 +1,160 @@
+// Package apierror defines the errors handlers return to API clients. Every
+// failure is rendered by Handler as one JSON envelope:
+//
+//	{"code": "room.not_found", "message": "Room not found", "details": {}, "request_id": "...", "trace_id": "..."}
+//
+// trace_id is present only when tracing is enabled.
+//
+// Codes are stable and meant for programs; messages are for people and may change.
+package apierror
+
+import (
+	"maps"
+	"net/http"
+)
+
+// Error is an API failure with an HTTP status and a machine-readable code.
+// Handlers return it instead of writing a response; the shared values below
+// are templates, so customise them with WithMessage and WithDetails.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details map[string]any
+}
+
+// New returns an error with the given status, code and message.
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithMessage returns a copy of e with a different human-readable message.
+func (e *Error) WithMessage(message string) *Error {
+	out := *e
+	out.Message = message
+	return &out
+}
+
+// WithDetails returns a copy of e with details merged into its details.
+func (e *Error) WithDetails(details map[string]any) *Error {
+	out := *e
+	out.Details = maps.Clone(e.Details)
+	if out.Details == nil {
+		out.Details = map[string]any{}
+	}
+	maps.Copy(out.Details, details)
+	return &out
+}
+
+// Internal reports an unexpected server-side failure. The message should say
+// what failed without leaking internals.
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Validation reports a request that was well-formed but had invalid values.
+func Validation(message string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, message)
+}
+
+// Error codes. They are part of the API contract: add new ones freely, but
+// never change or reuse an existing code.
+const (
+	// Generic request problems
+	CodeInvalidBody      = "request.invalid_body"
+	CodeInvalidParameter = "request.invalid_parameter"
+	CodeValidationFailed = "validation.failed"
+	CodeRouteNotFound    = "route.not_found"
+	CodeMethodNotAllowed = "request.method_not_allowed"
+	CodeBodyTooLarge     = "request.body_too_large"
+	CodeRequestFailed    = "request.failed"
+	CodeRateLimited      = "rate_limit.exceeded"
+	CodeInternal         = "internal.error"
+
+	// Authentication and authorization
+	CodeMissingToken          = "auth.missing_token"
+	CodeInvalidToken          = "auth.invalid_token"
+	CodeTokenExpired          = "auth.token_expired"
+	CodeSessionRevoked        = "auth.session_revoked"
+	CodeUnauthenticated       = "auth.unauthenticated"
+	CodeInvalidCredentials    = "auth.invalid_credentials"
+	CodeAccountLocked         = "auth.account_locked"
+	CodeInvalidRefreshToken   = "auth.invalid_refresh_token"
+	CodeRefreshTokenReused    = "auth.refresh_token_reused"
+	CodeEmailNotVerified      = "auth.email_not_verified"
+	CodeForbidden             = "auth.forbidden"
+	CodeMissingScope          = "auth.missing_scope"
+	CodeSessionRequired       = "auth.session_required"
+	CodeSignupDenied          = "auth.signup_denied"
+	CodeInvalidResetToken     = "auth.invalid_reset_token"
+	CodeInvalidVerifyToken    = "auth.invalid_verification_token"
+	CodeInvalidChallenge      = "auth.invalid_challenge"
+	CodeInvalidTwoFactorCode  = "auth.invalid_two_factor_code"
+	CodeTwoFactorNotEnabled   = "auth.two_factor_not_enabled"
+	CodeTwoFactorEnabled      = "auth.two_factor_already_enabled"
+	CodeNoPendingEnrollment   = "auth.no_pending_enrollment"
+	CodePasswordRequired      = "auth.password_required"
+	CodeInvalidOAuthState     = "auth.invalid_oauth_state"
+	CodeInvalidLinkToken      = "auth.invalid_link_token"
+	CodeAccessDenied          = "auth.access_denied"
+	CodeDeviceCodeExpired     = "auth.device_code_expired"
+	CodeAccountConflict       = "auth.account_conflict"
+	CodeProviderError         = "auth.provider_error"
+	CodeProviderNotConfigured = "identity.provider_not_configured"
+	CodeIdentityNotLinked     = "identity.not_linked"
+	CodeIdentityLinked        = "identity.already_linked"
+	CodeLastSignInMethod      = "identity.last_sign_in_method"
+
+	// Resources
+	CodeUserNotFound     = "user.not_found"
+	CodeUserExists       = "user.already_exists"
+	CodeUsernameTaken    = "user.username_taken"
+	CodeOwnRole          = "user.cannot_change_own_role"
+	CodeRoomNotFound     = "room.not_found"
+	CodeMessageNotFound  = "message.not_found"
+	CodeMessageForbidden = "message.forbidden"
+	CodeMessageThrottled = "message.throttled"
+	CodeSessionNotFound  = "session.not_found"
+	CodeTokenNotFound    = "token.not_found"
+	CodeBotNotFound      = "bot.not_found"
+	CodeInviteNotFound   = "invite.not_found"
+)
+
+// Shared errors with their default messages.
+var (
+	InvalidBody = New(http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+
+	MissingToken       = New(http.StatusUnauthorized, CodeMissingToken, "Authorization header required")
+	InvalidToken       = New(http.StatusUnauthorized, CodeInvalidToken, "Invalid or expired token")
+	TokenExpired       = New(http.StatusUnauthorized, CodeTokenExpired, "Access token has expired")
+	SessionRevoked     = New(http.StatusUnauthorized, CodeSessionRevoked, "Session has been revoked")
+	Unauthenticated    = New(http.StatusUnauthorized, CodeUnauthenticated, "User not authenticated")
+	InvalidCredentials = New(http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials")
+	EmailNotVerified   = New(http.StatusForbidden, CodeEmailNotVerified, "Email address not verified")
+	Forbidden          = New(http.StatusForbidden, CodeForbidden, "Insufficient permissions")
+	RateLimited        = New(http.StatusTooManyRequests, CodeRateLimited, "Too many requests, please try again later")
+
+	InvalidTwoFactorCode = New(http.StatusUnauthorized, CodeInvalidTwoFactorCode, "Invalid two-factor code")
+	TwoFactorNotEnabled  = New(http.StatusBadRequest, CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled")
+
+	UserNotFound    = New(http.StatusNotFound, CodeUserNotFound, "User not found")
+	RoomNotFound    = New(http.StatusNotFound, CodeRoomNotFound, "Room not found")
+	MessageNotFound = New(http.StatusNotFound, CodeMessageNotFound, "Message not found")
+	SessionNotFound = New(http.StatusNotFound, CodeSessionNotFound, "Session not found")
+	TokenNotFound   = New(http.StatusNotFound, CodeTokenNotFound, "Token not found")
+	BotNotFound     = New(http.StatusNotFound, CodeBotNotFound, "Bot not found")
+	InviteNotFound  = New(http.StatusNotFound, CodeInviteNotFound, "Invite not found")
+
+	ProviderNotConfigured = New(http.StatusNotFound, CodeProviderNotConfigured, "Identity provider not configured")
+	InvalidLinkToken      = New(http.StatusUnauthorized, CodeInvalidLinkToken, "invalid or expired link token")
+)
+
+// InvalidParameter reports a malformed path or query parameter, such as a
+// non-numeric ID.
+func InvalidParameter(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidParameter, message)
+}
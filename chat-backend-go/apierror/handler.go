@@ -0,0 +1,75 @@
+package apierror
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Envelope is the JSON body of every error response.
+type Envelope struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details"`
+	RequestID string         `json:"request_id"`
+	TraceID   string         `json:"trace_id,omitempty"`
+}
+
+// Handler is the app's fiber.Config.ErrorHandler. It renders *Error values
+// as-is, maps Fiber's own errors (unknown route, body too large, ...) to codes,
+// and hides anything else behind a logged internal error.
+func Handler(c *fiber.Ctx, err error) error {
+	apiErr := FromError(err)
+	if known := (*Error)(nil); apiErr.Code == CodeInternal && !errors.As(err, &known) {
+		slog.ErrorContext(c.UserContext(), "Unhandled error", "method", c.Method(), "path", c.Path(), "error", err)
+	}
+	return Write(c, apiErr)
+}
+
+// FromError converts any error returned by a handler into an *Error.
+func FromError(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		switch fiberErr.Code {
+		case http.StatusNotFound:
+			return New(fiberErr.Code, CodeRouteNotFound, "No such endpoint")
+		case http.StatusMethodNotAllowed:
+			return New(fiberErr.Code, CodeMethodNotAllowed, "Method not allowed")
+		case http.StatusRequestEntityTooLarge:
+			return New(fiberErr.Code, CodeBodyTooLarge, "Request body is too large")
+		case http.StatusUnprocessableEntity, http.StatusBadRequest:
+			return InvalidBody
+		}
+		if fiberErr.Code < 500 {
+			return New(fiberErr.Code, CodeRequestFailed, fiberErr.Message)
+		}
+	}
+	return Internal("Internal server error")
+}
+
+// Write sends e as the response, for the rare middleware that must respond
+// without returning an error (for example after setting headers).
+func Write(c *fiber.Ctx, e *Error) error {
+	details := e.Details
+	if details == nil {
+		details = map[string]any{}
+	}
+	envelope := Envelope{
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   details,
+		RequestID: c.GetRespHeader(fiber.HeaderXRequestID),
+	}
+	if sc := trace.SpanContextFromContext(c.UserContext()); sc.IsValid() {
+		envelope.TraceID = sc.TraceID().String()
+	}
+	return c.Status(e.Status).JSON(envelope)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"chat-backend-go/config"
+	"chat-backend-go/migrations"
+	"chat-backend-go/utils"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ensureSchema applies pending migrations when MIGRATE_ON_START allows it and
+// otherwise refuses to start against an out-of-date schema.
+func ensureSchema() {
+	pending, err := migrations.Pending(config.DB)
+	if err != nil {
+		fatal("Failed to check migrations", "error", err)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if !config.MigrateOnStart() {
+		fatal("Database has pending migrations; run `chat-backend-go migrate up` first", "pending", len(pending))
+	}
+	applied, err := migrations.Up(config.DB, 0)
+	if err != nil {
+		fatal("Failed to migrate database", "error", err)
+	}
+	for _, m := range applied {
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+	}
+}
+
+// runMigrate implements `migrate up|down|status|create`.
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	// create only writes files, so it doesn't need a database
+	if args[0] == "create" {
+		var dirs []string
+		for _, dialect := range migrations.Dialects {
+			dirs = append(dirs, filepath.Join("migrations", dialect))
+		}
+		paths, err := migrations.Create(strings.Join(args[1:], " "), dirs...)
+		if err != nil {
+			fatal("Failed to create migration", "error", err)
+		}
+		for _, p := range paths {
+			fmt.Println("Created", p)
+		}
+		return
+	}
+
+	config.ConnectDB()
+
+	steps := 0
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fatal("Invalid step count", "steps", args[1])
+		}
+		steps = n
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrations.Up(config.DB, steps)
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+	case "down":
+		reverted, err := migrations.Down(config.DB, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations")
+		}
+	case "status":
+		statuses, err := migrations.List(config.DB)
+		if err != nil {
+			fatal("Failed to read migrations", "error", err)
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runSeed implements `seed`: it creates the demo users and rooms. The demo
+// passwords are public, so production requires -force.
+func runSeed(args []string) {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	force := flags.Bool("force", false, "seed even when APP_ENV=production")
+	flags.Parse(args)
+
+	config.ConnectDB()
+	if config.IsProduction() && !*force {
+		fatal("Refusing to seed demo accounts with public passwords in production; pass -force to override")
+	}
+	if pending, err := migrations.Pending(config.DB); err != nil || len(pending) > 0 {
+		fatal("Database schema is not up to date; run `chat-backend-go migrate up` first")
+	}
+
+	utils.SeedDemoUsers()
+	utils.SeedDemoRooms()
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// EmailVerificationMode controls how accounts with unverified emails are treated.
+type EmailVerificationMode string
+
+const (
+	// EmailVerificationOff sends verification emails but never restricts access.
+	EmailVerificationOff EmailVerificationMode = "off"
+	// EmailVerificationRestrict lets unverified users sign in read-only.
+	EmailVerificationRestrict EmailVerificationMode = "restrict"
+	// EmailVerificationBlock refuses to issue tokens until the email is verified.
+	EmailVerificationBlock EmailVerificationMode = "block"
+)
+
+// GetEmailVerificationMode reads EMAIL_VERIFICATION (off, restrict or block; default off).
+func GetEmailVerificationMode() EmailVerificationMode {
+	switch EmailVerificationMode(strings.ToLower(os.Getenv("EMAIL_VERIFICATION"))) {
+	case EmailVerificationRestrict:
+		return EmailVerificationRestrict
+	case EmailVerificationBlock:
+		return EmailVerificationBlock
+	default:
+		return EmailVerificationOff
+	}
+}
+
+// IsProduction reports whether APP_ENV is "production". Production mode refuses
+// insecure defaults such as the built-in JWT secret.
+func IsProduction() bool {
+	return strings.EqualFold(os.Getenv("APP_ENV"), "production")
+}
@@ -1,26 +1,50 @@
 // Package config handles database connection, configuration, and setup.
-// This file manages PostgreSQL connection with optimized connection pooling,
-// environment variable loading, and automatic database migration.
+// This file manages the PostgreSQL connection with optimized connection pooling
+// (or a SQLite file for local development and tests) and environment variable
+// loading. The schema is managed by package migrations.
 package config
 
 import (
-	"chat-backend-go/models"
+	"chat-backend-go/logging"
+	"chat-backend-go/tracing"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// LoadEnv loads variables from a .env file in the working directory, without
+// overriding ones already set. It fails if there is no such file.
+func LoadEnv() error {
+	return godotenv.Load()
+}
+
 func ConnectDB() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found")
+	// DB_DRIVER=sqlite runs without a database server
+	switch driver := os.Getenv("DB_DRIVER"); driver {
+	case "", "postgres":
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "windgo.db"
+		}
+		var err error
+		if DB, err = OpenSQLite(path); err != nil {
+			slog.Error("Failed to open SQLite database", "path", path, "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Using SQLite database", "path", path)
+		return
+	default:
+		slog.Error("Unknown DB_DRIVER (use postgres or sqlite)", "driver", driver)
+		os.Exit(1)
 	}
 
 	// Get database URL from environment or use default
@@ -57,15 +81,17 @@ func ConnectDB() {
 	}
 
 	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err = openGorm(postgres.Open(dsn))
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		slog.Error("Failed to connect to database", "error", err)
+		os.Exit(1)
 	}
 
 	// Configure connection pool
 	sqlDB, err := DB.DB()
 	if err != nil {
-		log.Fatal("Failed to get underlying sql.DB:", err)
+		slog.Error("Failed to get underlying sql.DB", "error", err)
+		os.Exit(1)
 	}
 
 	// Set connection pool settings
@@ -74,16 +100,64 @@ func ConnectDB() {
 	sqlDB.SetConnMaxLifetime(time.Hour)        // Connection lifetime
 	sqlDB.SetConnMaxIdleTime(30 * time.Minute) // Maximum idle time
 
-	log.Println("Database connected successfully!")
-	log.Printf("Connection pool configured: MaxIdle=%d, MaxOpen=%d", 10, 100)
+	slog.Info("Database connected", "driver", "postgres", "max_idle", 10, "max_open", 100)
+}
+
+// OpenSQLite opens a SQLite database file, or a private in-memory database
+// for ":memory:". Foreign keys are enforced to match Postgres.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	dsn := ":memory:?_foreign_keys=on"
+	if path != ":memory:" {
+		dsn = "file:" + path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL"
+	}
 
-	// Auto migrate models
-	err = DB.AutoMigrate(&models.User{}, &models.Room{}, &models.Message{})
+	db, err := openGorm(sqlite.Open(dsn))
 	if err != nil {
-		log.Fatal("Failed to migrate database:", err)
+		return nil, err
+	}
+
+	// Every connection to ":memory:" is a separate database, so keep just one
+	if path == ":memory:" {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
 	}
+	return db, nil
+}
 
-	log.Println("Database migration completed!")
+// openGorm opens a database that logs through slog and traces its queries.
+func openGorm(dialector gorm.Dialector) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logging.Gorm()})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// MigrateOnStart reports whether the server applies pending migrations when it
+// starts. MIGRATE_ON_START overrides the default, which is on except in production.
+func MigrateOnStart() bool {
+	if value := os.Getenv("MIGRATE_ON_START"); value != "" {
+		return value == "true"
+	}
+	return !IsProduction()
+}
+
+// CloseDB closes the connection pool. Call it once nothing uses DB any more.
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
 func GetDB() *gorm.DB {
@@ -3,11 +3,39 @@ package config
 import (
     "errors"
     "os"
+    "strings"
 
     "golang.org/x/oauth2"
-    "golang.org/x/oauth2/github"
 )
 
+// GitHubEndpoints holds the GitHub URLs used for OAuth and the REST API.
+// They default to github.com and can be pointed at a local fake OAuth server
+// with GITHUB_OAUTH_BASE_URL and GITHUB_API_BASE_URL (useful for tests).
+type GitHubEndpoints struct {
+    AuthURL       string
+    TokenURL      string
+    DeviceAuthURL string
+    APIBaseURL    string
+}
+
+// GetGitHubEndpoints builds the GitHub endpoint URLs from the environment.
+func GetGitHubEndpoints() GitHubEndpoints {
+    oauthBase := strings.TrimRight(os.Getenv("GITHUB_OAUTH_BASE_URL"), "/")
+    if oauthBase == "" {
+        oauthBase = "https://github.com"
+    }
+    apiBase := strings.TrimRight(os.Getenv("GITHUB_API_BASE_URL"), "/")
+    if apiBase == "" {
+        apiBase = "https://api.github.com"
+    }
+    return GitHubEndpoints{
+        AuthURL:       oauthBase + "/login/oauth/authorize",
+        TokenURL:      oauthBase + "/login/oauth/access_token",
+        DeviceAuthURL: oauthBase + "/login/device/code",
+        APIBaseURL:    apiBase,
+    }
+}
+
 // GetGitHubOAuthConfig builds an oauth2.Config from environment variables.
 // Requires GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET, and GITHUB_REDIRECT_URL.
 func GetGitHubOAuthConfig() (*oauth2.Config, error) {
@@ -19,13 +47,23 @@ func GetGitHubOAuthConfig() (*oauth2.Config, error) {
         return nil, errors.New("GitHub OAuth not configured: set GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET, GITHUB_REDIRECT_URL")
     }
 
+    scopes := []string{"read:user", "user:email"}
+    if GitHubMembershipRequired() {
+        // Needed to see private organization and team memberships
+        scopes = append(scopes, "read:org")
+    }
+
+    endpoints := GetGitHubEndpoints()
     cfg := &oauth2.Config{
         ClientID:     clientID,
         ClientSecret: clientSecret,
-        Endpoint:     github.Endpoint,
-        RedirectURL:  redirectURL,
-        Scopes:       []string{"read:user", "user:email"},
+        Endpoint: oauth2.Endpoint{
+            AuthURL:       endpoints.AuthURL,
+            TokenURL:      endpoints.TokenURL,
+            DeviceAuthURL: endpoints.DeviceAuthURL,
+        },
+        RedirectURL: redirectURL,
+        Scopes:      scopes,
     }
     return cfg, nil
 }
-
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// SignupMode controls who may create an account.
+type SignupMode string
+
+const (
+	// SignupOpen lets anyone create an account (subject to the allowlists).
+	SignupOpen SignupMode = "open"
+	// SignupInvite requires an unused invite code issued by an admin.
+	SignupInvite SignupMode = "invite"
+)
+
+// GetSignupMode reads SIGNUP_MODE (open or invite; default open).
+func GetSignupMode() SignupMode {
+	if SignupMode(strings.ToLower(os.Getenv("SIGNUP_MODE"))) == SignupInvite {
+		return SignupInvite
+	}
+	return SignupOpen
+}
+
+// GetSignupAllowedDomains reads SIGNUP_ALLOWED_DOMAINS, a comma-separated list
+// of email domains new accounts must use. Empty means any domain.
+func GetSignupAllowedDomains() []string {
+	return splitList(os.Getenv("SIGNUP_ALLOWED_DOMAINS"))
+}
+
+// GetGitHubAllowedOrgs reads GITHUB_ALLOWED_ORGS, a comma-separated list of
+// GitHub organizations; new GitHub accounts must belong to one of them.
+func GetGitHubAllowedOrgs() []string {
+	return splitList(os.Getenv("GITHUB_ALLOWED_ORGS"))
+}
+
+// GetGitHubAllowedTeams reads GITHUB_ALLOWED_TEAMS, a comma-separated list of
+// "org/team-slug" entries; new GitHub accounts must belong to one of them.
+func GetGitHubAllowedTeams() []string {
+	return splitList(os.Getenv("GITHUB_ALLOWED_TEAMS"))
+}
+
+// GitHubMembershipRequired reports whether GitHub sign-ups are restricted by
+// organization or team, which needs the read:org scope.
+func GitHubMembershipRequired() bool {
+	return len(GetGitHubAllowedOrgs()) > 0 || len(GetGitHubAllowedTeams()) > 0
+}
+
+// splitList splits a comma-separated setting into lowercase, trimmed entries.
+func splitList(value string) []string {
+	var out []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
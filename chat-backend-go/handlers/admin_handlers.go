@@ -0,0 +1,61 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file holds user administration endpoints for admins.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type SetRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+}
+
+// SetUserRole changes another user's role. Admins can't change their own role,
+// so there is always at least one admin left.
+func SetUserRole(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	adminID := c.Locals("userID").(uint)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return apierror.InvalidParameter("Invalid user ID")
+	}
+
+	var req SetRoleRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+	if uint(id) == adminID {
+		return apierror.New(400, apierror.CodeOwnRole, "You cannot change your own role")
+	}
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, id).Error; err != nil {
+		return apierror.UserNotFound
+	}
+	if user.IsBot && req.Role == "admin" {
+		return apierror.Validation("Bot accounts cannot be admins")
+	}
+
+	previous := user.Role
+	if err := config.DB.WithContext(ctx).Model(&user).Update("role", req.Role).Error; err != nil {
+		return apierror.Internal("Failed to update role")
+	}
+	middleware.Audit(c, utils.AuditRoleChanged, "user", auditID(user.ID), models.AuditDetails{
+		"from": previous,
+		"to":   req.Role,
+	})
+
+	return c.JSON(fiber.Map{
+		"user": user,
+	})
+}
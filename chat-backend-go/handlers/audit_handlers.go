@@ -0,0 +1,214 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file lets admins search and export the audit log, and holds the helpers
+// other handlers use to record audit events.
+package handlers
+
+import (
+	"bytes"
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/metrics"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+	maxAuditExportRows   = 50000
+)
+
+// ListAuditLogs returns audit entries, newest first. Filters (all optional):
+// actor_id, action (exact, or a prefix ending in "*" such as "auth.*"),
+// target_type, target_id, since and until (RFC 3339). Page backwards with
+// before_id set to the last ID of the previous page.
+func ListAuditLogs(c *fiber.Ctx) error {
+	query, err := auditQuery(c)
+	if err != nil {
+		return apierror.InvalidParameter(err.Error())
+	}
+
+	limit := c.QueryInt("limit", defaultAuditPageSize)
+	if limit < 1 || limit > maxAuditPageSize {
+		limit = defaultAuditPageSize
+	}
+	if beforeID := c.QueryInt("before_id"); beforeID > 0 {
+		query = query.Where("id < ?", beforeID)
+	}
+
+	entries := []models.AuditLog{}
+	if err := query.Order("id DESC").Limit(limit).Find(&entries).Error; err != nil {
+		return apierror.Internal("Failed to fetch audit log")
+	}
+
+	response := fiber.Map{
+		"entries": entries,
+	}
+	if len(entries) == limit {
+		response["next_before_id"] = entries[len(entries)-1].ID
+	}
+	return c.JSON(response)
+}
+
+// ExportAuditLogs downloads the entries matching the ListAuditLogs filters as
+// CSV (format=csv, the default) or a JSON array (format=json), oldest first.
+func ExportAuditLogs(c *fiber.Ctx) error {
+	format := strings.ToLower(c.Query("format", "csv"))
+	if format != "csv" && format != "json" {
+		return apierror.Validation("format must be csv or json")
+	}
+
+	query, err := auditQuery(c)
+	if err != nil {
+		return apierror.InvalidParameter(err.Error())
+	}
+
+	entries := []models.AuditLog{}
+	if err := query.Order("id").Limit(maxAuditExportRows).Find(&entries).Error; err != nil {
+		return apierror.Internal("Failed to export audit log")
+	}
+
+	filename := fmt.Sprintf("audit-log-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		return c.JSON(entries)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "created_at", "action", "actor_id", "actor_name", "target_type", "target_id", "ip_address", "user_agent", "details"})
+	for _, entry := range entries {
+		actorID := ""
+		if entry.ActorID != nil {
+			actorID = auditID(*entry.ActorID)
+		}
+		details := ""
+		if len(entry.Details) > 0 {
+			raw, _ := json.Marshal(entry.Details)
+			details = string(raw)
+		}
+		w.Write([]string{
+			auditID(entry.ID),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(entry.Action),
+			actorID,
+			csvCell(entry.ActorName),
+			csvCell(entry.TargetType),
+			csvCell(entry.TargetID),
+			csvCell(entry.IPAddress),
+			csvCell(entry.UserAgent),
+			csvCell(details),
+		})
+	}
+	w.Flush()
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	return c.Send(buf.Bytes())
+}
+
+// csvCell prefixes values that spreadsheets would evaluate as a formula with
+// a quote, so user-supplied fields such as usernames and user agents are shown
+// as text when the export is opened.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// auditQuery builds the audit log query from the request's filter parameters.
+func auditQuery(c *fiber.Ctx) (*gorm.DB, error) {
+	ctx := c.UserContext()
+
+	query := config.DB.WithContext(ctx).Model(&models.AuditLog{})
+
+	if actor := c.Query("actor_id"); actor != "" {
+		id, err := strconv.ParseUint(actor, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actor_id")
+		}
+		query = query.Where("actor_id = ?", id)
+	}
+	if action := c.Query("action"); action != "" {
+		if prefix, ok := strings.CutSuffix(action, "*"); ok {
+			query = query.Where(`action LIKE ? ESCAPE '\'`, escapeLike(prefix)+"%")
+		} else {
+			query = query.Where("action = ?", action)
+		}
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	for _, bound := range []struct{ param, cond string }{
+		{"since", "created_at >= ?"},
+		{"until", "created_at < ?"},
+	} {
+		value := c.Query(bound.param)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be an RFC 3339 time", bound.param)
+		}
+		query = query.Where(bound.cond, t)
+	}
+
+	return query, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// auditID formats a numeric ID as an audit target.
+func auditID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// auditLogin records a successful sign-in with the given method (password,
+// two_factor or a provider name) in the audit log and the login metrics.
+func auditLogin(c *fiber.Ctx, user *models.User, method string) {
+	metrics.Login(method, true)
+	middleware.AuditAs(c, user.ID, utils.AuditLogin, "user", auditID(user.ID), models.AuditDetails{"method": method})
+}
+
+// auditLoginFailure records a failed sign-in. user is nil when no account was
+// identified; email is whatever address the attempt used.
+func auditLoginFailure(c *fiber.Ctx, user *models.User, email, method, reason string) {
+	metrics.Login(method, false)
+	details := models.AuditDetails{"method": method, "reason": reason}
+	if email != "" {
+		details["email"] = email
+	}
+	targetID := ""
+	if user != nil {
+		targetID = auditID(user.ID)
+	}
+	middleware.AuditAs(c, 0, utils.AuditLoginFailed, "user", targetID, details)
+}
+
+// auditIdentityLinked records a provider identity being attached to userID.
+// via says how: from account settings or automatically by verified email.
+func auditIdentityLinked(c *fiber.Ctx, userID uint, linked *models.UserIdentity, via string) {
+	middleware.AuditAs(c, userID, utils.AuditIdentityLinked, "user", auditID(userID), models.AuditDetails{
+		"provider": linked.Provider,
+		"subject":  linked.Subject,
+		"via":      via,
+	})
+}
@@ -1,13 +1,19 @@
 package handlers
 
 import (
+    "chat-backend-go/apierror"
     "chat-backend-go/config"
-    "chat-backend-go/utils"
+    "chat-backend-go/metrics"
+    "chat-backend-go/tracing"
+    "chat-backend-go/validation"
+    "context"
     "encoding/json"
-    "log"
+    "errors"
+    "log/slog"
     "net/http"
     "net/url"
     "strings"
+    "sync"
     "time"
 
     "github.com/gofiber/fiber/v2"
@@ -28,140 +34,257 @@ type devicePollResponse struct {
     TokenType   string `json:"token_type"`
     Scope       string `json:"scope"`
     Error       string `json:"error"`
+    Interval    int    `json:"interval"`
 }
 
+const (
+    defaultDeviceInterval  = 5 * time.Second
+    defaultDeviceExpiry    = 15 * time.Minute
+    deviceSlowDownIncrease = 5 * time.Second
+)
+
+// githubDeviceClient talks to GitHub's device flow endpoints; its calls are
+// timed in the identity provider latency metrics and traced.
+var githubDeviceClient = metrics.InstrumentClient("github", tracing.InstrumentClient(&http.Client{Timeout: 10 * time.Second}))
+
+// deviceFlow is the server-side state of a pending device authorization. Each
+// poll makes at most one request to GitHub, and clients that poll faster than
+// the interval are told to slow down without GitHub being contacted.
+type deviceFlow struct {
+    interval  time.Duration
+    expiresAt time.Time
+    nextPoll  time.Time
+    // inviteCode from the start request, used if the login creates an account
+    inviteCode string
+}
+
+// deviceFlows tracks pending flows by device code. State lives in memory, so
+// with several backend instances the start and poll requests must reach the
+// same one (or the client simply restarts the flow).
+var (
+    deviceFlowsMu sync.Mutex
+    deviceFlows   = map[string]*deviceFlow{}
+)
+
 // GitHubDeviceStart starts the device flow and returns user_code and verification URI.
+// The optional body {"invite_code": "..."} is used if the login creates an account.
 func GitHubDeviceStart(c *fiber.Ctx) error {
+    var reqBody struct {
+        InviteCode string `json:"invite_code"`
+    }
+    if len(c.Body()) > 0 {
+        if err := validation.Parse(c, &reqBody); err != nil {
+            return err
+        }
+    }
+
     oauthCfg, err := config.GetGitHubOAuthConfig()
     if err != nil {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+        return apierror.New(fiber.StatusBadRequest, apierror.CodeProviderNotConfigured, err.Error())
     }
 
     form := url.Values{}
     form.Set("client_id", oauthCfg.ClientID)
     form.Set("scope", strings.Join(oauthCfg.Scopes, " "))
 
-    req, _ := http.NewRequest("POST", "https://github.com/login/device/code", strings.NewReader(form.Encode()))
+    req, _ := http.NewRequestWithContext(c.UserContext(), "POST", oauthCfg.Endpoint.DeviceAuthURL, strings.NewReader(form.Encode()))
     req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
     req.Header.Set("Accept", "application/json")
 
-    httpClient := &http.Client{Timeout: 10 * time.Second}
-    resp, err := httpClient.Do(req)
+    resp, err := githubDeviceClient.Do(req)
     if err != nil {
-        return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "failed to contact GitHub"})
+        return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, "failed to contact GitHub")
     }
     defer resp.Body.Close()
 
     var out deviceStartResponse
     if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-        return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "invalid response from GitHub"})
+        return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, "invalid response from GitHub")
     }
     if out.DeviceCode == "" || out.UserCode == "" {
-        return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "failed to start device flow"})
+        return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, "failed to start device flow")
     }
-    return c.JSON(out)
-}
 
-// GitHubDevicePoll polls GitHub for token and returns app JWT when authorized.
-// Request JSON: { "device_code": string, "timeout": optional seconds, "interval": optional seconds }
-func GitHubDevicePoll(c *fiber.Ctx) error {
-    var reqBody struct {
-        DeviceCode string `json:"device_code"`
-        Timeout    int    `json:"timeout"`
-        Interval   int    `json:"interval"`
+    interval := defaultDeviceInterval
+    if out.Interval > 0 {
+        interval = time.Duration(out.Interval) * time.Second
+    }
+    expiry := defaultDeviceExpiry
+    if out.ExpiresIn > 0 {
+        expiry = time.Duration(out.ExpiresIn) * time.Second
     }
-    if err := c.BodyParser(&reqBody); err != nil || reqBody.DeviceCode == "" {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "device_code required"})
+    out.Interval = int(interval.Seconds())
+    out.ExpiresIn = int(expiry.Seconds())
+
+    now := time.Now()
+    deviceFlowsMu.Lock()
+    pruneDeviceFlowsLocked(now)
+    deviceFlows[out.DeviceCode] = &deviceFlow{
+        interval:   interval,
+        expiresAt:  now.Add(expiry),
+        inviteCode: reqBody.InviteCode,
     }
+    deviceFlowsMu.Unlock()
 
-    if reqBody.Timeout <= 0 {
-        reqBody.Timeout = 60 // default 60s
+    return c.JSON(out)
+}
+
+// GitHubDevicePoll checks once whether the user has authorized the device and
+// returns app tokens when they have. Otherwise it answers immediately with
+// 202 and {"status": "authorization_pending" | "slow_down", "interval": seconds};
+// clients should wait that many seconds before polling again.
+// Request JSON: { "device_code": string }
+func (h *AuthHandler) GitHubDevicePoll(c *fiber.Ctx) error {
+    var reqBody struct {
+        DeviceCode string `json:"device_code" validate:"required"`
     }
-    if reqBody.Interval <= 0 {
-        reqBody.Interval = 5 // default 5s
+    if err := validation.Parse(c, &reqBody); err != nil {
+        return err
     }
 
     oauthCfg, err := config.GetGitHubOAuthConfig()
     if err != nil {
-        return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+        return apierror.New(fiber.StatusBadRequest, apierror.CodeProviderNotConfigured, err.Error())
     }
 
-    deadline := time.Now().Add(time.Duration(reqBody.Timeout) * time.Second)
-    wait := time.Duration(reqBody.Interval) * time.Second
-
-    httpClient := &http.Client{Timeout: 10 * time.Second}
-
-    for time.Now().Before(deadline) {
-        form := url.Values{}
-        form.Set("client_id", oauthCfg.ClientID)
-        form.Set("device_code", reqBody.DeviceCode)
-        form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
-        // GitHub requires client_secret for device token exchange
-        form.Set("client_secret", oauthCfg.ClientSecret)
+    // Claim this poll slot, or tell an impatient client to back off
+    now := time.Now()
+    deviceFlowsMu.Lock()
+    flow, ok := deviceFlows[reqBody.DeviceCode]
+    if !ok || now.After(flow.expiresAt) {
+        delete(deviceFlows, reqBody.DeviceCode)
+        deviceFlowsMu.Unlock()
+        return apierror.New(fiber.StatusBadRequest, apierror.CodeDeviceCodeExpired, "device code expired")
+    }
+    if now.Before(flow.nextPoll) {
+        flow.interval += deviceSlowDownIncrease
+        flow.nextPoll = now.Add(flow.interval)
+        interval := flow.interval
+        deviceFlowsMu.Unlock()
+        return devicePending(c, "slow_down", interval)
+    }
+    flow.nextPoll = now.Add(flow.interval)
+    interval := flow.interval
+    deviceFlowsMu.Unlock()
 
-        httpReq, _ := http.NewRequest("POST", "https://github.com/login/oauth/access_token", strings.NewReader(form.Encode()))
-        httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-        httpReq.Header.Set("Accept", "application/json")
+    pr, err := exchangeDeviceCode(c.UserContext(), oauthCfg, reqBody.DeviceCode)
+    if err != nil {
+        return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, err.Error())
+    }
 
-        resp, err := httpClient.Do(httpReq)
+    if pr.AccessToken != "" {
+        finishDeviceFlow(reqBody.DeviceCode)
+        // We have a GitHub user; fetch profile and issue app JWT
+        gh, err := githubProvider()
         if err != nil {
-            return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "failed to contact GitHub"})
+            return apierror.New(fiber.StatusBadRequest, apierror.CodeProviderNotConfigured, err.Error())
         }
-
-        var pr devicePollResponse
-        if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
-            resp.Body.Close()
-            return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": "invalid response from GitHub"})
+        ctx, cancel := context.WithTimeout(c.UserContext(), 10*time.Second)
+        defer cancel()
+        profile, err := gh.ProfileFromToken(ctx, pr.AccessToken)
+        if err != nil {
+            slog.ErrorContext(c.UserContext(), "GitHub device flow: fetching profile failed", "error", err)
+            return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, err.Error())
+        }
+        user, err := h.linkOrCreateUser(c, "github", profile, flow.inviteCode)
+        if denied, resp := signupDenied(err); denied {
+            auditLoginFailure(c, nil, profile.Email, "github_device", "signup_denied")
+            return resp
+        }
+        if errors.Is(err, errEmailInUse) {
+            auditLoginFailure(c, nil, profile.Email, "github_device", "email_in_use")
+            return apierror.New(fiber.StatusConflict, apierror.CodeAccountConflict, err.Error())
+        }
+        if err != nil {
+            slog.ErrorContext(c.UserContext(), "GitHub device flow: creating or linking user failed", "error", err)
+            return apierror.Internal("failed to sign in with GitHub")
+        }
+        if user.EmailVerifiedAt == nil && config.GetEmailVerificationMode() == config.EmailVerificationBlock {
+            return apierror.EmailNotVerified
         }
-        resp.Body.Close()
-
-		if pr.AccessToken != "" {
-			log.Println("GitHub Device Flow: Received access token, fetching user profile")
-			// We have a GitHub user; fetch profile and issue app JWT
-			tok := &oauth2.Token{AccessToken: pr.AccessToken}
-			ghUser, primaryEmail, err := fetchGitHubUser(tok)
-			if err != nil {
-				log.Printf("GitHub Device Flow: Error fetching user profile: %v", err)
-				return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
-			}
-			log.Printf("GitHub Device Flow: Fetched user profile, email: %s", primaryEmail)
-			user, err := linkOrCreateUserFromGitHub(ghUser, primaryEmail)
-			if err != nil {
-				log.Printf("GitHub Device Flow: Error creating/linking user: %v", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
-			}
-			log.Printf("GitHub Device Flow: User processed successfully, ID: %d", user.ID)
-			appToken, err := utils.GenerateJWT(user.ID)
-			if err != nil {
-				log.Printf("GitHub Device Flow: Error generating JWT: %v", err)
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "failed to generate token"})
-			}
-			log.Printf("GitHub Device Flow: JWT generated successfully for user ID: %d", user.ID)
-			return c.JSON(AuthResponse{Token: appToken, User: *user})
-		}
-
-        switch pr.Error {
-        case "authorization_pending":
-            // Continue waiting
-        case "slow_down":
-            wait += 5 * time.Second
-        case "expired_token":
-            return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "device code expired"})
-        case "access_denied":
-            return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "access denied"})
-        case "":
-            // No explicit error but also no access token; wait
-        default:
-            return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": pr.Error})
+        authResp, err := newAuthResponse(c, user)
+        if err != nil {
+            slog.ErrorContext(c.UserContext(), "GitHub device flow: issuing tokens failed", "user_id", user.ID, "error", err)
+            return apierror.Internal("failed to generate token")
         }
+        slog.InfoContext(c.UserContext(), "GitHub device flow: signed in", "user_id", user.ID)
+        auditLogin(c, user, "github_device")
+        return c.JSON(authResp)
+    }
 
-        // Sleep until next poll interval or deadline
-        select {
-        case <-time.After(wait):
-        case <-time.After(time.Until(deadline)):
-            return c.Status(fiber.StatusRequestTimeout).JSON(fiber.Map{"error": "timeout"})
+    switch pr.Error {
+    case "authorization_pending", "":
+        return devicePending(c, "authorization_pending", interval)
+    case "slow_down":
+        // GitHub includes the new minimum interval; fall back to the RFC 8628 increment
+        deviceFlowsMu.Lock()
+        if pr.Interval > 0 {
+            flow.interval = time.Duration(pr.Interval) * time.Second
+        } else {
+            flow.interval += deviceSlowDownIncrease
         }
+        flow.nextPoll = time.Now().Add(flow.interval)
+        interval = flow.interval
+        deviceFlowsMu.Unlock()
+        return devicePending(c, "slow_down", interval)
+    case "expired_token":
+        finishDeviceFlow(reqBody.DeviceCode)
+        return apierror.New(fiber.StatusBadRequest, apierror.CodeDeviceCodeExpired, "device code expired")
+    case "access_denied":
+        finishDeviceFlow(reqBody.DeviceCode)
+        auditLoginFailure(c, nil, "", "github_device", "access_denied")
+        return apierror.New(fiber.StatusUnauthorized, apierror.CodeAccessDenied, "access denied")
+    default:
+        return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, pr.Error)
     }
+}
+
+// exchangeDeviceCode asks GitHub once whether the device code has been authorized.
+func exchangeDeviceCode(ctx context.Context, oauthCfg *oauth2.Config, deviceCode string) (*devicePollResponse, error) {
+    form := url.Values{}
+    form.Set("client_id", oauthCfg.ClientID)
+    form.Set("device_code", deviceCode)
+    form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+    // GitHub requires client_secret for device token exchange
+    form.Set("client_secret", oauthCfg.ClientSecret)
+
+    httpReq, _ := http.NewRequestWithContext(ctx, "POST", oauthCfg.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+    httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    httpReq.Header.Set("Accept", "application/json")
 
-    return c.Status(fiber.StatusRequestTimeout).JSON(fiber.Map{"error": "timeout"})
+    resp, err := githubDeviceClient.Do(httpReq)
+    if err != nil {
+        return nil, errors.New("failed to contact GitHub")
+    }
+    defer resp.Body.Close()
+
+    var pr devicePollResponse
+    if err := json.NewDecoder(resp.Body).Decode(&pr); err != nil {
+        return nil, errors.New("invalid response from GitHub")
+    }
+    return &pr, nil
+}
+
+// devicePending tells the client to keep waiting and when to poll next.
+func devicePending(c *fiber.Ctx, status string, interval time.Duration) error {
+    return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+        "status":   status,
+        "interval": int(interval.Seconds()),
+    })
+}
+
+// finishDeviceFlow forgets a device code that has completed or failed.
+func finishDeviceFlow(deviceCode string) {
+    deviceFlowsMu.Lock()
+    delete(deviceFlows, deviceCode)
+    deviceFlowsMu.Unlock()
+}
+
+// pruneDeviceFlowsLocked drops expired flows. The caller must hold deviceFlowsMu.
+func pruneDeviceFlowsLocked(now time.Time) {
+    for code, flow := range deviceFlows {
+        if now.After(flow.expiresAt) {
+            delete(deviceFlows, code)
+        }
+    }
 }
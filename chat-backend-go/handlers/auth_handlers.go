@@ -4,19 +4,38 @@
 package handlers
 
 import (
+	"chat-backend-go/apierror"
 	"chat-backend-go/config"
+	"chat-backend-go/middleware"
 	"chat-backend-go/models"
+	"chat-backend-go/repository"
+	"chat-backend-go/tracing"
 	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"errors"
+	"log/slog"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// AuthHandler serves sign-up, sign-in and account recovery, looking users up
+// and creating them through a UserRepository.
+type AuthHandler struct {
+	users repository.UserRepository
+}
+
+// NewAuthHandler returns authentication handlers backed by users.
+func NewAuthHandler(users repository.UserRepository) *AuthHandler {
+	return &AuthHandler{users: users}
+}
+
 type AuthRequest struct {
-	Username string `json:"username" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
-	Role     string `json:"role" validate:"omitempty,oneof=admin user"`
+	Username string `json:"username" validate:"required,username"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=6,max=72"`
+	// InviteCode is required when SIGNUP_MODE=invite
+	InviteCode string `json:"invite_code"`
 }
 
 type LoginRequest struct {
@@ -25,129 +44,248 @@ type LoginRequest struct {
 }
 
 type AuthResponse struct {
-	Token string      `json:"token"`
-	User  models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	ExpiresIn    int         `json:"expires_in"`
+	User         models.User `json:"user"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// newAuthResponse opens a session for the request's client and issues its
+// access token and first refresh token.
+func newAuthResponse(c *fiber.Ctx, user *models.User) (*AuthResponse, error) {
+	session, err := utils.CreateSession(c.UserContext(), user.ID, sessionInfo(c))
+	if err != nil {
+		return nil, err
+	}
+	token, err := utils.IssueSessionTokens(c.UserContext(), session)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.IssueRefreshToken(c.UserContext(), user.ID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+		User:         *user,
+	}, nil
 }
 
 // Register handles user registration
-func Register(c *fiber.Ctx) error {
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
 	var req AuthRequest
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := validation.Parse(c, &req); err != nil {
+		return err
 	}
 
 	// Check if user already exists
-	var existingUser models.User
-	if err := config.DB.Where("email = ? OR username = ?", req.Email, req.Username).First(&existingUser).Error; err == nil {
-		return c.Status(409).JSON(fiber.Map{
-			"error": "User with this email or username already exists",
-		})
+	if taken, err := h.accountTaken(c, req.Email, req.Username); err != nil {
+		return apierror.Internal("Failed to create user")
+	} else if taken {
+		return apierror.New(409, apierror.CodeUserExists, "User with this email or username already exists")
 	}
 
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to hash password",
-		})
+		return apierror.Internal("Failed to hash password")
 	}
 
-	// Create user
-	role := req.Role
-	if role == "" {
-		role = "user" // Default role
-	}
-	
+	// Create user; self-registered accounts are never admins
 	user := models.User{
-		Username: req.Username,
-		Email:    req.Email,
-		Password: string(hashedPassword),
-		Role:     role,
+		Username:    req.Username,
+		Email:       req.Email,
+		Password:    string(hashedPassword),
+		HasPassword: true,
+		Role:        "user",
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to create user",
+	err = createAccount(ctx, h.users, &user, signupRequest{Email: req.Email, InviteCode: req.InviteCode}, nil)
+	if denied, resp := signupDenied(err); denied {
+		return resp
+	}
+	if err != nil {
+		return apierror.Internal("Failed to create user")
+	}
+
+	// Send email verification link
+	if err := sendVerificationEmail(ctx, &user); err != nil {
+		slog.ErrorContext(ctx, "Register: failed to create verification token", "user_id", user.ID, "error", err)
+	}
+
+	// Unverified accounts get no token until the email is confirmed
+	if config.GetEmailVerificationMode() == config.EmailVerificationBlock {
+		return c.Status(201).JSON(fiber.Map{
+			"message": "Account created. Check your email to verify your address before signing in.",
+			"user":    user,
 		})
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID)
+	// Generate JWT and refresh tokens
+	resp, err := newAuthResponse(c, &user)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
+		return apierror.Internal("Failed to generate token")
 	}
 
-	return c.Status(201).JSON(AuthResponse{
-		Token: token,
-		User:  user,
-	})
+	return c.Status(201).JSON(resp)
+}
+
+// accountTaken reports whether a user already has email or username.
+func (h *AuthHandler) accountTaken(c *fiber.Ctx, email, username string) (bool, error) {
+	if _, err := h.users.GetByEmail(c.UserContext(), email); !errors.Is(err, repository.ErrNotFound) {
+		return err == nil, err
+	}
+	if _, err := h.users.GetByUsername(c.UserContext(), username); !errors.Is(err, repository.ErrNotFound) {
+		return err == nil, err
+	}
+	return false, nil
 }
 
 // Login handles user authentication
-func Login(c *fiber.Ctx) error {
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
 	var req LoginRequest
 
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := validation.Parse(c, &req); err != nil {
+		return err
 	}
 
-	// Validate request data
-	if req.Email == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Email is required",
-		})
+	// Throttle per account and refuse locked accounts before checking the password
+	key := accountKey(req.Email)
+	if locked, resp := accountLocked(c, key); locked {
+		return resp
 	}
-	if req.Password == "" {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Password is required",
-		})
+	if limited, resp := limitAccount(c, loginAccountRule(), key); limited {
+		return resp
 	}
 
-	// Find user by email using optimized query
-	user, err := utils.GetUserByEmail(req.Email)
+	user, err := h.users.GetByEmail(ctx, req.Email)
 	if err != nil {
-		return c.Status(401).JSON(fiber.Map{
-			"error": "Invalid credentials",
-		})
+		recordLoginFailure(c, key)
+		auditLoginFailure(c, nil, req.Email, "password", "unknown_user")
+		return apierror.InvalidCredentials
+	}
+
+	// Check password (bcrypt is slow on purpose; trace it apart from the queries)
+	_, span := tracing.Start(ctx, "bcrypt.compare")
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
+	span.End()
+	if err != nil {
+		recordLoginFailure(c, key)
+		auditLoginFailure(c, user, req.Email, "password", "invalid_password")
+		return apierror.InvalidCredentials
 	}
 
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return c.Status(401).JSON(fiber.Map{
-			"error": "Invalid credentials",
+	// Check email verification
+	if user.EmailVerifiedAt == nil && config.GetEmailVerificationMode() == config.EmailVerificationBlock {
+		return apierror.EmailNotVerified
+	}
+
+	// Require a second factor when 2FA is enabled
+	if twoFactorEnabled(ctx, user.ID) {
+		challenge, err := utils.GenerateChallengeJWT(user.ID)
+		if err != nil {
+			return apierror.Internal("Failed to generate token")
+		}
+		return c.JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+			"expires_in":          int(utils.TwoFactorChallengeTTL.Seconds()),
 		})
 	}
+	recordLoginSuccess(c, key)
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID)
+	// Generate JWT and refresh tokens
+	resp, err := newAuthResponse(c, user)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to generate token",
-		})
+		return apierror.Internal("Failed to generate token")
+	}
+	auditLogin(c, user, "password")
+
+	return c.JSON(resp)
+}
+
+// RefreshToken exchanges a refresh token for a new access/refresh token pair.
+// Each refresh token is single-use; replaying one revokes its whole family.
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var req RefreshRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	userID, sessionID, refreshToken, err := utils.RotateRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, utils.ErrRefreshTokenReused) {
+			middleware.AuditAs(c, userID, utils.AuditRefreshTokenReused, "session", sessionID, nil)
+			return apierror.New(401, apierror.CodeRefreshTokenReused, err.Error())
+		}
+		if errors.Is(err, utils.ErrInvalidRefreshToken) {
+			return apierror.New(401, apierror.CodeInvalidRefreshToken, err.Error())
+		}
+		return apierror.Internal("Failed to refresh token")
+	}
+
+	user, err := h.users.GetByID(ctx, userID)
+	if err != nil {
+		return apierror.Unauthenticated.WithMessage("User not found")
+	}
+
+	token, err := utils.IssueSessionTokens(ctx, &models.Session{ID: sessionID, UserID: user.ID})
+	if err != nil {
+		return apierror.Internal("Failed to refresh token")
 	}
 
 	return c.JSON(AuthResponse{
-		Token: token,
-		User:  *user, // Dereference the pointer
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(utils.AccessTokenTTL().Seconds()),
+		User:         *user,
+	})
+}
+
+// Logout revokes the refresh token family so the session can't be renewed.
+func Logout(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var req RefreshRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	userID, err := utils.RevokeRefreshToken(ctx, req.RefreshToken)
+	if err != nil && !errors.Is(err, utils.ErrInvalidRefreshToken) {
+		return apierror.Internal("Failed to log out")
+	}
+	if err == nil {
+		middleware.AuditAs(c, userID, utils.AuditLogout, "user", auditID(userID), nil)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Logged out successfully",
 	})
 }
 
 // GetProfile returns the current user's profile
-func GetProfile(c *fiber.Ctx) error {
+func (h *AuthHandler) GetProfile(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
 	userID := c.Locals("userID").(uint)
 
-	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "User not found",
-		})
+	user, err := h.users.GetByID(ctx, userID)
+	if err != nil {
+		return apierror.UserNotFound
 	}
 
 	return c.JSON(user)
@@ -0,0 +1,342 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file implements browser login through external identity providers
+// (GitHub, OpenID Connect) and maps their profiles onto local users.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/identity"
+	"chat-backend-go/models"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oauthStateCookie = "oauth_state"
+	oauthStateTTL    = 10 * time.Minute
+)
+
+// oauthState is kept in an HttpOnly cookie between the login redirect and
+// the callback. The cookie is not signed, so the callback must not trust
+// anything in it that the browser's owner couldn't legitimately choose.
+type oauthState struct {
+	Provider string `json:"p"`
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	// InviteCode is passed on to sign-up when SIGNUP_MODE=invite
+	InviteCode string `json:"i,omitempty"`
+}
+
+func randomState(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ListProviders returns the identity providers users can sign in with.
+func ListProviders(c *fiber.Ctx) error {
+	list := []fiber.Map{}
+	for _, p := range identity.All() {
+		list = append(list, fiber.Map{
+			"name":         p.Name(),
+			"display_name": p.DisplayName(),
+			"login_url":    "/api/auth/providers/" + p.Name() + "/login",
+		})
+	}
+	return c.JSON(fiber.Map{
+		"providers": list,
+	})
+}
+
+// ProviderLogin redirects the user to the named provider's authorization page.
+func ProviderLogin(c *fiber.Ctx) error {
+	return providerLogin(c, c.Params("provider"))
+}
+
+// ProviderCallback completes a login started by ProviderLogin.
+func (h *AuthHandler) ProviderCallback(c *fiber.Ctx) error {
+	return h.providerCallback(c, c.Params("provider"))
+}
+
+func providerLogin(c *fiber.Ctx, name string) error {
+	provider, err := identity.Get(name)
+	if err != nil {
+		return apierror.ProviderNotConfigured
+	}
+
+	state, err := randomState(24)
+	if err != nil {
+		return apierror.Internal("failed to generate state")
+	}
+	nonce, err := randomState(24)
+	if err != nil {
+		return apierror.Internal("failed to generate state")
+	}
+	saved := oauthState{
+		Provider:   name,
+		State:      state,
+		Nonce:      nonce,
+		Verifier:   oauth2.GenerateVerifier(),
+		InviteCode: c.Query("invite_code"),
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 10*time.Second)
+	defer cancel()
+	authURL, err := provider.AuthCodeURL(ctx, saved.State, saved.Nonce, saved.Verifier)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "OAuth: building authorization URL failed", "provider", name, "error", err)
+		return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, "identity provider unavailable")
+	}
+
+	// Persist state, nonce and PKCE verifier in an HttpOnly cookie with short TTL
+	value, _ := json.Marshal(saved)
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(value),
+		HTTPOnly: true,
+		Secure:   strings.HasPrefix(strings.ToLower(c.Protocol()), "https"),
+		SameSite: "Lax",
+		Expires:  time.Now().Add(oauthStateTTL),
+	})
+
+	return c.Redirect(authURL, http.StatusFound)
+}
+
+func (h *AuthHandler) providerCallback(c *fiber.Ctx, name string) error {
+	provider, err := identity.Get(name)
+	if err != nil {
+		return apierror.ProviderNotConfigured
+	}
+
+	if errCode := c.Query("error"); errCode != "" {
+		return apierror.New(fiber.StatusUnauthorized, apierror.CodeAccessDenied, "login was not authorized: "+errCode)
+	}
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidOAuthState, "missing state or code")
+	}
+
+	// A link started by LinkIdentity is held on the server; any other state
+	// must match the cookie set by ProviderLogin. Both are single-use.
+	var saved oauthState
+	var linkUserID uint
+	if flow, ok := takeLinkFlow(state); ok {
+		if flow.provider != name {
+			return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidOAuthState, "invalid oauth state")
+		}
+		if !utils.SessionActive(c.UserContext(), flow.userID, flow.sessionID) {
+			return apierror.InvalidLinkToken.WithMessage("the session that started linking has ended")
+		}
+		saved = oauthState{Provider: name, State: state, Nonce: flow.nonce, Verifier: flow.verifier}
+		linkUserID = flow.userID
+	} else {
+		saved, ok = readOAuthState(c)
+		c.ClearCookie(oauthStateCookie)
+		if !ok || saved.State != state || saved.Provider != name {
+			return apierror.New(fiber.StatusBadRequest, apierror.CodeInvalidOAuthState, "invalid oauth state")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), 10*time.Second)
+	defer cancel()
+
+	profile, err := provider.Exchange(ctx, code, saved.Nonce, saved.Verifier)
+	if err != nil {
+		slog.WarnContext(c.UserContext(), "OAuth: login failed", "provider", name, "error", err)
+		auditLoginFailure(c, nil, "", name, "provider_error")
+		return apierror.New(fiber.StatusBadRequest, apierror.CodeProviderError, "failed to complete login with identity provider")
+	}
+
+	if linkUserID != 0 {
+		return finishLinkIdentity(c, linkUserID, name, profile)
+	}
+
+	// Link or create user
+	user, err := h.linkOrCreateUser(c, name, profile, saved.InviteCode)
+	if denied, resp := signupDenied(err); denied {
+		auditLoginFailure(c, nil, profile.Email, name, "signup_denied")
+		return resp
+	}
+	if errors.Is(err, errEmailInUse) {
+		auditLoginFailure(c, nil, profile.Email, name, "email_in_use")
+		return apierror.New(fiber.StatusConflict, apierror.CodeAccountConflict, err.Error())
+	}
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "OAuth: creating or linking user failed", "provider", name, "error", err)
+		return apierror.Internal("failed to sign in with identity provider")
+	}
+	if user.EmailVerifiedAt == nil && config.GetEmailVerificationMode() == config.EmailVerificationBlock {
+		return apierror.EmailNotVerified
+	}
+
+	// Issue JWT and refresh token
+	resp, err := newAuthResponse(c, user)
+	if err != nil {
+		return apierror.Internal("failed to generate token")
+	}
+	auditLogin(c, user, name)
+
+	return c.JSON(resp)
+}
+
+func readOAuthState(c *fiber.Ctx) (oauthState, bool) {
+	var saved oauthState
+	raw, err := base64.RawURLEncoding.DecodeString(c.Cookies(oauthStateCookie))
+	if err != nil || json.Unmarshal(raw, &saved) != nil || saved.State == "" {
+		return oauthState{}, false
+	}
+	return saved, true
+}
+
+// errEmailInUse is returned when a provider login matches a local account by
+// email but the match can't be trusted; the owner must sign in and link instead.
+var errEmailInUse = errors.New("an account with this email already exists; sign in and link this provider from your account settings")
+
+// linkOrCreateUser returns the user linked to a provider identity, creating one if
+// needed. An existing account with the same email is only linked automatically
+// when both the provider and the local account have verified that email. New
+// accounts are subject to the sign-up policy; inviteCode is used in invite mode.
+func (h *AuthHandler) linkOrCreateUser(c *fiber.Ctx, provider string, profile *identity.Profile, inviteCode string) (*models.User, error) {
+	ctx := c.UserContext()
+
+	// If user exists with this identity, return it
+	var linked models.UserIdentity
+	if err := config.DB.WithContext(ctx).Where("provider = ? AND subject = ?", provider, profile.Subject).First(&linked).Error; err == nil {
+		user, err := h.users.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, err
+		}
+		slog.DebugContext(ctx, "OAuth: found user by linked identity", "provider", provider, "user_id", user.ID)
+		touchIdentity(ctx, &linked, profile)
+		updates := map[string]any{"avatar_url": profile.AvatarURL}
+		if profile.EmailVerified && user.EmailVerifiedAt == nil && strings.EqualFold(user.Email, profile.Email) {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := config.DB.WithContext(ctx).Model(user).Updates(updates).Error; err != nil {
+			slog.WarnContext(ctx, "OAuth: failed to update existing user", "provider", provider, "user_id", user.ID, "error", err)
+		}
+		return user, nil
+	}
+
+	// Else try to find by email; linking on an unverified address would let
+	// whoever controls either side take over the other account
+	if user, err := h.users.GetByEmail(ctx, profile.Email); err == nil {
+		if !profile.EmailVerified || user.EmailVerifiedAt == nil {
+			slog.InfoContext(ctx, "OAuth: refusing to link user by unverified email", "provider", provider, "user_id", user.ID)
+			return nil, errEmailInUse
+		}
+		slog.InfoContext(ctx, "OAuth: linking user by verified email", "provider", provider, "user_id", user.ID)
+		linked, err := linkIdentity(ctx, user.ID, provider, profile)
+		if err != nil {
+			slog.ErrorContext(ctx, "OAuth: linking identity to existing user failed", "provider", provider, "user_id", user.ID, "error", err)
+			return nil, err
+		}
+		auditIdentityLinked(c, user.ID, linked, "verified_email")
+		return user, nil
+	}
+
+	// Create new user
+	// Ensure a valid, unique username; provider handles and email addresses
+	// may contain characters local usernames don't allow
+	baseUsername := profile.Username
+	if baseUsername == "" {
+		baseUsername = strings.Split(profile.Email, "@")[0]
+	}
+	baseUsername = validation.SanitizeUsername(baseUsername)
+	username := baseUsername
+	for i := 0; i < 10; i++ {
+		_, err := h.users.GetByUsername(ctx, username)
+		if errors.Is(err, repository.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "OAuth: checking username uniqueness failed", "provider", provider, "error", err)
+			return nil, err
+		}
+		suffix := strconv.Itoa(i + 1)
+		username = baseUsername[:min(len(baseUsername), validation.MaxUsernameLength-len(suffix))] + suffix
+	}
+
+	// Set a random hashed password to satisfy NOT NULL constraint; HasPassword stays false
+	rnd := make([]byte, 24)
+	if _, err := rand.Read(rnd); err != nil {
+		return nil, err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(rnd)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	newUser := models.User{
+		Username:  username,
+		Email:     profile.Email,
+		Password:  string(hashed),
+		Role:      "user",
+		Provider:  provider,
+		AvatarURL: profile.AvatarURL,
+	}
+	if profile.EmailVerified {
+		now := time.Now()
+		newUser.EmailVerifiedAt = &now
+	}
+	signup := signupRequest{
+		Email:         profile.Email,
+		EmailVerified: profile.EmailVerified,
+		Provider:      provider,
+		Groups:        profile.Groups,
+		InviteCode:    inviteCode,
+	}
+	err = createAccount(ctx, h.users, &newUser, signup, func(tx repository.UserRepository) error {
+		return tx.CreateIdentity(ctx, newIdentity(newUser.ID, provider, profile))
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "OAuth: creating user failed", "provider", provider, "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "OAuth: created user", "provider", provider, "user_id", newUser.ID)
+	return &newUser, nil
+}
+
+func newIdentity(userID uint, provider string, profile *identity.Profile) *models.UserIdentity {
+	now := time.Now()
+	return &models.UserIdentity{
+		UserID:     userID,
+		Provider:   provider,
+		Subject:    profile.Subject,
+		Email:      profile.Email,
+		Username:   profile.Username,
+		LastUsedAt: &now,
+	}
+}
+
+// touchIdentity records a login and refreshes the cached profile fields.
+func touchIdentity(ctx context.Context, linked *models.UserIdentity, profile *identity.Profile) {
+	err := config.DB.WithContext(ctx).Model(linked).Updates(map[string]any{
+		"email":        profile.Email,
+		"username":     profile.Username,
+		"last_used_at": time.Now(),
+	}).Error
+	if err != nil {
+		slog.Warn("OAuth: failed to update identity", "provider", linked.Provider, "identity_id", linked.ID, "error", err)
+	}
+}
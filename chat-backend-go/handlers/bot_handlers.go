@@ -0,0 +1,129 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file manages bot accounts: non-interactive users owned by a human user
+// that authenticate only with personal access tokens.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// botEmailDomain is a reserved domain (RFC 2606) so bot addresses never receive mail.
+const botEmailDomain = "bots.invalid"
+
+type CreateBotRequest struct {
+	Username string `json:"username" validate:"required,username"`
+}
+
+// ListBots returns the bots owned by the current user.
+func ListBots(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var bots []models.User
+	if err := config.DB.WithContext(ctx).Where("owner_id = ? AND is_bot = ?", userID, true).Order("username ASC").Find(&bots).Error; err != nil {
+		return apierror.Internal("Failed to fetch bots")
+	}
+
+	return c.JSON(fiber.Map{
+		"bots": bots,
+	})
+}
+
+// CreateBot creates a bot account owned by the current user.
+func CreateBot(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var req CreateBotRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+	username := req.Username
+
+	var existing models.User
+	if err := config.DB.WithContext(ctx).Unscoped().Where("username = ?", username).First(&existing).Error; err == nil {
+		return apierror.New(409, apierror.CodeUsernameTaken, "Username is already taken")
+	}
+
+	// Bots can't sign in interactively: no password, and an undeliverable address
+	now := time.Now()
+	bot := models.User{
+		Username:        username,
+		Email:           username + "@" + botEmailDomain,
+		EmailVerifiedAt: &now,
+		Role:            "user",
+		Provider:        "bot",
+		IsBot:           true,
+		OwnerID:         &userID,
+	}
+	if err := config.DB.WithContext(ctx).Create(&bot).Error; err != nil {
+		return apierror.Internal("Failed to create bot")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"bot": bot,
+	})
+}
+
+// DeleteBot removes a bot owned by the current user and revokes its tokens.
+func DeleteBot(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	bot, ok := ownedBot(c)
+	if !ok {
+		return apierror.BotNotFound
+	}
+
+	if err := utils.RevokeAllPersonalAccessTokens(ctx, bot.ID); err != nil {
+		return apierror.Internal("Failed to revoke bot tokens")
+	}
+	if err := config.DB.WithContext(ctx).Delete(bot).Error; err != nil {
+		return apierror.Internal("Failed to delete bot")
+	}
+	middleware.Audit(c, utils.AuditBotDeleted, "user", auditID(bot.ID), models.AuditDetails{"username": bot.Username})
+
+	return c.JSON(fiber.Map{
+		"message": "Bot deleted",
+	})
+}
+
+// ListBotTokens returns the active tokens of a bot owned by the current user.
+func ListBotTokens(c *fiber.Ctx) error {
+	bot, ok := ownedBot(c)
+	if !ok {
+		return apierror.BotNotFound
+	}
+	return listTokensFor(c, bot.ID)
+}
+
+// CreateBotToken issues a personal access token for a bot owned by the current user.
+func CreateBotToken(c *fiber.Ctx) error {
+	bot, ok := ownedBot(c)
+	if !ok {
+		return apierror.BotNotFound
+	}
+	return createTokenFor(c, bot.ID)
+}
+
+// ownedBot loads the bot named by the :id route parameter if the current user owns it.
+func ownedBot(c *fiber.Ctx) (*models.User, bool) {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var bot models.User
+	if err := config.DB.WithContext(ctx).Where("id = ? AND owner_id = ? AND is_bot = ?", c.Params("id"), userID, true).First(&bot).Error; err != nil {
+		return nil, false
+	}
+	return &bot, true
+}
@@ -0,0 +1,190 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file handles email address verification: issuing single-use tokens,
+// resending them, and confirming an address.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/mailer"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"omitempty,email"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ResendVerification sends a fresh verification email. Authenticated callers get
+// a link for their own account; anonymous callers supply an email address and
+// always receive the same response so the endpoint can't be used to probe emails.
+// Emails are limited per address as well as per IP.
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	response := fiber.Map{
+		"message": "If the account exists and is unverified, a verification email has been sent",
+	}
+
+	var user *models.User
+	if userID, ok := c.Locals("userID").(uint); ok {
+		found, err := h.users.GetByID(ctx, userID)
+		if err != nil {
+			return apierror.UserNotFound
+		}
+		user = found
+		if user.EmailVerifiedAt != nil {
+			return c.JSON(fiber.Map{
+				"message": "Email address is already verified",
+			})
+		}
+		if limited, resp := limitAccount(c, emailResendAccountRule(), accountKey(user.Email)); limited {
+			return resp
+		}
+	} else {
+		var req ResendVerificationRequest
+		if err := validation.Parse(c, &req); err != nil {
+			return err
+		}
+		email := strings.TrimSpace(req.Email)
+		if email == "" {
+			return apierror.Validation("Email is required")
+		}
+		// Limit emails per address whether or not the account exists
+		if limited, resp := limitAccount(c, emailResendAccountRule(), accountKey(email)); limited {
+			return resp
+		}
+		found, err := h.users.GetByEmail(ctx, email)
+		if err != nil || found.EmailVerifiedAt != nil {
+			return c.JSON(response)
+		}
+		user = found
+	}
+
+	if err := sendVerificationEmail(ctx, user); err != nil {
+		return apierror.Internal("Failed to create verification token")
+	}
+
+	return c.JSON(response)
+}
+
+// VerifyEmail confirms an email address. The token may be sent as JSON
+// ({"token": "..."}) or as a ?token= query parameter so emailed links work.
+func VerifyEmail(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	token := c.Query("token")
+	if token == "" && c.Method() == fiber.MethodPost {
+		var req VerifyEmailRequest
+		if err := validation.Parse(c, &req); err != nil {
+			return err
+		}
+		token = req.Token
+	}
+	if token == "" {
+		return apierror.Validation("Verification token is required")
+	}
+
+	// Atomically claim the token so it can only be used once
+	now := time.Now()
+	hash := utils.HashToken(token)
+	result := config.DB.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return apierror.Internal("Failed to verify email")
+	}
+	if result.RowsAffected == 0 {
+		return apierror.New(400, apierror.CodeInvalidVerifyToken, "Invalid or expired verification token")
+	}
+
+	var verification models.EmailVerificationToken
+	if err := config.DB.WithContext(ctx).Where("token_hash = ?", hash).First(&verification).Error; err != nil {
+		return apierror.Internal("Failed to verify email")
+	}
+
+	// Only verify the address the token was issued for, in case it has since changed
+	result = config.DB.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND email = ?", verification.UserID, verification.Email).
+		Update("email_verified_at", now)
+	if result.Error != nil {
+		return apierror.Internal("Failed to verify email")
+	}
+	if result.RowsAffected == 0 {
+		return apierror.New(400, apierror.CodeInvalidVerifyToken, "Invalid or expired verification token")
+	}
+
+	// Any other outstanding verification links for this user are now stale
+	config.DB.WithContext(ctx).Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", verification.UserID).
+		Update("used_at", now)
+
+	return c.JSON(fiber.Map{
+		"message": "Email address verified",
+	})
+}
+
+// sendVerificationEmail stores a new verification token for the user's current
+// address and emails it.
+func sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	verification := models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+	}
+	if err := config.DB.WithContext(ctx).Create(&verification).Error; err != nil {
+		return err
+	}
+
+	sendMail(verificationEmail(user, token))
+	return nil
+}
+
+// verificationEmail renders the verification message. EMAIL_VERIFY_URL defaults
+// to this server's confirm endpoint so the link works without a frontend.
+func verificationEmail(user *models.User, token string) mailer.Message {
+	verifyURL := os.Getenv("EMAIL_VERIFY_URL")
+	if verifyURL == "" {
+		verifyURL = "http://localhost:8080/api/auth/email/verify"
+	}
+	link := verifyURL + "?token=" + url.QueryEscape(token)
+
+	body := fmt.Sprintf(`Hi %s,
+
+Please confirm that %s is your email address by opening the link below
+within %d hours:
+
+%s
+
+Or use this verification token: %s
+
+If you didn't create a WindGo Chat account, you can ignore this email.
+`, user.Username, user.Email, int(emailVerificationTTL.Hours()), link, token)
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your WindGo Chat email address",
+		Body:    body,
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"chat-backend-go/health"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Livez is the liveness probe: it answers as long as the process can serve
+// requests and deliberately checks no dependencies, so a database outage does
+// not get the server restarted.
+func Livez(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.JSON(fiber.Map{
+		"status": health.StatusOK,
+	})
+}
+
+// Readyz is the readiness probe: it runs every registered health check and
+// answers 503 if any of them fails. It is served on the public port, so it
+// reports only each check's status and latency; failures are logged, and
+// ReadyzDetails gives the full report to operators.
+func Readyz(c *fiber.Ctx) error {
+	report := runHealthChecks(c)
+	for name, result := range report.Checks {
+		if result.Status != health.StatusOK {
+			slog.WarnContext(c.UserContext(), "Health check failed", "check", name, "error", result.Error)
+		}
+	}
+	return c.JSON(report.Redacted())
+}
+
+// ReadyzDetails is Readyz with each check's error and details (connection
+// pool statistics, schema version). It is mounted on the internal metrics
+// listener and behind the admin role.
+func ReadyzDetails(c *fiber.Ctx) error {
+	return c.JSON(runHealthChecks(c))
+}
+
+// runHealthChecks runs the registered checks and sets the probe's status code
+// and caching headers.
+func runHealthChecks(c *fiber.Ctx) health.Report {
+	report := health.Default().Run(c.UserContext(), checkTimeout())
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if !report.OK() {
+		c.Status(fiber.StatusServiceUnavailable)
+	}
+	return report
+}
+
+// checkTimeout is how long each readiness check may take (HEALTH_CHECK_TIMEOUT,
+// default 2s).
+func checkTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("HEALTH_CHECK_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 2 * time.Second
+}
@@ -0,0 +1,236 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file lets a signed-in user list, link and unlink the external identity
+// providers they can sign in with.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/identity"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/oauth2"
+)
+
+var (
+	errIdentityLinkedElsewhere = errors.New("this identity is already linked to another account")
+	errProviderAlreadyLinked   = errors.New("a different identity from this provider is already linked to your account")
+)
+
+// ListIdentities returns the providers linked to the current user and whether
+// the account also has a password.
+func ListIdentities(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return apierror.UserNotFound
+	}
+
+	identities := []models.UserIdentity{}
+	if err := config.DB.WithContext(ctx).Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return apierror.Internal("Failed to fetch identities")
+	}
+
+	return c.JSON(fiber.Map{
+		"identities":   identities,
+		"has_password": user.HasPassword,
+	})
+}
+
+// linkFlowTTL bounds a provider link from LinkIdentity to the callback.
+const linkFlowTTL = 10 * time.Minute
+
+// linkFlow is a provider link in progress, keyed by its OAuth state. It is
+// kept on the server and bound to the session that started it, so nothing
+// the browser sends decides which account the identity is linked to.
+type linkFlow struct {
+	userID    uint
+	sessionID string
+	provider  string
+	nonce     string
+	verifier  string
+	expiresAt time.Time
+}
+
+// linkFlows tracks pending links by state. Like device flows they live in
+// memory, so with several backend instances the callback must reach the
+// instance that started the link (or the user starts again).
+var (
+	linkFlowsMu sync.Mutex
+	linkFlows   = map[string]*linkFlow{}
+)
+
+// LinkIdentity starts linking a provider to the current account. It returns
+// the provider's authorization URL for the browser; the provider callback
+// then attaches the identity to this user instead of signing in. The link is
+// only completed while the session that started it is still active.
+func LinkIdentity(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+	sessionID, _ := c.Locals("sessionID").(string)
+	// The flow outlives the request, and Fiber reuses the buffer Params points into
+	name := strings.Clone(c.Params("provider"))
+
+	provider, err := identity.Get(name)
+	if err != nil {
+		return apierror.ProviderNotConfigured
+	}
+
+	var count int64
+	config.DB.WithContext(ctx).Model(&models.UserIdentity{}).Where("user_id = ? AND provider = ?", userID, name).Count(&count)
+	if count > 0 {
+		return apierror.New(409, apierror.CodeIdentityLinked, "Provider is already linked")
+	}
+
+	state, err := randomState(24)
+	if err != nil {
+		return apierror.Internal("Failed to start linking")
+	}
+	nonce, err := randomState(24)
+	if err != nil {
+		return apierror.Internal("Failed to start linking")
+	}
+	flow := &linkFlow{
+		userID:    userID,
+		sessionID: sessionID,
+		provider:  name,
+		nonce:     nonce,
+		verifier:  oauth2.GenerateVerifier(),
+		expiresAt: time.Now().Add(linkFlowTTL),
+	}
+
+	providerCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	authURL, err := provider.AuthCodeURL(providerCtx, state, flow.nonce, flow.verifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "OAuth: building authorization URL failed", "provider", name, "error", err)
+		return apierror.New(fiber.StatusBadGateway, apierror.CodeProviderError, "identity provider unavailable")
+	}
+
+	linkFlowsMu.Lock()
+	pruneLinkFlowsLocked(time.Now())
+	linkFlows[state] = flow
+	linkFlowsMu.Unlock()
+
+	return c.JSON(fiber.Map{
+		"login_url":  authURL,
+		"expires_in": int(linkFlowTTL.Seconds()),
+	})
+}
+
+// takeLinkFlow removes and returns the pending link with the given state.
+// Each link can be completed once.
+func takeLinkFlow(state string) (*linkFlow, bool) {
+	linkFlowsMu.Lock()
+	defer linkFlowsMu.Unlock()
+	pruneLinkFlowsLocked(time.Now())
+	flow, ok := linkFlows[state]
+	delete(linkFlows, state)
+	return flow, ok
+}
+
+// pruneLinkFlowsLocked drops expired links. The caller must hold linkFlowsMu.
+func pruneLinkFlowsLocked(now time.Time) {
+	for state, flow := range linkFlows {
+		if now.After(flow.expiresAt) {
+			delete(linkFlows, state)
+		}
+	}
+}
+
+// UnlinkIdentity removes a linked provider. The last way to sign in can't be
+// removed: users without a password must keep at least one identity.
+func UnlinkIdentity(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+	name := c.Params("provider")
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return apierror.UserNotFound
+	}
+
+	var linked models.UserIdentity
+	if err := config.DB.WithContext(ctx).Where("user_id = ? AND provider = ?", userID, name).First(&linked).Error; err != nil {
+		return apierror.New(404, apierror.CodeIdentityNotLinked, "Provider is not linked")
+	}
+
+	var count int64
+	config.DB.WithContext(ctx).Model(&models.UserIdentity{}).Where("user_id = ?", userID).Count(&count)
+	if count <= 1 && !user.HasPassword {
+		return apierror.New(409, apierror.CodeLastSignInMethod, "Cannot unlink your only sign-in method; set a password or link another provider first")
+	}
+
+	if err := config.DB.WithContext(ctx).Delete(&linked).Error; err != nil {
+		return apierror.Internal("Failed to unlink provider")
+	}
+	middleware.Audit(c, utils.AuditIdentityUnlinked, "user", auditID(userID), models.AuditDetails{
+		"provider": linked.Provider,
+		"subject":  linked.Subject,
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "Provider unlinked",
+	})
+}
+
+// finishLinkIdentity completes a provider callback that was started by LinkIdentity.
+func finishLinkIdentity(c *fiber.Ctx, userID uint, provider string, profile *identity.Profile) error {
+	ctx := c.UserContext()
+
+	linked, err := linkIdentity(ctx, userID, provider, profile)
+	if errors.Is(err, errIdentityLinkedElsewhere) {
+		return apierror.New(409, apierror.CodeAccountConflict, err.Error())
+	}
+	if errors.Is(err, errProviderAlreadyLinked) {
+		return apierror.New(409, apierror.CodeIdentityLinked, err.Error())
+	}
+	if err != nil {
+		return apierror.Internal("Failed to link provider")
+	}
+	auditIdentityLinked(c, userID, linked, "account_settings")
+
+	return c.JSON(fiber.Map{
+		"message":  "Provider linked",
+		"identity": linked,
+	})
+}
+
+// linkIdentity attaches a provider identity to a user. Linking the same
+// identity again is a no-op.
+func linkIdentity(ctx context.Context, userID uint, provider string, profile *identity.Profile) (*models.UserIdentity, error) {
+	var existing models.UserIdentity
+	if err := config.DB.WithContext(ctx).Where("provider = ? AND subject = ?", provider, profile.Subject).First(&existing).Error; err == nil {
+		if existing.UserID != userID {
+			return nil, errIdentityLinkedElsewhere
+		}
+		touchIdentity(ctx, &existing, profile)
+		return &existing, nil
+	}
+
+	var count int64
+	config.DB.WithContext(ctx).Model(&models.UserIdentity{}).Where("user_id = ? AND provider = ?", userID, provider).Count(&count)
+	if count > 0 {
+		return nil, errProviderAlreadyLinked
+	}
+
+	linked := newIdentity(userID, provider, profile)
+	if err := config.DB.WithContext(ctx).Create(linked).Error; err != nil {
+		return nil, err
+	}
+	return linked, nil
+}
@@ -0,0 +1,114 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file lets admins issue, list and revoke sign-up invite codes.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultInviteLifetimeDays = 7
+
+type CreateInviteRequest struct {
+	Email         string `json:"email" validate:"omitempty,email"`
+	Note          string `json:"note" validate:"max=200"`
+	MaxUses       int    `json:"max_uses" validate:"omitempty,min=1,max=1000"`
+	ExpiresInDays int    `json:"expires_in_days" validate:"omitempty,min=1,max=90"`
+}
+
+// ListInvites returns all invites, newest first.
+func ListInvites(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	invites := []models.Invite{}
+	if err := config.DB.WithContext(ctx).Order("created_at DESC").Limit(200).Find(&invites).Error; err != nil {
+		return apierror.Internal("Failed to fetch invites")
+	}
+
+	return c.JSON(fiber.Map{
+		"invites":     invites,
+		"signup_mode": config.GetSignupMode(),
+	})
+}
+
+// CreateInvite issues an invite code. The raw code appears only in this response.
+func CreateInvite(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var req CreateInviteRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	maxUses := req.MaxUses
+	if maxUses == 0 {
+		maxUses = 1
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultInviteLifetimeDays
+	}
+
+	code, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return apierror.Internal("Failed to generate invite code")
+	}
+	expiresAt := time.Now().AddDate(0, 0, days)
+	invite := models.Invite{
+		CodeHash:    hash,
+		Prefix:      code[:8],
+		Email:       strings.TrimSpace(req.Email),
+		Note:        strings.TrimSpace(req.Note),
+		MaxUses:     maxUses,
+		CreatedByID: userID,
+		ExpiresAt:   &expiresAt,
+	}
+	if err := config.DB.WithContext(ctx).Create(&invite).Error; err != nil {
+		return apierror.Internal("Failed to create invite")
+	}
+	middleware.Audit(c, utils.AuditInviteCreated, "invite", auditID(invite.ID), models.AuditDetails{
+		"prefix":   invite.Prefix,
+		"max_uses": strconv.Itoa(invite.MaxUses),
+	})
+
+	return c.Status(201).JSON(fiber.Map{
+		"code":   code,
+		"invite": invite,
+	})
+}
+
+// RevokeInvite stops an invite from being used again.
+func RevokeInvite(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return apierror.InvalidParameter("Invalid invite ID")
+	}
+
+	result := config.DB.WithContext(ctx).Model(&models.Invite{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return apierror.Internal("Failed to revoke invite")
+	}
+	if result.RowsAffected == 0 {
+		return apierror.InviteNotFound
+	}
+	middleware.Audit(c, utils.AuditInviteRevoked, "invite", c.Params("id"), nil)
+
+	return c.JSON(fiber.Map{
+		"message": "Invite revoked",
+	})
+}
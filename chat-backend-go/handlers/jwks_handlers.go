@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JWKS publishes the public signing keys so other services can verify WindGo tokens.
+func JWKS(c *fiber.Ctx) error {
+	keys, err := utils.PublicJWKS()
+	if err != nil {
+		return apierror.Internal("Failed to load signing keys")
+	}
+
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(fiber.Map{
+		"keys": keys,
+	})
+}
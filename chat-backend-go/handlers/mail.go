@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"chat-backend-go/mailer"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// sendMail delivers msg through the configured mailer. Failures are logged
+// rather than returned so responses don't reveal whether an email went out.
+func sendMail(msg mailer.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := mailer.Default().Send(ctx, msg); err != nil {
+		slog.Error("Mailer: failed to send", "subject", msg.Subject, "to", msg.To, "error", err)
+	}
+}
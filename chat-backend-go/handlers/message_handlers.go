@@ -1,62 +1,71 @@
 package handlers
 
 import (
-	"chat-backend-go/config"
+	"chat-backend-go/apierror"
+	"chat-backend-go/metrics"
+	"chat-backend-go/middleware"
 	"chat-backend-go/models"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// MessageHandler serves the room and message endpoints from its repositories.
+type MessageHandler struct {
+	repos *repository.Repositories
+}
+
+// NewMessageHandler returns room and message handlers backed by repos.
+func NewMessageHandler(repos *repository.Repositories) *MessageHandler {
+	return &MessageHandler{repos: repos}
+}
+
 // SendMessage creates a new message in a room
-func SendMessage(c *fiber.Ctx) error {
+func (h *MessageHandler) SendMessage(c *fiber.Ctx) error {
 	// Get user ID from JWT middleware (we'll assume it's set)
-	userID := c.Locals("userID")
-	if userID == nil {
-		return c.Status(401).JSON(fiber.Map{
-			"error": "User not authenticated",
-		})
+	userID, ok := c.Locals("userID").(uint)
+	if !ok {
+		return apierror.Unauthenticated
 	}
 
 	type MessageRequest struct {
 		RoomID  uint   `json:"room_id" validate:"required"`
-		Content string `json:"content" validate:"required"`
+		Content string `json:"content" validate:"required,notblank,max=4000"`
 	}
 
 	var req MessageRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid request body",
-		})
+	if err := validation.Parse(c, &req); err != nil {
+		return err
 	}
 
 	// Validate room exists
-	var room models.Room
-	if err := config.DB.First(&room, req.RoomID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Room not found",
-		})
+	room, err := h.repos.Rooms.GetByID(c.UserContext(), req.RoomID)
+	if err != nil {
+		return apierror.RoomNotFound
+	}
+
+	sender, err := h.repos.Users.GetByID(c.UserContext(), userID)
+	if err != nil {
+		return apierror.Unauthenticated.WithMessage("User not found")
+	}
+	if throttled, resp := throttleMessage(c, sender, room, req.Content); throttled {
+		return resp
 	}
 
-	// Create message
+	// Create message; the repository loads the author for the response
 	message := models.Message{
-		UserID:  userID.(uint),
+		UserID:  userID,
 		RoomID:  req.RoomID,
 		Content: req.Content,
 	}
 
-	if err := config.DB.Create(&message).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to create message",
-		})
-	}
-
-	// Load user data for response
-	if err := config.DB.Preload("User").First(&message, message.ID).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to load message data",
-		})
+	if err := h.repos.Messages.Create(c.UserContext(), &message); err != nil {
+		return apierror.Internal("Failed to create message")
 	}
+	metrics.MessageSent(room.ID)
 
 	return c.Status(201).JSON(fiber.Map{
 		"message": "Message sent successfully",
@@ -65,21 +74,16 @@ func SendMessage(c *fiber.Ctx) error {
 }
 
 // GetMessages retrieves messages for a specific room
-func GetMessages(c *fiber.Ctx) error {
+func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
 	roomIDStr := c.Params("roomId")
 	roomID, err := strconv.ParseUint(roomIDStr, 10, 32)
 	if err != nil {
-		return c.Status(400).JSON(fiber.Map{
-			"error": "Invalid room ID",
-		})
+		return apierror.InvalidParameter("Invalid room ID")
 	}
 
 	// Validate room exists
-	var room models.Room
-	if err := config.DB.First(&room, roomID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{
-			"error": "Room not found",
-		})
+	if _, err := h.repos.Rooms.GetByID(c.UserContext(), uint(roomID)); err != nil {
+		return apierror.RoomNotFound
 	}
 
 	// Get pagination parameters
@@ -90,23 +94,11 @@ func GetMessages(c *fiber.Ctx) error {
 	}
 	offset := (page - 1) * limit
 
-	var messages []models.Message
-	if err := config.DB.
-		Preload("User").
-		Where("room_id = ?", roomID).
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch messages",
-		})
+	messages, total, err := h.repos.Messages.ListByRoom(c.UserContext(), uint(roomID), limit, offset)
+	if err != nil {
+		return apierror.Internal("Failed to fetch messages")
 	}
 
-	// Count total messages for pagination
-	var total int64
-	config.DB.Model(&models.Message{}).Where("room_id = ?", roomID).Count(&total)
-
 	return c.JSON(fiber.Map{
 		"messages": messages,
 		"pagination": fiber.Map{
@@ -119,15 +111,110 @@ func GetMessages(c *fiber.Ctx) error {
 }
 
 // GetRooms retrieves all available rooms
-func GetRooms(c *fiber.Ctx) error {
-	var rooms []models.Room
-	if err := config.DB.Find(&rooms).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{
-			"error": "Failed to fetch rooms",
-		})
+func (h *MessageHandler) GetRooms(c *fiber.Ctx) error {
+	rooms, err := h.repos.Rooms.List(c.UserContext())
+	if err != nil {
+		return apierror.Internal("Failed to fetch rooms")
 	}
 
 	return c.JSON(fiber.Map{
 		"rooms": rooms,
 	})
 }
+
+// SetSlowMode sets the minimum number of seconds between one user's messages
+// in a room (0 turns slow mode off). Moderators and admins only.
+func (h *MessageHandler) SetSlowMode(c *fiber.Ctx) error {
+	roomID, err := strconv.ParseUint(c.Params("roomId"), 10, 32)
+	if err != nil {
+		return apierror.InvalidParameter("Invalid room ID")
+	}
+
+	var req struct {
+		Seconds int `json:"seconds" validate:"min=0,max=3600"`
+	}
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	room, err := h.repos.Rooms.GetByID(c.UserContext(), uint(roomID))
+	if err != nil {
+		return apierror.RoomNotFound
+	}
+	previous := room.SlowModeSeconds
+	if err := h.repos.Rooms.SetSlowMode(c.UserContext(), room, req.Seconds); err != nil {
+		return apierror.Internal("Failed to update slow mode")
+	}
+	middleware.Audit(c, utils.AuditSlowModeChanged, "room", auditID(room.ID), models.AuditDetails{
+		"from": strconv.Itoa(previous),
+		"to":   strconv.Itoa(req.Seconds),
+	})
+
+	return c.JSON(fiber.Map{
+		"room": room,
+	})
+}
+
+// DeleteMessage removes a message. Authors can delete their own messages;
+// moderators and admins can delete anyone's, which is recorded in the audit log.
+func (h *MessageHandler) DeleteMessage(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return apierror.InvalidParameter("Invalid message ID")
+	}
+
+	message, err := h.repos.Messages.GetByID(c.UserContext(), uint(id))
+	if err != nil {
+		return apierror.MessageNotFound
+	}
+
+	moderated := message.UserID != userID
+	if moderated {
+		user, err := h.repos.Users.GetByID(c.UserContext(), userID)
+		if err != nil || (user.Role != "admin" && user.Role != "moderator") {
+			return apierror.New(403, apierror.CodeMessageForbidden, "You can only delete your own messages")
+		}
+	}
+
+	if err := h.repos.Messages.Delete(c.UserContext(), message); err != nil {
+		return apierror.Internal("Failed to delete message")
+	}
+	if moderated {
+		middleware.Audit(c, utils.AuditMessageDeleted, "message", auditID(message.ID), models.AuditDetails{
+			"room_id":   auditID(message.RoomID),
+			"author_id": auditID(message.UserID),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Message deleted",
+	})
+}
+
+// DeleteRoom removes a room and its messages. Admins only.
+func (h *MessageHandler) DeleteRoom(c *fiber.Ctx) error {
+	roomID, err := strconv.ParseUint(c.Params("roomId"), 10, 32)
+	if err != nil {
+		return apierror.InvalidParameter("Invalid room ID")
+	}
+
+	room, err := h.repos.Rooms.GetByID(c.UserContext(), uint(roomID))
+	if err != nil {
+		return apierror.RoomNotFound
+	}
+
+	deleted, err := h.repos.Rooms.Delete(c.UserContext(), room)
+	if err != nil {
+		return apierror.Internal("Failed to delete room")
+	}
+	middleware.Audit(c, utils.AuditRoomDeleted, "room", auditID(room.ID), models.AuditDetails{
+		"name":     room.Name,
+		"messages": strconv.FormatInt(deleted, 10),
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "Room deleted",
+	})
+}
@@ -0,0 +1,287 @@
+package handlers_test
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/handlers"
+	"chat-backend-go/internal/testdb"
+	"chat-backend-go/models"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// newMessageApp wires the message and user handlers to a fresh database. The
+// X-User-ID header stands in for the JWT middleware.
+func newMessageApp(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	db := testdb.New(t)
+	ratelimit.SetDefault(ratelimit.NewMemoryStore())
+
+	repos := repository.NewGorm(db)
+	messages := handlers.NewMessageHandler(repos)
+	users := handlers.NewUserHandler(repos.Users)
+
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	app.Use(func(c *fiber.Ctx) error {
+		if id, err := strconv.ParseUint(c.Get("X-User-ID"), 10, 32); err == nil {
+			c.Locals("userID", uint(id))
+		}
+		return c.Next()
+	})
+	app.Get("/rooms", messages.GetRooms)
+	app.Post("/messages", messages.SendMessage)
+	app.Get("/rooms/:roomId/messages", messages.GetMessages)
+	app.Delete("/messages/:id", messages.DeleteMessage)
+	app.Put("/rooms/:roomId/slow-mode", messages.SetSlowMode)
+	app.Delete("/rooms/:roomId", messages.DeleteRoom)
+	app.Get("/users", users.ListUsers)
+	return app, db
+}
+
+func do(t *testing.T, app *fiber.App, method, path string, userID uint, body string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if userID != 0 {
+		req.Header.Set("X-User-ID", strconv.FormatUint(uint64(userID), 10))
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatalf("%s %s: decode %q: %v", method, path, raw, err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestMessageHandlers(t *testing.T) {
+	app, db := newMessageApp(t)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com"}
+	bob := &models.User{Username: "bob", Email: "bob@example.com"}
+	mod := &models.User{Username: "mod", Email: "mod@example.com", Role: "moderator"}
+	admin := &models.User{Username: "admin", Email: "admin@example.com", Role: "admin"}
+	room := &models.Room{Name: "general"}
+	for _, v := range []any{alice, bob, mod, admin, room} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	roomPath := "/rooms/" + strconv.FormatUint(uint64(room.ID), 10)
+	send := `{"room_id":` + strconv.FormatUint(uint64(room.ID), 10) + `,"content":"hello"}`
+
+	status, body := do(t, app, "POST", "/messages", alice.ID, send)
+	if status != 201 {
+		t.Fatalf("send: %d %v", status, body)
+	}
+	data := body["data"].(map[string]any)
+	if data["user"].(map[string]any)["username"] != "alice" {
+		t.Fatalf("send: author missing from %v", data)
+	}
+	messagePath := "/messages/" + strconv.Itoa(int(data["id"].(float64)))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		user   uint
+		body   string
+		status int
+		code   string
+	}{
+		{"list rooms", "GET", "/rooms", 0, "", 200, ""},
+		{"send to missing room", "POST", "/messages", alice.ID, `{"room_id":999,"content":"hi"}`, 404, apierror.CodeRoomNotFound},
+		{"send malformed body", "POST", "/messages", alice.ID, `{`, 400, apierror.CodeInvalidBody},
+		{"send blank message", "POST", "/messages", alice.ID, `{"room_id":1,"content":"  "}`, 400, apierror.CodeValidationFailed},
+		{"send oversized message", "POST", "/messages", alice.ID, `{"room_id":1,"content":"` + strings.Repeat("x", 4001) + `"}`, 400, apierror.CodeValidationFailed},
+		{"send unauthenticated", "POST", "/messages", 0, send, 401, apierror.CodeUnauthenticated},
+		{"messages of missing room", "GET", "/rooms/999/messages", alice.ID, "", 404, apierror.CodeRoomNotFound},
+		{"messages bad room id", "GET", "/rooms/abc/messages", alice.ID, "", 400, apierror.CodeInvalidParameter},
+		{"list messages", "GET", roomPath + "/messages?limit=10", alice.ID, "", 200, ""},
+		{"delete someone else's message", "DELETE", messagePath, bob.ID, "", 403, apierror.CodeMessageForbidden},
+		{"slow mode out of range", "PUT", roomPath + "/slow-mode", mod.ID, `{"seconds":4000}`, 400, apierror.CodeValidationFailed},
+		{"slow mode", "PUT", roomPath + "/slow-mode", mod.ID, `{"seconds":0}`, 200, ""},
+		{"moderator deletes message", "DELETE", messagePath, mod.ID, "", 200, ""},
+		{"delete missing message", "DELETE", messagePath, alice.ID, "", 404, apierror.CodeMessageNotFound},
+		{"list users", "GET", "/users?search=BO", alice.ID, "", 200, ""},
+		{"delete room", "DELETE", roomPath, admin.ID, "", 200, ""},
+		{"delete missing room", "DELETE", roomPath, admin.ID, "", 404, apierror.CodeRoomNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, app, tt.method, tt.path, tt.user, tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.status, body)
+			}
+			if tt.code != "" && body["code"] != tt.code {
+				t.Fatalf("code = %v, want %s (%v)", body["code"], tt.code, body)
+			}
+		})
+	}
+
+	var audits int64
+	db.Model(&models.AuditLog{}).Where("action IN ?", []string{utils.AuditMessageDeleted, utils.AuditRoomDeleted, utils.AuditSlowModeChanged}).Count(&audits)
+	if audits != 3 {
+		t.Fatalf("recorded %d moderation audit entries, want 3", audits)
+	}
+}
+
+func TestGetMessagesPagination(t *testing.T) {
+	app, db := newMessageApp(t)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com"}
+	room := &models.Room{Name: "general"}
+	db.Create(alice)
+	db.Create(room)
+	for i := 0; i < 5; i++ {
+		db.Create(&models.Message{UserID: alice.ID, RoomID: room.ID, Content: "m" + strconv.Itoa(i)})
+	}
+
+	status, body := do(t, app, "GET", "/rooms/"+strconv.FormatUint(uint64(room.ID), 10)+"/messages?page=2&limit=2", alice.ID, "")
+	if status != 200 {
+		t.Fatalf("status %d", status)
+	}
+	if got := len(body["messages"].([]any)); got != 2 {
+		t.Fatalf("got %d messages, want 2", got)
+	}
+	pagination := body["pagination"].(map[string]any)
+	if pagination["total"].(float64) != 5 || pagination["totalPages"].(float64) != 3 {
+		t.Fatalf("pagination = %v", pagination)
+	}
+}
+
+func TestListUsersOnlineStatus(t *testing.T) {
+	app, db := newMessageApp(t)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com"}
+	bob := &models.User{Username: "bob", Email: "bob@example.com"}
+	db.Create(alice)
+	db.Create(bob)
+
+	status, body := do(t, app, "GET", "/users", alice.ID, "")
+	if status != 200 {
+		t.Fatalf("status %d", status)
+	}
+	users := body["users"].([]any)
+	if len(users) != 1 {
+		t.Fatalf("got %d users, want only bob", len(users))
+	}
+	if u := users[0].(map[string]any); u["username"] != "bob" || u["status"] != "offline" {
+		t.Fatalf("user = %v", u)
+	}
+}
+
+func TestSlowModeRejectionsKeepTokens(t *testing.T) {
+	t.Setenv("RATE_LIMIT_MESSAGE_USER", "2/1h")
+	app, db := newMessageApp(t)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com"}
+	mod := &models.User{Username: "mod", Email: "mod@example.com", Role: "moderator"}
+	room := &models.Room{Name: "general"}
+	for _, v := range []any{alice, mod, room} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	roomID := strconv.FormatUint(uint64(room.ID), 10)
+	if status, body := do(t, app, "PUT", "/rooms/"+roomID+"/slow-mode", mod.ID, `{"seconds":60}`); status != 200 {
+		t.Fatalf("slow mode: %d %v", status, body)
+	}
+
+	send := func(content string) (int, any) {
+		status, body := do(t, app, "POST", "/messages", alice.ID, `{"room_id":`+roomID+`,"content":"`+content+`"}`)
+		details, _ := body["details"].(map[string]any)
+		return status, details["reason"]
+	}
+	if status, _ := send("first"); status != 201 {
+		t.Fatalf("first message: %d", status)
+	}
+	for i := 0; i < 5; i++ {
+		if status, reason := send("too soon " + strconv.Itoa(i)); status != 429 || reason != "slow_mode" {
+			t.Fatalf("message during cooldown: %d %v", status, reason)
+		}
+	}
+
+	// Only the accepted message spent a token, so one is left after the cooldown
+	if err := ratelimit.Default().Delete(context.Background(), "slow:"+roomID+":"+strconv.FormatUint(uint64(alice.ID), 10)); err != nil {
+		t.Fatal(err)
+	}
+	if status, reason := send("second"); status != 201 {
+		t.Fatalf("message after cooldown: %d %v", status, reason)
+	}
+
+	// A message refused by the rate limit gives its slow mode slot back
+	slowKey := "slow:" + roomID + ":" + strconv.FormatUint(uint64(alice.ID), 10)
+	store := ratelimit.Default()
+	if err := store.Delete(context.Background(), slowKey); err != nil {
+		t.Fatal(err)
+	}
+	if status, reason := send("third"); status != 429 || reason != "rate_limited" {
+		t.Fatalf("message over the rate limit: %d %v", status, reason)
+	}
+	if active, _, _ := store.Get(context.Background(), slowKey); active != 0 {
+		t.Fatal("a refused message started the slow mode cooldown")
+	}
+}
+
+// racedSlowModeStore hides the slow mode slot from reads but not from the
+// claim, as if a concurrent send claimed it in between.
+type racedSlowModeStore struct {
+	ratelimit.Store
+}
+
+func (s racedSlowModeStore) Get(ctx context.Context, key string) (int64, time.Time, error) {
+	if strings.HasPrefix(key, "slow:") {
+		return 0, time.Time{}, nil
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func (s racedSlowModeStore) CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error) {
+	if strings.HasPrefix(key, "slow:") {
+		return false, nil
+	}
+	return s.Store.CompareAndSwap(ctx, key, old, new, ttl)
+}
+
+func TestSlowModeRaceKeepsTokens(t *testing.T) {
+	t.Setenv("RATE_LIMIT_MESSAGE_USER", "1/1h")
+	app, db := newMessageApp(t)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com"}
+	room := &models.Room{Name: "general", SlowModeSeconds: 60}
+	for _, v := range []any{alice, room} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	send := func() int {
+		status, _ := do(t, app, "POST", "/messages", alice.ID, `{"room_id":`+strconv.FormatUint(uint64(room.ID), 10)+`,"content":"hello"}`)
+		return status
+	}
+
+	store := ratelimit.Default()
+	ratelimit.SetDefault(racedSlowModeStore{store})
+	if status := send(); status != 429 {
+		t.Fatalf("send losing the slow mode race: %d", status)
+	}
+	ratelimit.SetDefault(store)
+	if status := send(); status != 201 {
+		t.Fatalf("send after a lost race: %d, want its token unspent", status)
+	}
+}
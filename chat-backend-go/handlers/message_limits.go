@@ -0,0 +1,148 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file throttles message sends: per-user and per-room token buckets,
+// room slow mode, and a temporary mute for users flooding duplicate messages.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/utils"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// floodPolicy mutes a user who sends the same message more than Limit times within Window.
+type floodPolicy struct {
+	Limit  int
+	Window time.Duration
+	Mute   time.Duration
+}
+
+// floodPolicyFromEnv reads MESSAGE_DUPLICATE_LIMIT (default 3, 0 disables),
+// MESSAGE_DUPLICATE_WINDOW (30s) and MESSAGE_MUTE_DURATION (5m).
+func floodPolicyFromEnv() floodPolicy {
+	policy := floodPolicy{Limit: 3, Window: 30 * time.Second, Mute: 5 * time.Minute}
+	if n, err := strconv.Atoi(os.Getenv("MESSAGE_DUPLICATE_LIMIT")); err == nil && n >= 0 {
+		policy.Limit = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("MESSAGE_DUPLICATE_WINDOW")); err == nil && d > 0 {
+		policy.Window = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("MESSAGE_MUTE_DURATION")); err == nil && d > 0 {
+		policy.Mute = d
+	}
+	return policy
+}
+
+func messageUserBucket() ratelimit.Bucket {
+	return ratelimit.BucketFromEnv("message_user", 10, 10*time.Second)
+}
+
+func messageRoomBucket() ratelimit.Bucket {
+	return ratelimit.BucketFromEnv("message_room", 60, 10*time.Second)
+}
+
+// throttleMessage decides whether user may post content to room now and
+// responds 429 if not. Moderators are exempt from slow mode only. It reports
+// whether the request was rejected; limiter store errors let messages through.
+//
+// Mute and slow mode are checked before the token buckets so that a message
+// refused for either doesn't spend the user's or the room's tokens. The slow
+// mode slot is claimed up front, which also settles concurrent sends, and is
+// given back if the message is refused further on.
+func throttleMessage(c *fiber.Ctx, user *models.User, room *models.Room, content string) (bool, error) {
+	ctx := c.UserContext()
+	store := ratelimit.Default()
+	userKey := "user:" + strconv.FormatUint(uint64(user.ID), 10)
+	muteKey := "mute:" + userKey
+
+	if muted, expiresAt, err := store.Get(ctx, muteKey); err != nil {
+		slog.ErrorContext(ctx, "Message throttling: store error", "error", err)
+	} else if muted > 0 {
+		return true, messageThrottled(c, time.Until(expiresAt), "muted", "You are temporarily muted for flooding")
+	}
+
+	slowKey := fmt.Sprintf("slow:%d:%d", room.ID, user.ID)
+	slowClaimed := false
+	if room.SlowModeSeconds > 0 && user.Role != "admin" && user.Role != "moderator" {
+		gap := time.Duration(room.SlowModeSeconds) * time.Second
+		started, err := store.CompareAndSwap(ctx, slowKey, 0, 1, gap)
+		if err != nil {
+			slog.ErrorContext(ctx, "Message throttling: store error", "error", err)
+		} else if !started {
+			_, expiresAt, _ := store.Get(ctx, slowKey)
+			return true, messageThrottled(c, time.Until(expiresAt), "slow_mode",
+				fmt.Sprintf("Slow mode is on: one message every %d seconds", room.SlowModeSeconds))
+		}
+		slowClaimed = started
+	}
+	// refuse gives the slow mode slot back, so a refused message doesn't start the cooldown
+	refuse := func(err error) (bool, error) {
+		if slowClaimed {
+			if err := store.Delete(ctx, slowKey); err != nil {
+				slog.ErrorContext(ctx, "Message throttling: store error", "error", err)
+			}
+		}
+		return true, err
+	}
+
+	buckets := []struct {
+		bucket ratelimit.Bucket
+		key    string
+		msg    string
+	}{
+		{messageUserBucket(), userKey, "You are sending messages too quickly"},
+		{messageRoomBucket(), "room:" + strconv.FormatUint(uint64(room.ID), 10), "This room is receiving too many messages"},
+	}
+	for _, b := range buckets {
+		allowed, retryAfter, err := b.bucket.Take(ctx, store, b.key)
+		if err != nil {
+			slog.ErrorContext(ctx, "Message throttling: store error", "bucket", b.bucket.Name, "error", err)
+			continue
+		}
+		if !allowed {
+			return refuse(messageThrottled(c, retryAfter, "rate_limited", b.msg))
+		}
+	}
+
+	// Identical messages in a short window earn a temporary mute
+	if policy := floodPolicyFromEnv(); policy.Limit > 0 {
+		digest := utils.HashToken(strings.ToLower(strings.Join(strings.Fields(content), " ")))
+		count, err := store.Incr(ctx, "dup:"+userKey+":"+digest, policy.Window)
+		if err != nil {
+			slog.ErrorContext(ctx, "Message throttling: store error", "error", err)
+		} else if count > int64(policy.Limit) {
+			if err := store.Set(ctx, muteKey, 1, policy.Mute); err != nil {
+				slog.ErrorContext(ctx, "Message throttling: store error", "error", err)
+			}
+			slog.InfoContext(ctx, "Message throttling: muted user after repeated duplicate messages", "user_id", user.ID, "duration", policy.Mute)
+			middleware.Audit(c, utils.AuditUserMuted, "user", auditID(user.ID), models.AuditDetails{
+				"reason":   "duplicate_messages",
+				"room_id":  auditID(room.ID),
+				"duration": policy.Mute.String(),
+			})
+			return refuse(messageThrottled(c, policy.Mute, "muted", "You are temporarily muted for flooding"))
+		}
+	}
+
+	return false, nil
+}
+
+// messageThrottled responds 429 with Retry-After and the reason the message was refused.
+func messageThrottled(c *fiber.Ctx, retryAfter time.Duration, reason, message string) error {
+	seconds := max(1, int(math.Ceil(retryAfter.Seconds())))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return apierror.New(429, apierror.CodeMessageThrottled, message).WithDetails(map[string]any{
+		"reason":      reason,
+		"retry_after": seconds,
+	})
+}
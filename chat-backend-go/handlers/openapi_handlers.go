@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"chat-backend-go/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// OpenAPISpec serves the OpenAPI 3 description of this API.
+func OpenAPISpec(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSONCharsetUTF8)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.Send(openapi.Spec)
+}
@@ -0,0 +1,205 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file handles password management: authenticated password changes and
+// the forgot/reset flow backed by single-use, expiring, hashed reset tokens.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/mailer"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passwordResetTTL = time.Hour
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=6,max=72"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6,max=72"`
+}
+
+// ChangePassword updates the current user's password after verifying the old one.
+// All previously issued tokens are revoked and a fresh token pair is returned.
+func ChangePassword(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var req ChangePasswordRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return apierror.UserNotFound
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return apierror.InvalidCredentials.WithMessage("Current password is incorrect")
+	}
+
+	if err := setPassword(ctx, user.ID, req.NewPassword); err != nil {
+		return apierror.Internal("Failed to update password")
+	}
+	middleware.Audit(c, utils.AuditPasswordChanged, "user", auditID(user.ID), nil)
+
+	resp, err := newAuthResponse(c, &user)
+	if err != nil {
+		return apierror.Internal("Failed to generate token")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":       "Password changed successfully",
+		"token":         resp.Token,
+		"refresh_token": resp.RefreshToken,
+		"expires_in":    resp.ExpiresIn,
+	})
+}
+
+// ForgotPassword emails a password reset link. The response is identical whether
+// or not the address belongs to an account, so it cannot be used to probe emails.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var req ForgotPasswordRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+	email := req.Email
+
+	// Limit reset emails per address whether or not the account exists
+	if limited, resp := limitAccount(c, passwordForgotAccountRule(), accountKey(email)); limited {
+		return resp
+	}
+
+	response := fiber.Map{
+		"message": "If an account exists for that email, a password reset link has been sent",
+	}
+
+	user, err := h.users.GetByEmail(ctx, email)
+	if err != nil || user.IsBot {
+		return c.JSON(response)
+	}
+
+	token, hash, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return apierror.Internal("Failed to generate reset token")
+	}
+
+	reset := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := config.DB.WithContext(ctx).Create(&reset).Error; err != nil {
+		return apierror.Internal("Failed to create reset token")
+	}
+
+	sendMail(passwordResetEmail(user, token))
+
+	return c.JSON(response)
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes existing sessions.
+func ResetPassword(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var req ResetPasswordRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	// Atomically claim the token so it can only be used once
+	now := time.Now()
+	result := config.DB.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashToken(req.Token), now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return apierror.Internal("Failed to verify reset token")
+	}
+	if result.RowsAffected == 0 {
+		return apierror.New(400, apierror.CodeInvalidResetToken, "Invalid or expired reset token")
+	}
+
+	var reset models.PasswordResetToken
+	if err := config.DB.WithContext(ctx).Where("token_hash = ?", utils.HashToken(req.Token)).First(&reset).Error; err != nil {
+		return apierror.Internal("Failed to verify reset token")
+	}
+
+	if err := setPassword(ctx, reset.UserID, req.NewPassword); err != nil {
+		return apierror.Internal("Failed to update password")
+	}
+	middleware.AuditAs(c, reset.UserID, utils.AuditPasswordReset, "user", auditID(reset.UserID), nil)
+
+	// Any other outstanding reset links for this user are now stale
+	config.DB.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", reset.UserID).
+		Update("used_at", now)
+
+	return c.JSON(fiber.Map{
+		"message": "Password has been reset. Please sign in with your new password.",
+	})
+}
+
+// setPassword hashes and stores a new password, then revokes all existing tokens.
+func setPassword(ctx context.Context, userID uint, password string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := config.DB.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"password":     string(hashed),
+		"has_password": true,
+	}).Error; err != nil {
+		return err
+	}
+	return utils.RevokeUserTokens(ctx, userID)
+}
+
+// passwordResetEmail renders the reset message. PASSWORD_RESET_URL points at the
+// page that accepts the token; the raw token is also included for CLI users.
+func passwordResetEmail(user *models.User, token string) mailer.Message {
+	resetURL := os.Getenv("PASSWORD_RESET_URL")
+	if resetURL == "" {
+		resetURL = "http://localhost:3000/reset-password"
+	}
+	link := resetURL + "?token=" + url.QueryEscape(token)
+
+	body := fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password for your WindGo Chat account.
+If that was you, open the link below within %d minutes:
+
+%s
+
+Or use this reset token: %s
+
+If you didn't request this, you can ignore this email.
+`, user.Username, int(passwordResetTTL.Minutes()), link, token)
+
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your WindGo Chat password",
+		Body:    body,
+	}
+}
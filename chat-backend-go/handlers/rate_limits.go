@@ -0,0 +1,83 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file holds the per-account rate limits and login lockout applied inside
+// the auth handlers; per-IP limits are attached to the routes.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/utils"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// accountKey normalizes an email into a rate limit key.
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// limitAccount applies rule to key and responds 429 when it is exceeded.
+// It reports whether the request was rejected; store errors let it through.
+func limitAccount(c *fiber.Ctx, rule ratelimit.Rule, key string) (bool, error) {
+	allowed, retryAfter, err := rule.Allow(c.UserContext(), ratelimit.Default(), key)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Rate limiting: store error", "rule", rule.Name, "error", err)
+		return false, nil
+	}
+	if allowed {
+		return false, nil
+	}
+	return true, middleware.TooManyRequests(c, retryAfter, apierror.RateLimited.WithMessage("Too many attempts, please try again later"))
+}
+
+// accountLocked responds 429 if key is locked out after failed sign-ins.
+func accountLocked(c *fiber.Ctx, key string) (bool, error) {
+	remaining, err := ratelimit.LockoutFromEnv().Locked(c.UserContext(), ratelimit.Default(), key)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Rate limiting: store error", "rule", "lockout", "error", err)
+		return false, nil
+	}
+	if remaining <= 0 {
+		return false, nil
+	}
+	return true, middleware.TooManyRequests(c, remaining, apierror.New(429, apierror.CodeAccountLocked,
+		"Account temporarily locked after too many failed sign-in attempts"))
+}
+
+// recordLoginFailure counts a failed sign-in towards the lockout.
+func recordLoginFailure(c *fiber.Ctx, key string) {
+	locked, err := ratelimit.LockoutFromEnv().Fail(c.UserContext(), ratelimit.Default(), key)
+	if err != nil {
+		slog.ErrorContext(c.UserContext(), "Rate limiting: store error", "rule", "lockout", "error", err)
+		return
+	}
+	if locked > 0 {
+		slog.WarnContext(c.UserContext(), "Rate limiting: locked account after repeated failed sign-ins", "key", key, "duration", locked.Round(time.Second))
+		middleware.AuditAs(c, 0, utils.AuditAccountLocked, "account", strings.TrimPrefix(key, "account:"),
+			models.AuditDetails{"duration": locked.Round(time.Second).String()})
+	}
+}
+
+// recordLoginSuccess clears the failure count for key.
+func recordLoginSuccess(c *fiber.Ctx, key string) {
+	if err := ratelimit.LockoutFromEnv().Succeed(c.UserContext(), ratelimit.Default(), key); err != nil {
+		slog.ErrorContext(c.UserContext(), "Rate limiting: store error", "rule", "lockout", "error", err)
+	}
+}
+
+func loginAccountRule() ratelimit.Rule {
+	return ratelimit.RuleFromEnv("login_account", 10, 15*time.Minute)
+}
+
+func passwordForgotAccountRule() ratelimit.Rule {
+	return ratelimit.RuleFromEnv("password_forgot_account", 3, time.Hour)
+}
+
+func emailResendAccountRule() ratelimit.Rule {
+	return ratelimit.RuleFromEnv("email_resend_account", 3, time.Hour)
+}
@@ -0,0 +1,100 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file exposes the caller's login sessions so they can review devices
+// and revoke any they don't recognise.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const maxDeviceNameLength = 100
+
+// sessionInfo describes the requesting client. Clients may name themselves with
+// the X-Device-Name header; otherwise the user agent is used.
+func sessionInfo(c *fiber.Ctx) utils.SessionInfo {
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	deviceName := strings.TrimSpace(c.Get("X-Device-Name"))
+	if deviceName == "" {
+		deviceName = userAgent
+	}
+	if deviceName == "" {
+		deviceName = "Unknown device"
+	}
+	if len(deviceName) > maxDeviceNameLength {
+		deviceName = deviceName[:maxDeviceNameLength]
+	}
+	return utils.SessionInfo{
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IPAddress:  c.IP(),
+	}
+}
+
+// ListSessions returns the current user's active sessions.
+func ListSessions(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+	currentID, _ := c.Locals("sessionID").(string)
+
+	sessions, err := utils.ListSessions(ctx, userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch sessions")
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	return c.JSON(fiber.Map{
+		"sessions": sessions,
+	})
+}
+
+// RevokeSession signs out one of the current user's sessions.
+func RevokeSession(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	revoked, err := utils.RevokeSession(ctx, userID, c.Params("id"))
+	if err != nil {
+		return apierror.Internal("Failed to revoke session")
+	}
+	if !revoked {
+		return apierror.SessionNotFound
+	}
+	middleware.Audit(c, utils.AuditSessionRevoked, "session", c.Params("id"), nil)
+
+	return c.JSON(fiber.Map{
+		"message": "Session revoked",
+	})
+}
+
+// RevokeOtherSessions signs out every session except the one making the request.
+func RevokeOtherSessions(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+	currentID, _ := c.Locals("sessionID").(string)
+
+	count, err := utils.RevokeOtherSessions(ctx, userID, currentID)
+	if err != nil {
+		return apierror.Internal("Failed to revoke sessions")
+	}
+	middleware.Audit(c, utils.AuditSessionRevoked, "user", auditID(userID), models.AuditDetails{
+		"scope": "others",
+		"count": strconv.FormatInt(count, 10),
+	})
+
+	return c.JSON(fiber.Map{
+		"message": "Other sessions revoked",
+		"revoked": count,
+	})
+}
@@ -0,0 +1,98 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file enforces the sign-up policy (SIGNUP_MODE, SIGNUP_ALLOWED_DOMAINS,
+// GITHUB_ALLOWED_ORGS/TEAMS) for every path that creates an account.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// signupRequest describes an account that is about to be created.
+type signupRequest struct {
+	Email         string
+	EmailVerified bool
+	// Provider is the identity provider name, or empty for password registration
+	Provider   string
+	Groups     []string
+	InviteCode string
+}
+
+// signupError is a sign-up refused by policy; its message is safe to return to clients.
+type signupError struct {
+	message string
+}
+
+func (e *signupError) Error() string { return e.message }
+
+// signupDenied returns a 403 error if err is a policy refusal and reports whether it was one.
+func signupDenied(err error) (bool, error) {
+	var denied *signupError
+	if !errors.As(err, &denied) {
+		return false, nil
+	}
+	return true, apierror.New(403, apierror.CodeSignupDenied, denied.message)
+}
+
+// checkSignupPolicy applies the configured restrictions to a new account.
+// Invite codes are only checked for presence here; createAccount claims them.
+func checkSignupPolicy(req signupRequest) error {
+	if domains := config.GetSignupAllowedDomains(); len(domains) > 0 {
+		_, domain, _ := strings.Cut(strings.ToLower(req.Email), "@")
+		if !slices.Contains(domains, domain) {
+			return &signupError{"Sign-up is restricted to approved email domains"}
+		}
+		// An address the provider hasn't verified proves nothing about the domain
+		if req.Provider != "" && !req.EmailVerified {
+			return &signupError{"Your identity provider has not verified this email address"}
+		}
+	}
+
+	if req.Provider == "github" && config.GitHubMembershipRequired() {
+		allowed := append(config.GetGitHubAllowedOrgs(), config.GetGitHubAllowedTeams()...)
+		if !slices.ContainsFunc(req.Groups, func(group string) bool { return slices.Contains(allowed, group) }) {
+			return &signupError{"Sign-up requires membership in an approved GitHub organization or team"}
+		}
+	}
+
+	if config.GetSignupMode() == config.SignupInvite && req.InviteCode == "" {
+		return &signupError{"An invite code is required to sign up"}
+	}
+	return nil
+}
+
+// createAccount checks the sign-up policy and creates user through users. In
+// invite mode the invite is claimed in the same transaction, so a failed insert
+// doesn't use it up. extra, if set, runs in the transaction after the user is
+// created.
+func createAccount(ctx context.Context, users repository.UserRepository, user *models.User, req signupRequest, extra func(tx repository.UserRepository) error) error {
+	if err := checkSignupPolicy(req); err != nil {
+		return err
+	}
+
+	return users.WithTx(ctx, func(tx repository.UserRepository) error {
+		if config.GetSignupMode() == config.SignupInvite {
+			err := tx.ClaimInvite(ctx, utils.HashToken(strings.TrimSpace(req.InviteCode)), req.Email)
+			if errors.Is(err, repository.ErrNotFound) {
+				return &signupError{"Invite code is invalid, expired or already used"}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(ctx, user); err != nil {
+			return err
+		}
+		if extra != nil {
+			return extra(tx)
+		}
+		return nil
+	})
+}
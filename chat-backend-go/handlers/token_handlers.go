@@ -0,0 +1,125 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file lets users manage personal access tokens for themselves and for
+// the bot accounts they own.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const defaultTokenLifetimeDays = 30
+
+type CreateTokenRequest struct {
+	Name          string   `json:"name" validate:"required,notblank,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+// ListTokens returns the current user's active personal access tokens.
+func ListTokens(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	return listTokensFor(c, userID)
+}
+
+// CreateToken issues a personal access token for the current user.
+func CreateToken(c *fiber.Ctx) error {
+	userID := c.Locals("userID").(uint)
+	return createTokenFor(c, userID)
+}
+
+// RevokeToken revokes one of the current user's tokens, or a token belonging
+// to one of their bots.
+func RevokeToken(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	id, err := strconv.ParseUint(c.Params("id"), 10, 32)
+	if err != nil {
+		return apierror.InvalidParameter("Invalid token ID")
+	}
+
+	owners := []uint{userID}
+	var botIDs []uint
+	config.DB.WithContext(ctx).Model(&models.User{}).Where("owner_id = ? AND is_bot = ?", userID, true).Pluck("id", &botIDs)
+	owners = append(owners, botIDs...)
+
+	revoked, err := utils.RevokePersonalAccessToken(ctx, uint(id), owners...)
+	if err != nil {
+		return apierror.Internal("Failed to revoke token")
+	}
+	if !revoked {
+		return apierror.TokenNotFound
+	}
+	middleware.Audit(c, utils.AuditTokenRevoked, "personal_access_token", auditID(uint(id)), nil)
+
+	return c.JSON(fiber.Map{
+		"message": "Token revoked",
+	})
+}
+
+// listTokensFor responds with the active tokens of userID.
+func listTokensFor(c *fiber.Ctx, userID uint) error {
+	ctx := c.UserContext()
+
+	tokens, err := utils.ListPersonalAccessTokens(ctx, userID)
+	if err != nil {
+		return apierror.Internal("Failed to fetch tokens")
+	}
+
+	return c.JSON(fiber.Map{
+		"tokens": tokens,
+	})
+}
+
+// createTokenFor parses a CreateTokenRequest and issues a token for userID.
+// The raw token appears only in this response.
+func createTokenFor(c *fiber.Ctx, userID uint) error {
+	ctx := c.UserContext()
+
+	var req CreateTokenRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !utils.IsKnownScope(scope) {
+			return apierror.Validation("Unknown scope: " + scope).WithDetails(map[string]any{
+				"available_scopes": utils.KnownScopes(),
+			})
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultTokenLifetimeDays
+	}
+
+	token, pat, err := utils.IssuePersonalAccessToken(ctx, userID, name, scopes, time.Now().AddDate(0, 0, days))
+	if err != nil {
+		return apierror.Internal("Failed to create token")
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"message":      "Token created. Copy it now; it won't be shown again.",
+		"access_token": token,
+		"token":        pat,
+	})
+}
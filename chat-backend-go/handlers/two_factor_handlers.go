@@ -0,0 +1,383 @@
+// Package handlers contains HTTP request handlers for the chat application.
+// This file handles optional TOTP two-factor authentication for password
+// accounts: enrollment, confirmation, recovery codes and the login challenge.
+// Accounts that sign in only through an identity provider can't enroll; the
+// provider is responsible for their second factor.
+package handlers
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/utils"
+	"chat-backend-go/validation"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const recoveryCodeCount = 10
+
+// maxChallengeAttempts is how many wrong codes one login challenge allows
+// before the user has to enter their password again.
+const maxChallengeAttempts = 5
+
+// TwoFactorCodeRequest carries a TOTP code; recovery codes can't confirm
+// enrollment or replace themselves.
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// DisableTwoFactorRequest needs the password of password accounts; accounts
+// without one (enrolled before that was refused) prove themselves with the
+// second factor alone.
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+// TwoFactorStatus reports whether 2FA is enabled for the current user.
+func TwoFactorStatus(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var remaining int64
+	enabled := twoFactorEnabled(ctx, userID)
+	if enabled {
+		config.DB.WithContext(ctx).Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&remaining)
+	}
+
+	return c.JSON(fiber.Map{
+		"enabled":                  enabled,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// EnrollTwoFactor starts enrollment by generating a new secret. 2FA is not
+// enforced until the user proves their authenticator works via ConfirmTwoFactor.
+func EnrollTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return apierror.UserNotFound
+	}
+	if !user.HasPassword {
+		return apierror.New(400, apierror.CodePasswordRequired, "Set a password before enabling two-factor authentication")
+	}
+	if twoFactorEnabled(ctx, userID) {
+		return apierror.New(409, apierror.CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return apierror.Internal("Failed to generate secret")
+	}
+
+	// Replace any earlier, unconfirmed enrollment
+	config.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.TOTPCredential{})
+	credential := models.TOTPCredential{UserID: userID, Secret: secret}
+	if err := config.DB.WithContext(ctx).Create(&credential).Error; err != nil {
+		return apierror.Internal("Failed to start enrollment")
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "WindGo Chat"
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(issuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables 2FA once the user submits a valid code for the
+// pending secret, and returns a fresh set of recovery codes.
+func ConfirmTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var req TwoFactorCodeRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	var credential models.TOTPCredential
+	if err := config.DB.WithContext(ctx).Where("user_id = ? AND confirmed_at IS NULL", userID).First(&credential).Error; err != nil {
+		return apierror.New(400, apierror.CodeNoPendingEnrollment, "No pending two-factor enrollment")
+	}
+
+	step, ok := utils.ValidateTOTP(credential.Secret, req.Code, time.Now())
+	if !ok {
+		return apierror.InvalidTwoFactorCode
+	}
+
+	now := time.Now()
+	if err := config.DB.WithContext(ctx).Model(&credential).Updates(map[string]any{
+		"confirmed_at":   now,
+		"last_used_step": step,
+	}).Error; err != nil {
+		return apierror.Internal("Failed to enable two-factor authentication")
+	}
+
+	codes, err := generateRecoveryCodes(ctx, userID)
+	if err != nil {
+		return apierror.Internal("Failed to generate recovery codes")
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; requires a current TOTP code.
+func RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var req TwoFactorCodeRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+	if !twoFactorEnabled(ctx, userID) {
+		return apierror.TwoFactorNotEnabled
+	}
+	if !verifySecondFactor(ctx, userID, req.Code, "") {
+		return apierror.InvalidTwoFactorCode
+	}
+
+	codes, err := generateRecoveryCodes(ctx, userID)
+	if err != nil {
+		return apierror.Internal("Failed to generate recovery codes")
+	}
+
+	return c.JSON(fiber.Map{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableTwoFactor turns 2FA off after checking the password (for password
+// accounts) and a second factor.
+func DisableTwoFactor(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	userID := c.Locals("userID").(uint)
+
+	var req DisableTwoFactorRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return apierror.UserNotFound
+	}
+	if user.HasPassword {
+		if req.Password == "" {
+			return apierror.Validation("Password is required")
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			return apierror.InvalidCredentials.WithMessage("Password is incorrect")
+		}
+	}
+	if !twoFactorEnabled(ctx, userID) {
+		return apierror.TwoFactorNotEnabled
+	}
+	if !verifySecondFactor(ctx, userID, req.Code, req.RecoveryCode) {
+		return apierror.InvalidTwoFactorCode
+	}
+
+	config.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RecoveryCode{})
+	if err := config.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.TOTPCredential{}).Error; err != nil {
+		return apierror.Internal("Failed to disable two-factor authentication")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// VerifyTwoFactorLogin completes a login: it exchanges the challenge token from
+// Login plus a TOTP or recovery code for the real access and refresh tokens.
+// Each challenge completes one login and allows maxChallengeAttempts wrong codes.
+func VerifyTwoFactorLogin(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+
+	var req TwoFactorLoginRequest
+	if err := validation.Parse(c, &req); err != nil {
+		return err
+	}
+
+	userID, challengeID, err := utils.ParseChallengeJWT(req.ChallengeToken)
+	if err != nil {
+		return apierror.New(401, apierror.CodeInvalidChallenge, "Invalid or expired challenge token")
+	}
+	if spent, err := challengeSpent(c, challengeID); err != nil {
+		return apierror.Internal("Failed to verify challenge")
+	} else if spent {
+		return apierror.New(401, apierror.CodeInvalidChallenge, "Challenge token has been used up; sign in again")
+	}
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return apierror.Unauthenticated.WithMessage("User not found")
+	}
+
+	// Wrong codes count towards the same lockout as wrong passwords
+	key := accountKey(user.Email)
+	if locked, resp := accountLocked(c, key); locked {
+		return resp
+	}
+	// Claim the challenge before checking the code, so a concurrent request
+	// can't complete it twice and a recovery code is only spent on a login
+	// that goes through
+	store := ratelimit.Default()
+	claimed, err := store.CompareAndSwap(ctx, challengeUsedKey(challengeID), 0, 1, utils.TwoFactorChallengeTTL)
+	if err != nil {
+		return apierror.Internal("Failed to verify challenge")
+	}
+	if !claimed {
+		return apierror.New(401, apierror.CodeInvalidChallenge, "Challenge token has been used up; sign in again")
+	}
+	if !verifySecondFactor(ctx, userID, req.Code, req.RecoveryCode) {
+		// Release the claim so the challenge can be retried with another code
+		if err := store.Delete(ctx, challengeUsedKey(challengeID)); err != nil {
+			slog.ErrorContext(ctx, "Rate limiting: store error", "rule", "2fa_challenge", "error", err)
+		}
+		recordLoginFailure(c, key)
+		recordChallengeFailure(c, challengeID)
+		auditLoginFailure(c, &user, user.Email, "two_factor", "invalid_code")
+		return apierror.InvalidTwoFactorCode
+	}
+	recordLoginSuccess(c, key)
+
+	resp, err := newAuthResponse(c, &user)
+	if err != nil {
+		return apierror.Internal("Failed to generate token")
+	}
+	auditLogin(c, &user, "two_factor")
+
+	return c.JSON(resp)
+}
+
+func challengeUsedKey(challengeID string) string {
+	return "2fa_challenge_used:" + challengeID
+}
+
+func challengeFailuresKey(challengeID string) string {
+	return "2fa_challenge_failures:" + challengeID
+}
+
+// challengeSpent reports whether a login challenge has completed a login or
+// run out of attempts. Counters live in the rate limit store, which the
+// Postgres store shares between instances.
+func challengeSpent(c *fiber.Ctx, challengeID string) (bool, error) {
+	store := ratelimit.Default()
+	used, _, err := store.Get(c.UserContext(), challengeUsedKey(challengeID))
+	if err != nil {
+		return false, err
+	}
+	failures, _, err := store.Get(c.UserContext(), challengeFailuresKey(challengeID))
+	if err != nil {
+		return false, err
+	}
+	return used > 0 || failures >= maxChallengeAttempts, nil
+}
+
+// recordChallengeFailure counts a wrong code against a login challenge.
+func recordChallengeFailure(c *fiber.Ctx, challengeID string) {
+	if _, err := ratelimit.Default().Incr(c.UserContext(), challengeFailuresKey(challengeID), utils.TwoFactorChallengeTTL); err != nil {
+		slog.ErrorContext(c.UserContext(), "Rate limiting: store error", "rule", "2fa_challenge", "error", err)
+	}
+}
+
+// twoFactorEnabled reports whether the user has a confirmed TOTP credential.
+func twoFactorEnabled(ctx context.Context, userID uint) bool {
+	var count int64
+	config.DB.WithContext(ctx).Model(&models.TOTPCredential{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Count(&count)
+	return count > 0
+}
+
+// verifySecondFactor accepts either a TOTP code newer than the last one used,
+// or an unused recovery code, which is consumed.
+func verifySecondFactor(ctx context.Context, userID uint, code, recoveryCode string) bool {
+	if code != "" {
+		var credential models.TOTPCredential
+		if err := config.DB.WithContext(ctx).Where("user_id = ? AND confirmed_at IS NOT NULL", userID).First(&credential).Error; err != nil {
+			return false
+		}
+		step, ok := utils.ValidateTOTP(credential.Secret, code, time.Now())
+		if !ok {
+			return false
+		}
+		// Claim the step atomically so the same code can't be used twice
+		result := config.DB.WithContext(ctx).Model(&models.TOTPCredential{}).
+			Where("id = ? AND last_used_step < ?", credential.ID, step).
+			Update("last_used_step", step)
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	if recoveryCode != "" {
+		result := config.DB.WithContext(ctx).Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecoveryCode(recoveryCode))).
+			Update("used_at", time.Now())
+		return result.Error == nil && result.RowsAffected == 1
+	}
+
+	return false
+}
+
+// generateRecoveryCodes replaces the user's recovery codes and returns the new
+// plaintext codes, formatted "xxxxx-xxxxx". Only hashes are stored.
+func generateRecoveryCodes(ctx context.Context, userID uint) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(raw)})
+	}
+
+	if err := config.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	if err := config.DB.WithContext(ctx).Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode strips separators and case so "ABCDE-FGHIJ" and "abcdefghij" match.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -1,31 +1,30 @@
 package handlers
 
 import (
-	"chat-backend-go/config"
-	"chat-backend-go/models"
-	"strings"
+	"chat-backend-go/apierror"
+	"chat-backend-go/repository"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// ListUsers returns other users for chat directory, optionally filtered by search query
-func ListUsers(c *fiber.Ctx) error {
-	currentUserID := c.Locals("userID").(uint)
-	search := strings.ToLower(strings.TrimSpace(c.Query("search")))
+// UserHandler serves the user directory from a UserRepository.
+type UserHandler struct {
+	users repository.UserRepository
+}
 
-	var users []models.User
-	query := config.DB.Model(&models.User{}).Where("id <> ?", currentUserID)
+// NewUserHandler returns user directory handlers backed by users.
+func NewUserHandler(users repository.UserRepository) *UserHandler {
+	return &UserHandler{users: users}
+}
 
-	if search != "" {
-		like := "%" + search + "%"
-		query = query.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", like, like)
-	}
+// ListUsers returns other users for chat directory, optionally filtered by search query
+func (h *UserHandler) ListUsers(c *fiber.Ctx) error {
+	currentUserID := c.Locals("userID").(uint)
 
-	if err := query.Order("username ASC").Find(&users).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "Failed to fetch users",
-		})
+	users, err := h.users.Search(c.UserContext(), currentUserID, c.Query("search"))
+	if err != nil {
+		return apierror.Internal("Failed to fetch users")
 	}
 
 	// Calculate online status based on last_active_at
@@ -0,0 +1,58 @@
+package health
+
+import (
+	"chat-backend-go/migrations"
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Database pings the database and reports the connection pool statistics.
+func Database(db *gorm.DB) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		stats := sqlDB.Stats()
+		details := map[string]any{
+			"driver":           db.Dialector.Name(),
+			"open_connections": stats.OpenConnections,
+			"in_use":           stats.InUse,
+			"idle":             stats.Idle,
+			"max_open":         stats.MaxOpenConnections,
+			"wait_count":       stats.WaitCount,
+			"wait_duration_ms": stats.WaitDuration.Milliseconds(),
+		}
+		if err := sqlDB.PingContext(ctx); err != nil {
+			return details, err
+		}
+		return details, nil
+	}
+}
+
+// Migrations fails while any embedded migration has not been applied, so an
+// instance is not sent traffic before its schema is up to date.
+func Migrations(db *gorm.DB) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		statuses, err := migrations.List(db.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		applied, pending, version := 0, 0, 0
+		for _, s := range statuses {
+			if s.AppliedAt == nil {
+				pending++
+				continue
+			}
+			applied++
+			version = s.Version
+		}
+		details := map[string]any{"version": version, "applied": applied, "pending": pending}
+		if pending > 0 {
+			return details, fmt.Errorf("%d pending migrations", pending)
+		}
+		return details, nil
+	}
+}
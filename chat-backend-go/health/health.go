@@ -0,0 +1,184 @@
+// Package health runs the dependency checks behind the readiness probe.
+// Subsystems register a named Check when they start (the database, migrations,
+// and later things like blob storage or pub/sub); Run executes them all in
+// parallel, each with its own deadline, and reports how long each one took.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check probes one dependency. It returns optional details to include in the
+// report, and an error when the dependency is not usable. It should give up
+// when ctx expires.
+type Check func(ctx context.Context) (details map[string]any, err error)
+
+// Status values reported for the whole service and for each check.
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string         `json:"status"`
+	LatencyMS float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Report is the outcome of every registered check. Status is ok only when
+// every check passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Redacted returns a copy of the report with only each check's status and
+// latency. Errors and details can name hosts, ports and pool sizes, which
+// an unauthenticated caller has no business seeing.
+func (r Report) Redacted() Report {
+	redacted := Report{Status: r.Status, Checks: make(map[string]Result, len(r.Checks))}
+	for name, result := range r.Checks {
+		redacted.Checks[name] = Result{Status: result.Status, LatencyMS: result.LatencyMS}
+	}
+	return redacted
+}
+
+// Registry holds the named checks.
+type Registry struct {
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string]Check)}
+}
+
+// Register adds check under name, replacing any check already registered with
+// that name.
+func (r *Registry) Register(name string, check Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// Names lists the registered checks in alphabetical order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes every check concurrently, giving each at most timeout, and
+// collects the results. A check that overruns its deadline is reported as
+// failed even if it ignores ctx.
+func (r *Registry) Run(ctx context.Context, timeout time.Duration) Report {
+	r.mu.RLock()
+	checks := make(map[string]Check, len(r.checks))
+	for name, check := range r.checks {
+		checks[name] = check
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := runCheck(ctx, check, timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusUnavailable
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func runCheck(ctx context.Context, check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		details map[string]any
+		err     error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("check panicked: %v", r)}
+			}
+		}()
+		details, err := check(ctx)
+		done <- outcome{details, err}
+	}()
+
+	var out outcome
+	select {
+	case out = <-done:
+	case <-ctx.Done():
+		out.err = ctx.Err()
+	}
+	if out.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		out.err = fmt.Errorf("timed out after %s", timeout)
+	}
+
+	result := Result{
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   out.details,
+	}
+	if out.err != nil {
+		result.Status = StatusUnavailable
+		result.Error = out.err.Error()
+	}
+	return result
+}
+
+var (
+	defaultMu       sync.Mutex
+	defaultRegistry = NewRegistry()
+)
+
+// Default returns the process-wide registry used by the readiness probe.
+func Default() *Registry {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	return defaultRegistry
+}
+
+// SetDefault replaces the process-wide registry (useful for tests).
+func SetDefault(r *Registry) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultRegistry = r
+}
+
+// Register adds a check to the process-wide registry.
+func Register(name string, check Check) {
+	Default().Register(name, check)
+}
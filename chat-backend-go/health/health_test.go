@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReportsEveryCheck(t *testing.T) {
+	r := NewRegistry()
+	r.Register("cache", func(context.Context) (map[string]any, error) {
+		return map[string]any{"entries": 3}, nil
+	})
+	r.Register("queue", func(context.Context) (map[string]any, error) {
+		return nil, errors.New("connection refused")
+	})
+	r.Register("blob", func(ctx context.Context) (map[string]any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	r.Register("stuck", func(context.Context) (map[string]any, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	r.Register("broken", func(context.Context) (map[string]any, error) {
+		panic("nil storage client")
+	})
+
+	start := time.Now()
+	report := r.Run(context.Background(), 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Run took %s; a check that ignores ctx held it past the deadline", elapsed)
+	}
+
+	if report.OK() || report.Status != StatusUnavailable {
+		t.Fatalf("report status = %q, want %q", report.Status, StatusUnavailable)
+	}
+	if want := []string{"blob", "broken", "cache", "queue", "stuck"}; !reflect.DeepEqual(r.Names(), want) {
+		t.Fatalf("Names = %v, want %v", r.Names(), want)
+	}
+	if got := report.Checks["cache"]; got.Status != StatusOK || got.Details["entries"] != 3 || got.Error != "" {
+		t.Fatalf("cache = %+v", got)
+	}
+	for name, want := range map[string]string{
+		"queue":  "connection refused",
+		"blob":   "timed out",
+		"stuck":  "timed out",
+		"broken": "panicked",
+	} {
+		got := report.Checks[name]
+		if got.Status != StatusUnavailable || !strings.Contains(got.Error, want) {
+			t.Errorf("%s = %+v, want an error containing %q", name, got, want)
+		}
+	}
+}
+
+func TestRunWithoutChecksIsReady(t *testing.T) {
+	if report := NewRegistry().Run(context.Background(), time.Second); !report.OK() || len(report.Checks) != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+}
+
+func TestRedactedKeepsOnlyStatusAndLatency(t *testing.T) {
+	report := Report{Status: StatusUnavailable, Checks: map[string]Result{
+		"database": {Status: StatusUnavailable, LatencyMS: 1.5, Error: "dial tcp 10.0.0.5:5432: connection refused", Details: map[string]any{"open": 3}},
+	}}
+	redacted := report.Redacted()
+	want := Report{Status: StatusUnavailable, Checks: map[string]Result{
+		"database": {Status: StatusUnavailable, LatencyMS: 1.5},
+	}}
+	if !reflect.DeepEqual(redacted, want) {
+		t.Fatalf("Redacted = %+v, want %+v", redacted, want)
+	}
+	if report.Checks["database"].Error == "" {
+		t.Fatal("Redacted modified the original report")
+	}
+}
@@ -0,0 +1,103 @@
+package identity
+
+import (
+	"chat-backend-go/config"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// providersFile is the format of IDENTITY_PROVIDERS_FILE.
+type providersFile struct {
+	Providers []struct {
+		Type string `json:"type"`
+		OIDCConfig
+		// ClientSecretEnv names an environment variable holding the client
+		// secret, so the file itself can be committed.
+		ClientSecretEnv string `json:"client_secret_env"`
+	} `json:"providers"`
+}
+
+// LoadProviders registers every configured identity provider:
+//   - GitHub, when GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET and GITHUB_REDIRECT_URL are set
+//   - one OIDC provider from OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET and OIDC_REDIRECT_URL
+//   - any number of OIDC providers listed in the JSON file named by IDENTITY_PROVIDERS_FILE
+func LoadProviders() error {
+	Reset()
+
+	if cfg, err := config.GetGitHubOAuthConfig(); err == nil {
+		github := NewGitHubProvider(cfg, config.GetGitHubEndpoints().APIBaseURL)
+		if config.GitHubMembershipRequired() {
+			github.WithGroups()
+		}
+		Register(github)
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		cfg := OIDCConfig{
+			Name:         os.Getenv("OIDC_NAME"),
+			DisplayName:  os.Getenv("OIDC_DISPLAY_NAME"),
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+			Claims: ClaimMapping{
+				Username: os.Getenv("OIDC_USERNAME_CLAIM"),
+			},
+		}
+		if cfg.Name == "" {
+			cfg.Name = "oidc"
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = "Single Sign-On"
+		}
+		if err := registerOIDC(cfg); err != nil {
+			return err
+		}
+	}
+
+	if path := os.Getenv("IDENTITY_PROVIDERS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read identity providers file: %w", err)
+		}
+		var file providersFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return fmt.Errorf("parse identity providers file: %w", err)
+		}
+		for _, entry := range file.Providers {
+			if entry.Type != "" && entry.Type != "oidc" {
+				return fmt.Errorf("identity provider %q: unsupported type %q", entry.Name, entry.Type)
+			}
+			cfg := entry.OIDCConfig
+			if entry.ClientSecretEnv != "" {
+				cfg.ClientSecret = os.Getenv(entry.ClientSecretEnv)
+			}
+			if err := registerOIDC(cfg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func registerOIDC(cfg OIDCConfig) error {
+	if !providerNamePattern.MatchString(cfg.Name) || cfg.Name == "github" {
+		return fmt.Errorf("invalid identity provider name %q", cfg.Name)
+	}
+	if _, err := Get(cfg.Name); err == nil {
+		return fmt.Errorf("identity provider %q is configured twice", cfg.Name)
+	}
+	p, err := NewOIDCProvider(cfg)
+	if err != nil {
+		return fmt.Errorf("identity provider %q: %w", cfg.Name, err)
+	}
+	Register(p)
+	return nil
+}
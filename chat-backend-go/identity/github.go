@@ -0,0 +1,234 @@
+package identity
+
+import (
+	"chat-backend-go/metrics"
+	"chat-backend-go/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// GitHubProvider signs users in with GitHub OAuth. GitHub is not an OIDC
+// provider, so the profile comes from the REST API.
+type GitHubProvider struct {
+	config     *oauth2.Config
+	apiBaseURL string
+	httpClient *http.Client
+	// loadGroups fills Profile.Groups with the user's organizations and teams
+	loadGroups bool
+}
+
+// NewGitHubProvider builds a GitHub provider from an OAuth config and the REST
+// API base URL (normally https://api.github.com).
+func NewGitHubProvider(cfg *oauth2.Config, apiBaseURL string) *GitHubProvider {
+	return &GitHubProvider{
+		config:     cfg,
+		apiBaseURL: strings.TrimRight(apiBaseURL, "/"),
+		httpClient: metrics.InstrumentClient("github", tracing.InstrumentClient(&http.Client{Timeout: 10 * time.Second})),
+	}
+}
+
+// WithGroups makes profiles include organization and team memberships. The
+// OAuth config must request the read:org scope.
+func (p *GitHubProvider) WithGroups() *GitHubProvider {
+	p.loadGroups = true
+	return p
+}
+
+func (p *GitHubProvider) Name() string        { return "github" }
+func (p *GitHubProvider) DisplayName() string { return "GitHub" }
+
+// AuthCodeURL returns GitHub's authorization URL. GitHub has no ID tokens, so
+// the nonce is unused; state and PKCE protect the flow.
+func (p *GitHubProvider) AuthCodeURL(_ context.Context, state, _, verifier string) (string, error) {
+	return p.config.AuthCodeURL(state, oauth2.AccessTypeOnline, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems the code and loads the GitHub profile.
+func (p *GitHubProvider) Exchange(ctx context.Context, code, _, verifier string) (*Profile, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+	tok, err := p.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	return p.ProfileFromToken(ctx, tok.AccessToken)
+}
+
+// ProfileFromToken loads the profile for a GitHub access token, e.g. one
+// obtained through the device flow.
+func (p *GitHubProvider) ProfileFromToken(ctx context.Context, accessToken string) (*Profile, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.get(ctx, accessToken, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("github /user returned no id")
+	}
+
+	profile := &Profile{
+		Subject:   fmt.Sprint(user.ID),
+		Username:  user.Login,
+		Name:      user.Name,
+		AvatarURL: user.AvatarURL,
+	}
+
+	if p.loadGroups {
+		groups, err := p.groups(ctx, accessToken)
+		if err != nil {
+			return nil, err
+		}
+		profile.Groups = groups
+	}
+
+	// Prefer the public profile email; GitHub only allows verified addresses there.
+	if user.Email != "" {
+		profile.Email = user.Email
+		profile.EmailVerified = true
+		return profile, nil
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, accessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+	// Choose primary verified, else first verified, else first
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			profile.Email, profile.EmailVerified = e.Email, true
+			break
+		}
+	}
+	if profile.Email == "" {
+		for _, e := range emails {
+			if e.Verified {
+				profile.Email, profile.EmailVerified = e.Email, true
+				break
+			}
+		}
+	}
+	if profile.Email == "" && len(emails) > 0 {
+		profile.Email = emails[0].Email
+	}
+	if profile.Email == "" {
+		return nil, errors.New("no email available from GitHub; ensure 'user:email' scope and a verified email")
+	}
+	return profile, nil
+}
+
+// maxGroupPages bounds how many pages of organizations or teams a login
+// fetches; at 100 per page that covers any realistic membership.
+const maxGroupPages = 10
+
+type githubOrg struct {
+	Login string `json:"login"`
+}
+
+type githubTeam struct {
+	Slug         string `json:"slug"`
+	Organization struct {
+		Login string `json:"login"`
+	} `json:"organization"`
+}
+
+// groups returns the user's organizations as "org" and teams as "org/team".
+func (p *GitHubProvider) groups(ctx context.Context, accessToken string) ([]string, error) {
+	orgs, err := getPages[githubOrg](ctx, p, accessToken, "/user/orgs?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	teams, err := getPages[githubTeam](ctx, p, accessToken, "/user/teams?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(orgs)+len(teams))
+	for _, org := range orgs {
+		groups = append(groups, strings.ToLower(org.Login))
+	}
+	for _, team := range teams {
+		groups = append(groups, strings.ToLower(team.Organization.Login+"/"+team.Slug))
+	}
+	return groups, nil
+}
+
+// getPages fetches a list endpoint and every following page named by the
+// Link header, up to maxGroupPages.
+func getPages[T any](ctx context.Context, p *GitHubProvider, accessToken, path string) ([]T, error) {
+	var all []T
+	next := p.apiBaseURL + path
+	for page := 0; next != ""; page++ {
+		if page == maxGroupPages {
+			return nil, fmt.Errorf("github %s: more than %d pages", path, maxGroupPages)
+		}
+		// Only follow links back to the API; the request carries the token
+		if !strings.HasPrefix(next, p.apiBaseURL+"/") {
+			return nil, fmt.Errorf("github %s: next page %q is outside the API", path, next)
+		}
+		var items []T
+		link, err := p.fetch(ctx, accessToken, next, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		next = nextLink(link)
+	}
+	return all, nil
+}
+
+// nextLink returns the rel="next" URL of a Link header, or "".
+func nextLink(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(target), "<>")
+			}
+		}
+	}
+	return ""
+}
+
+func (p *GitHubProvider) get(ctx context.Context, accessToken, path string, v any) error {
+	_, err := p.fetch(ctx, accessToken, p.apiBaseURL+path, v)
+	return err
+}
+
+// fetch decodes the JSON at url into v and returns the response's Link header.
+func (p *GitHubProvider) fetch(ctx context.Context, accessToken, url string, v any) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("github %s failed: %s", strings.TrimPrefix(url, p.apiBaseURL), string(body))
+	}
+	return resp.Header.Get("Link"), json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,102 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+// newGitHubAPI serves /user and three pages of organizations and teams, each
+// linking to the next the way the GitHub REST API does.
+func newGitHubAPI(t *testing.T, nextURL func(server *httptest.Server, path string, page int) string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	paged := func(item func(page int) string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "bad credentials", http.StatusUnauthorized)
+				return
+			}
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page == 0 {
+				page = 1
+			}
+			if page < 3 {
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s/x?page=3>; rel="last"`, nextURL(server, r.URL.Path, page+1), server.URL))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, "[%s]", item(page))
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": 7, "login": "octocat", "email": "octocat@example.com"}`)
+	})
+	mux.HandleFunc("/user/orgs", paged(func(page int) string {
+		return fmt.Sprintf(`{"login": "Org%d"}`, page)
+	}))
+	mux.HandleFunc("/user/teams", paged(func(page int) string {
+		return fmt.Sprintf(`{"slug": "team%d", "organization": {"login": "Org1"}}`, page)
+	}))
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGitHubGroupsFollowPagination(t *testing.T) {
+	server := newGitHubAPI(t, func(server *httptest.Server, path string, page int) string {
+		return fmt.Sprintf("%s%s?per_page=100&page=%d", server.URL, path, page)
+	})
+	provider := NewGitHubProvider(&oauth2.Config{}, server.URL).WithGroups()
+
+	profile, err := provider.ProfileFromToken(context.Background(), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"org1", "org2", "org3", "org1/team1", "org1/team2", "org1/team3"}
+	if !reflect.DeepEqual(profile.Groups, want) {
+		t.Fatalf("groups = %v, want %v", profile.Groups, want)
+	}
+	if profile.Email != "octocat@example.com" || !profile.EmailVerified {
+		t.Fatalf("email = %q (verified %v)", profile.Email, profile.EmailVerified)
+	}
+}
+
+func TestGitHubPaginationStaysOnTheAPI(t *testing.T) {
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("token sent to %s", r.URL)
+	}))
+	defer elsewhere.Close()
+	server := newGitHubAPI(t, func(_ *httptest.Server, path string, page int) string {
+		return fmt.Sprintf("%s%s?page=%d", elsewhere.URL, path, page)
+	})
+	provider := NewGitHubProvider(&oauth2.Config{}, server.URL).WithGroups()
+
+	_, err := provider.ProfileFromToken(context.Background(), "token")
+	if err == nil || !strings.Contains(err.Error(), "outside the API") {
+		t.Fatalf("err = %v, want the foreign next page refused", err)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header, want string
+	}{
+		{"", ""},
+		{`<https://api.github.com/user/orgs?page=2>; rel="next", <https://api.github.com/user/orgs?page=5>; rel="last"`, "https://api.github.com/user/orgs?page=2"},
+		{`<https://api.github.com/user/orgs?page=1>; rel="prev", <https://api.github.com/user/orgs?page=1>; rel="first"`, ""},
+	}
+	for _, tt := range tests {
+		if got := nextLink(tt.header); got != tt.want {
+			t.Errorf("nextLink(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
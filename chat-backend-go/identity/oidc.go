@@ -0,0 +1,216 @@
+package identity
+
+import (
+	"chat-backend-go/metrics"
+	"chat-backend-go/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// ClaimMapping names the ID token (or userinfo) claims that fill a Profile.
+// Empty fields use the standard OIDC claim names.
+type ClaimMapping struct {
+	Subject       string `json:"subject"`
+	Email         string `json:"email"`
+	EmailVerified string `json:"email_verified"`
+	Username      string `json:"username"`
+	Name          string `json:"name"`
+	Avatar        string `json:"avatar"`
+}
+
+func (m ClaimMapping) withDefaults() ClaimMapping {
+	if m.Subject == "" {
+		m.Subject = "sub"
+	}
+	if m.Email == "" {
+		m.Email = "email"
+	}
+	if m.EmailVerified == "" {
+		m.EmailVerified = "email_verified"
+	}
+	if m.Username == "" {
+		m.Username = "preferred_username"
+	}
+	if m.Name == "" {
+		m.Name = "name"
+	}
+	if m.Avatar == "" {
+		m.Avatar = "picture"
+	}
+	return m
+}
+
+// OIDCConfig configures a generic OpenID Connect provider.
+type OIDCConfig struct {
+	Name         string       `json:"name"`
+	DisplayName  string       `json:"display_name"`
+	Issuer       string       `json:"issuer"`
+	ClientID     string       `json:"client_id"`
+	ClientSecret string       `json:"client_secret"`
+	RedirectURL  string       `json:"redirect_url"`
+	Scopes       []string     `json:"scopes"`
+	Claims       ClaimMapping `json:"claims"`
+}
+
+// OIDCProvider signs users in with any OpenID Connect issuer. Endpoints are
+// found through discovery on first use, the authorization code flow is
+// protected with PKCE and a nonce, and ID tokens are verified against the
+// issuer's JWKS.
+type OIDCProvider struct {
+	cfg        OIDCConfig
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider validates cfg and returns a provider. No network calls are
+// made until the provider is first used.
+func NewOIDCProvider(cfg OIDCConfig) (*OIDCProvider, error) {
+	if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("oidc provider requires name, issuer, client_id and redirect_url")
+	}
+	if cfg.DisplayName == "" {
+		cfg.DisplayName = cfg.Name
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	cfg.Claims = cfg.Claims.withDefaults()
+	return &OIDCProvider{
+		cfg:        cfg,
+		httpClient: metrics.InstrumentClient(cfg.Name, tracing.InstrumentClient(&http.Client{Timeout: 10 * time.Second})),
+	}, nil
+}
+
+func (p *OIDCProvider) Name() string        { return p.cfg.Name }
+func (p *OIDCProvider) DisplayName() string { return p.cfg.DisplayName }
+
+// AuthCodeURL returns the issuer's authorization URL with the nonce and PKCE challenge.
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange redeems the code, verifies the ID token and maps its claims. Claims
+// missing from the ID token are looked up at the userinfo endpoint when available.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Profile, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	ctx = oidc.ClientContext(ctx, p.httpClient)
+
+	tok, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response did not include an id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+
+	claims := map[string]any{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid id_token claims: %w", err)
+	}
+	if _, hasEmail := claims[p.cfg.Claims.Email]; !hasEmail && p.provider.UserInfoEndpoint() != "" {
+		if info, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(tok)); err == nil && info.Subject == idToken.Subject {
+			extra := map[string]any{}
+			if err := info.Claims(&extra); err == nil {
+				for k, v := range extra {
+					if _, exists := claims[k]; !exists {
+						claims[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	return p.mapClaims(claims)
+}
+
+// mapClaims builds a Profile according to the configured claim mapping.
+func (p *OIDCProvider) mapClaims(claims map[string]any) (*Profile, error) {
+	m := p.cfg.Claims
+	profile := &Profile{
+		Subject:       stringClaim(claims, m.Subject),
+		Email:         stringClaim(claims, m.Email),
+		EmailVerified: boolClaim(claims, m.EmailVerified),
+		Username:      stringClaim(claims, m.Username),
+		Name:          stringClaim(claims, m.Name),
+		AvatarURL:     stringClaim(claims, m.Avatar),
+	}
+	if profile.Subject == "" {
+		return nil, fmt.Errorf("id_token has no %q claim", m.Subject)
+	}
+	if profile.Email == "" {
+		return nil, fmt.Errorf("no %q claim available; request the email scope", m.Email)
+	}
+	return profile, nil
+}
+
+// discover fetches the issuer's metadata once. Failures are not cached, so a
+// temporarily unreachable issuer is retried on the next login.
+func (p *OIDCProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, p.httpClient), p.cfg.Issuer)
+	if err != nil {
+		return fmt.Errorf("oidc discovery for %s failed: %w", p.cfg.Issuer, err)
+	}
+	p.provider = provider
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return nil
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	switch v := claims[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprint(int64(v))
+	}
+	return ""
+}
+
+// boolClaim accepts both JSON booleans and "true"/"false" strings, which some
+// providers send for email_verified.
+func boolClaim(claims map[string]any, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
@@ -0,0 +1,305 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+// mockIssuer is a minimal OpenID Connect provider: discovery, JWKS, a token
+// endpoint that enforces PKCE, and userinfo.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// challenges maps issued authorization codes to their PKCE challenge.
+	challenges map[string]string
+	// idClaims are merged into every ID token; signingKey overrides the key.
+	idClaims   jwt.MapClaims
+	signingKey *rsa.PrivateKey
+	userinfo   map[string]any
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{t: t, key: key, challenges: map[string]string{}, idClaims: jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                m.server.URL,
+			"authorization_endpoint":                m.server.URL + "/authorize",
+			"token_endpoint":                        m.server.URL + "/token",
+			"jwks_uri":                              m.server.URL + "/jwks",
+			"userinfo_endpoint":                     m.server.URL + "/userinfo",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.handleToken)
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, m.userinfo)
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize simulates the user approving the login at authURL and returns the
+// authorization code.
+func (m *mockIssuer) authorize(authURL string) (code, nonce string) {
+	m.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		m.t.Fatalf("authorization URL is missing a PKCE challenge: %s", authURL)
+	}
+	code = "code-" + q.Get("state")
+	m.challenges[code] = q.Get("code_challenge")
+	return code, q.Get("nonce")
+}
+
+func (m *mockIssuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	code := r.PostForm.Get("code")
+	challenge, ok := m.challenges[code]
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		writeJSON(w, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss": m.server.URL,
+		"sub": "user-123",
+		"aud": "windgo",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range m.idClaims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	key := m.key
+	if m.signingKey != nil {
+		key = m.signingKey
+	}
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	writeJSON(w, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newTestProvider(t *testing.T, issuer *mockIssuer, claims ClaimMapping) *OIDCProvider {
+	t.Helper()
+	p, err := NewOIDCProvider(OIDCConfig{
+		Name:        "sso",
+		Issuer:      issuer.server.URL,
+		ClientID:    "windgo",
+		RedirectURL: "http://localhost:8080/api/auth/providers/sso/callback",
+		Claims:      claims,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// login runs the authorization code flow against the mock issuer. Unless a
+// test overrides it, the ID token echoes the nonce from the authorization URL.
+func login(t *testing.T, p *OIDCProvider, issuer *mockIssuer) (*Profile, error) {
+	t.Helper()
+	ctx := context.Background()
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, nonce := issuer.authorize(authURL)
+	if nonce != "nonce-1" {
+		t.Fatalf("nonce in authorization URL = %q, want nonce-1", nonce)
+	}
+	if _, set := issuer.idClaims["nonce"]; !set {
+		issuer.idClaims["nonce"] = nonce
+	}
+	return p.Exchange(ctx, code, "nonce-1", verifier)
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.idClaims["email"] = "ada@example.com"
+	issuer.idClaims["email_verified"] = true
+	issuer.idClaims["login"] = "ada"
+	issuer.idClaims["name"] = "Ada Lovelace"
+
+	p := newTestProvider(t, issuer, ClaimMapping{Username: "login"})
+	profile, err := login(t, p, issuer)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	want := Profile{
+		Subject:       "user-123",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Username:      "ada",
+		Name:          "Ada Lovelace",
+	}
+	if !reflect.DeepEqual(*profile, want) {
+		t.Errorf("profile = %+v, want %+v", *profile, want)
+	}
+}
+
+func TestOIDCProviderFallsBackToUserinfo(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.userinfo = map[string]any{
+		"sub":            "user-123",
+		"email":          "ada@example.com",
+		"email_verified": "true",
+	}
+
+	p := newTestProvider(t, issuer, ClaimMapping{})
+	profile, err := login(t, p, issuer)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if profile.Email != "ada@example.com" || !profile.EmailVerified {
+		t.Errorf("profile = %+v, want verified email from userinfo", *profile)
+	}
+}
+
+func TestOIDCProviderRejectsInvalidIDTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(*mockIssuer)
+	}{
+		{"wrong nonce", func(m *mockIssuer) { m.idClaims["nonce"] = "replayed" }},
+		{"wrong audience", func(m *mockIssuer) { m.idClaims["aud"] = "another-client" }},
+		{"wrong issuer", func(m *mockIssuer) { m.idClaims["iss"] = "https://evil.example.com" }},
+		{"expired", func(m *mockIssuer) { m.idClaims["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{"unknown signing key", func(m *mockIssuer) { m.signingKey = otherKey }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newMockIssuer(t)
+			issuer.idClaims["email"] = "ada@example.com"
+			tt.setup(issuer)
+
+			p := newTestProvider(t, issuer, ClaimMapping{})
+			if _, err := login(t, p, issuer); err == nil {
+				t.Fatal("expected login to fail")
+			}
+		})
+	}
+}
+
+func TestOIDCProviderRequiresPKCEVerifier(t *testing.T) {
+	issuer := newMockIssuer(t)
+	issuer.idClaims["email"] = "ada@example.com"
+	p := newTestProvider(t, issuer, ClaimMapping{})
+
+	ctx := context.Background()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", oauth2.GenerateVerifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := issuer.authorize(authURL)
+	if _, err := p.Exchange(ctx, code, "nonce-1", oauth2.GenerateVerifier()); err == nil {
+		t.Fatal("expected exchange with the wrong verifier to fail")
+	}
+}
+
+func TestLoadProvidersFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/providers.json"
+	file := `{"providers":[{"type":"oidc","name":"corp","display_name":"Corp SSO",
+		"issuer":"https://sso.example.com","client_id":"windgo","client_secret_env":"CORP_SECRET",
+		"redirect_url":"http://localhost:8080/api/auth/providers/corp/callback","claims":{"username":"upn"}}]}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_CLIENT_ID", "")
+	t.Setenv("OIDC_ISSUER", "")
+	t.Setenv("IDENTITY_PROVIDERS_FILE", path)
+	t.Setenv("CORP_SECRET", "s3cret")
+	t.Cleanup(Reset)
+
+	if err := LoadProviders(); err != nil {
+		t.Fatalf("LoadProviders: %v", err)
+	}
+	p, err := Get("corp")
+	if err != nil {
+		t.Fatalf("provider not registered: %v", err)
+	}
+	oidcProvider := p.(*OIDCProvider)
+	if oidcProvider.DisplayName() != "Corp SSO" || oidcProvider.cfg.ClientSecret != "s3cret" || oidcProvider.cfg.Claims.Username != "upn" {
+		t.Errorf("unexpected config: %+v", oidcProvider.cfg)
+	}
+	if len(All()) != 1 {
+		t.Errorf("All() = %d providers, want 1", len(All()))
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Replace(file, `"corp"`, `"github"`, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadProviders(); err == nil {
+		t.Error("expected the reserved name github to be rejected")
+	}
+}
@@ -0,0 +1,84 @@
+// Package identity defines pluggable external identity providers (GitHub,
+// generic OpenID Connect) used for social and single sign-on logins.
+package identity
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownProvider is returned when no provider is registered under a name.
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Profile is the user identity reported by a provider after a successful login.
+type Profile struct {
+	// Subject is the provider's stable, unique ID for the user.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Username is the preferred handle; it may be empty or already taken locally.
+	Username  string
+	Name      string
+	AvatarURL string
+	// Groups lists memberships used by sign-up policies, e.g. GitHub
+	// organizations ("org") and teams ("org/team"). Lowercase; may be empty.
+	Groups []string
+}
+
+// Provider is an external identity provider using the OAuth 2.0 authorization
+// code flow. Callers generate state, nonce and the PKCE verifier per login and
+// pass the same values to Exchange.
+type Provider interface {
+	// Name is the identifier used in routes, e.g. "github" or "sso".
+	Name() string
+	// DisplayName is a human-readable label for login buttons.
+	DisplayName() string
+	// AuthCodeURL returns the provider URL the browser should be sent to.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange redeems the authorization code and returns the user's profile.
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Profile, error)
+}
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{}
+)
+
+// Register makes a provider available under its name, replacing any existing one.
+func Register(p Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+// Reset removes all registered providers.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	providers = map[string]Provider{}
+}
+
+// Get returns the provider registered under name.
+func Get(name string) (Provider, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// All returns the registered providers sorted by name.
+func All() []Provider {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
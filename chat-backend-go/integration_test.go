@@ -0,0 +1,1086 @@
+package main
+
+import (
+	"bytes"
+	"chat-backend-go/apierror"
+	"chat-backend-go/health"
+	"chat-backend-go/identity"
+	"chat-backend-go/internal/testdb"
+	"chat-backend-go/logging"
+	"chat-backend-go/migrations"
+	"chat-backend-go/models"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// The cli* types mirror what cli/internal/api decodes. Every field without
+// omitempty must be present in the backend's reply, so renaming or dropping a
+// field the CLI relies on fails here. Keep them in sync with the CLI.
+
+type cliUser struct {
+	ID              uint       `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            string     `json:"role"`
+	Provider        string     `json:"provider"`
+	AvatarURL       string     `json:"avatar_url"`
+	LastActiveAt    *time.Time `json:"last_active_at"`
+	IsOnline        bool       `json:"is_online"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type cliAuthResponse struct {
+	Token             string  `json:"token"`
+	RefreshToken      string  `json:"refresh_token"`
+	ExpiresIn         int     `json:"expires_in"`
+	User              cliUser `json:"user"`
+	TwoFactorRequired bool    `json:"two_factor_required,omitempty"`
+	ChallengeToken    string  `json:"challenge_token,omitempty"`
+}
+
+type cliRoom struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type cliMessage struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	RoomID    uint      `json:"room_id"`
+	Content   string    `json:"content"`
+	User      cliUser   `json:"user"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type cliSession struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	Current    bool      `json:"current"`
+}
+
+type cliAPIError struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message"`
+	Details   map[string]any `json:"details"`
+	RequestID string         `json:"request_id"`
+}
+
+type cliRooms struct {
+	Rooms []cliRoom `json:"rooms"`
+}
+
+type cliUsers struct {
+	Users []cliUser `json:"users"`
+}
+
+type cliMessages struct {
+	Messages []cliMessage `json:"messages"`
+}
+
+type cliSentMessage struct {
+	Message string     `json:"message"`
+	Data    cliMessage `json:"data"`
+}
+
+type cliSessions struct {
+	Sessions []cliSession `json:"sessions"`
+}
+
+// apiCase is one request against the in-process server.
+type apiCase struct {
+	name   string
+	method string
+	path   string
+	token  string
+	body   any
+	status int
+	// code is the expected error code of a failure
+	code string
+	// shape points at the CLI type the reply must decode into
+	shape any
+	// check runs extra assertions on the decoded shape
+	check func(t *testing.T, shape any)
+}
+
+// newTestServer builds the full app, as serve does, on a fresh SQLite database.
+func newTestServer(t *testing.T) (*fiber.App, *gorm.DB) {
+	t.Helper()
+	t.Setenv("APP_ENV", "test")
+	t.Setenv("JWT_SECRET", "integration-test-secret")
+	t.Setenv("EMAIL_VERIFICATION", "off")
+	t.Setenv("SIGNUP_MODE", "")
+
+	db := testdb.New(t)
+	if err := utils.LoadSigningKeys(); err != nil {
+		t.Fatal(err)
+	}
+	ratelimit.SetDefault(ratelimit.NewMemoryStore())
+	health.SetDefault(health.NewRegistry())
+	registerHealthChecks(db)
+	return newApp(repository.NewGorm(db)), db
+}
+
+func runCases(t *testing.T, app *fiber.App, cases []apiCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body io.Reader
+			if tc.body != nil {
+				raw, err := json.Marshal(tc.body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = bytes.NewReader(raw)
+			}
+			req := httptest.NewRequest(tc.method, tc.path, body)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "windgo-cli")
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			raw, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.status {
+				t.Fatalf("%s %s: status %d, want %d: %s", tc.method, tc.path, resp.StatusCode, tc.status, raw)
+			}
+
+			shape := tc.shape
+			if shape == nil && tc.status >= 400 {
+				shape = &cliAPIError{}
+			}
+			if shape == nil {
+				return
+			}
+			assertShape(t, raw, shape)
+			if e, ok := shape.(*cliAPIError); ok && tc.code != "" && e.Code != tc.code {
+				t.Fatalf("%s %s: code %q, want %q", tc.method, tc.path, e.Code, tc.code)
+			}
+			if tc.check != nil {
+				tc.check(t, shape)
+			}
+		})
+	}
+}
+
+// assertShape decodes raw into shape, failing on type mismatches, and checks
+// that every non-omitempty field of shape is present in the JSON.
+func assertShape(t *testing.T, raw []byte, shape any) {
+	t.Helper()
+	if err := json.Unmarshal(raw, shape); err != nil {
+		t.Fatalf("reply does not decode into %T: %v: %s", shape, err, raw)
+	}
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if errs := missingFields("$", generic, reflect.TypeOf(shape).Elem()); len(errs) > 0 {
+		t.Fatalf("reply is missing fields the CLI decodes: %s\n%s", strings.Join(errs, ", "), raw)
+	}
+	if e, ok := shape.(*cliAPIError); ok && (e.Code == "" || e.Message == "" || e.RequestID == "") {
+		t.Fatalf("error reply without a code, message or request ID: %s", raw)
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func missingFields(path string, value any, typ reflect.Type) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var missing []string
+	switch typ.Kind() {
+	case reflect.Struct:
+		if typ == timeType {
+			return nil
+		}
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []string{path + " (not an object)"}
+		}
+		for i := 0; i < typ.NumField(); i++ {
+			name, opts, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+			field, present := object[name]
+			if !present {
+				if opts != "omitempty" {
+					missing = append(missing, path+"."+name)
+				}
+				continue
+			}
+			if field != nil {
+				missing = append(missing, missingFields(path+"."+name, field, typ.Field(i).Type)...)
+			}
+		}
+	case reflect.Slice:
+		items, ok := value.([]any)
+		if !ok {
+			return []string{path + " (not an array)"}
+		}
+		for i, item := range items {
+			missing = append(missing, missingFields(fmt.Sprintf("%s[%d]", path, i), item, typ.Elem())...)
+		}
+	}
+	return missing
+}
+
+func TestAPIIntegration(t *testing.T) {
+	app, db := newTestServer(t)
+
+	// Registration and login
+	var alice, bob, aliceLogin cliAuthResponse
+	runCases(t, app, []apiCase{
+		{
+			name: "register", method: "POST", path: "/api/auth/register",
+			body:   map[string]string{"username": "alice", "email": "alice@example.com", "password": "secret123"},
+			status: 201, shape: &alice,
+			check: func(t *testing.T, _ any) {
+				if alice.Token == "" || alice.RefreshToken == "" || alice.ExpiresIn <= 0 {
+					t.Fatalf("register issued no tokens: %+v", alice)
+				}
+				if alice.User.Username != "alice" || alice.User.Role != "user" {
+					t.Fatalf("register user = %+v", alice.User)
+				}
+			},
+		},
+		{
+			name: "register second user", method: "POST", path: "/api/auth/register",
+			body:   map[string]string{"username": "bob", "email": "bob@example.com", "password": "secret123"},
+			status: 201, shape: &bob,
+		},
+		{
+			name: "register duplicate email", method: "POST", path: "/api/auth/register",
+			body:   map[string]string{"username": "alice2", "email": "alice@example.com", "password": "secret123"},
+			status: 409, code: apierror.CodeUserExists,
+		},
+		{
+			name: "register invalid fields", method: "POST", path: "/api/auth/register",
+			body:   map[string]string{"username": "a b", "email": "not-an-email", "password": "x"},
+			status: 400, code: apierror.CodeValidationFailed,
+			check: func(t *testing.T, shape any) {
+				fields, _ := shape.(*cliAPIError).Details["fields"].([]any)
+				if len(fields) != 3 {
+					t.Fatalf("field errors = %v, want username, email and password", fields)
+				}
+			},
+		},
+		{
+			name: "register malformed body", method: "POST", path: "/api/auth/register",
+			body: "not an object", status: 400, code: apierror.CodeInvalidBody,
+		},
+		{
+			name: "device start malformed body", method: "POST", path: "/api/auth/github/device/start",
+			body: "not an object", status: 400, code: apierror.CodeInvalidBody,
+		},
+		{
+			name: "login", method: "POST", path: "/api/auth/login",
+			body:   map[string]string{"email": "alice@example.com", "password": "secret123"},
+			status: 200, shape: &aliceLogin,
+			check: func(t *testing.T, _ any) {
+				if aliceLogin.Token == "" || aliceLogin.TwoFactorRequired {
+					t.Fatalf("login = %+v", aliceLogin)
+				}
+			},
+		},
+		{
+			name: "login wrong password", method: "POST", path: "/api/auth/login",
+			body:   map[string]string{"email": "alice@example.com", "password": "wrong"},
+			status: 401, code: apierror.CodeInvalidCredentials,
+		},
+		{
+			name: "login unknown email", method: "POST", path: "/api/auth/login",
+			body:   map[string]string{"email": "nobody@example.com", "password": "secret123"},
+			status: 401, code: apierror.CodeInvalidCredentials,
+		},
+	})
+	if aliceLogin.Token == "" || bob.Token == "" {
+		t.Fatal("setup failed: no tokens")
+	}
+
+	room := &models.Room{Name: "general"}
+	if err := db.Create(room).Error; err != nil {
+		t.Fatal(err)
+	}
+	roomPath := fmt.Sprintf("/api/v1/rooms/%d", room.ID)
+
+	// Profile, rooms, messages and users
+	var profile cliUser
+	var rooms cliRooms
+	var sent cliSentMessage
+	var page1, page2 cliMessages
+	var users, search cliUsers
+	var sessions cliSessions
+	runCases(t, app, []apiCase{
+		{
+			name: "profile", method: "GET", path: "/api/auth/profile", token: aliceLogin.Token,
+			status: 200, shape: &profile,
+			check: func(t *testing.T, _ any) {
+				if profile.ID != alice.User.ID || profile.Email != "alice@example.com" {
+					t.Fatalf("profile = %+v", profile)
+				}
+			},
+		},
+		{name: "profile without token", method: "GET", path: "/api/auth/profile", status: 401, code: apierror.CodeMissingToken},
+		{name: "profile with bad token", method: "GET", path: "/api/auth/profile", token: "not-a-jwt", status: 401, code: apierror.CodeInvalidToken},
+		{name: "unknown endpoint", method: "GET", path: "/api/v1/nowhere", token: aliceLogin.Token, status: 404, code: apierror.CodeRouteNotFound},
+		{
+			name: "rooms", method: "GET", path: "/api/v1/rooms", token: aliceLogin.Token,
+			status: 200, shape: &rooms,
+			check: func(t *testing.T, _ any) {
+				if len(rooms.Rooms) != 1 || rooms.Rooms[0].Name != "general" {
+					t.Fatalf("rooms = %+v", rooms.Rooms)
+				}
+			},
+		},
+		{name: "rooms anonymously", method: "GET", path: "/api/v1/rooms", status: 200, shape: &cliRooms{}},
+		{
+			name: "send message", method: "POST", path: "/api/v1/messages", token: aliceLogin.Token,
+			body:   map[string]any{"room_id": room.ID, "content": "first"},
+			status: 201, shape: &sent,
+			check: func(t *testing.T, _ any) {
+				if sent.Data.Content != "first" || sent.Data.User.Username != "alice" || sent.Data.RoomID != room.ID {
+					t.Fatalf("sent = %+v", sent.Data)
+				}
+			},
+		},
+		{
+			name: "send more messages", method: "POST", path: "/api/v1/messages", token: bob.Token,
+			body:   map[string]any{"room_id": room.ID, "content": "second"},
+			status: 201, shape: &cliSentMessage{},
+		},
+		{
+			name: "send third message", method: "POST", path: "/api/v1/messages", token: aliceLogin.Token,
+			body:   map[string]any{"room_id": room.ID, "content": "third"},
+			status: 201, shape: &cliSentMessage{},
+		},
+		{
+			name: "send to missing room", method: "POST", path: "/api/v1/messages", token: aliceLogin.Token,
+			body: map[string]any{"room_id": 9999, "content": "hello"}, status: 404, code: apierror.CodeRoomNotFound,
+		},
+		{
+			name: "send without token", method: "POST", path: "/api/v1/messages",
+			body: map[string]any{"room_id": room.ID, "content": "hello"}, status: 401,
+		},
+		{
+			name: "messages first page", method: "GET", path: roomPath + "/messages?page=1&limit=2", token: aliceLogin.Token,
+			status: 200, shape: &page1,
+			check: func(t *testing.T, _ any) {
+				if len(page1.Messages) != 2 || page1.Messages[0].Content != "third" || page1.Messages[1].Content != "second" {
+					t.Fatalf("page 1 = %+v", page1.Messages)
+				}
+				if page1.Messages[1].User.Username != "bob" {
+					t.Fatalf("author not loaded: %+v", page1.Messages[1].User)
+				}
+			},
+		},
+		{
+			name: "messages second page", method: "GET", path: roomPath + "/messages?page=2&limit=2", token: aliceLogin.Token,
+			status: 200, shape: &page2,
+			check: func(t *testing.T, _ any) {
+				if len(page2.Messages) != 1 || page2.Messages[0].Content != "first" {
+					t.Fatalf("page 2 = %+v", page2.Messages)
+				}
+			},
+		},
+		{name: "messages of missing room", method: "GET", path: "/api/v1/rooms/9999/messages", token: aliceLogin.Token, status: 404, code: apierror.CodeRoomNotFound},
+		{name: "messages without token", method: "GET", path: roomPath + "/messages", status: 401},
+		{
+			name: "list users", method: "GET", path: "/api/v1/users", token: aliceLogin.Token,
+			status: 200, shape: &users,
+			check: func(t *testing.T, _ any) {
+				if len(users.Users) != 1 || users.Users[0].Username != "bob" {
+					t.Fatalf("users = %+v", users.Users)
+				}
+			},
+		},
+		{
+			name: "search users", method: "GET", path: "/api/v1/users?search=ALI", token: bob.Token,
+			status: 200, shape: &search,
+			check: func(t *testing.T, _ any) {
+				if len(search.Users) != 1 || search.Users[0].Username != "alice" {
+					t.Fatalf("search = %+v", search.Users)
+				}
+			},
+		},
+		{name: "users without token", method: "GET", path: "/api/v1/users", status: 401},
+		{
+			name: "sessions", method: "GET", path: "/api/auth/sessions", token: aliceLogin.Token,
+			status: 200, shape: &sessions,
+			check: func(t *testing.T, _ any) {
+				if len(sessions.Sessions) != 2 {
+					t.Fatalf("sessions = %+v", sessions.Sessions)
+				}
+			},
+		},
+	})
+
+	// Refresh rotates the pair; the spent refresh token is rejected
+	var refreshed cliAuthResponse
+	runCases(t, app, []apiCase{
+		{
+			name: "refresh", method: "POST", path: "/api/auth/refresh",
+			body:   map[string]string{"refresh_token": aliceLogin.RefreshToken},
+			status: 200, shape: &refreshed,
+			check: func(t *testing.T, _ any) {
+				if refreshed.Token == "" || refreshed.RefreshToken == "" || refreshed.RefreshToken == aliceLogin.RefreshToken {
+					t.Fatalf("refresh = %+v", refreshed)
+				}
+			},
+		},
+	})
+
+	// The refreshed access token replaces the old one
+	runCases(t, app, []apiCase{
+		{name: "refreshed access token", method: "GET", path: "/api/auth/sessions", token: refreshed.Token, status: 200},
+		{name: "replaced access token", method: "GET", path: "/api/auth/sessions", token: aliceLogin.Token, status: 401, code: apierror.CodeSessionRevoked},
+		{name: "refresh without token", method: "POST", path: "/api/auth/refresh", body: map[string]string{}, status: 400, code: apierror.CodeValidationFailed},
+		{name: "refresh with unknown token", method: "POST", path: "/api/auth/refresh", body: map[string]string{"refresh_token": "bogus"}, status: 401, code: apierror.CodeInvalidRefreshToken},
+		{name: "refresh token reuse", method: "POST", path: "/api/auth/refresh", body: map[string]string{"refresh_token": aliceLogin.RefreshToken}, status: 401, code: apierror.CodeRefreshTokenReused},
+	})
+
+	// Reusing a refresh token revokes the whole session
+	runCases(t, app, []apiCase{
+		{name: "rotated token revoked after reuse", method: "POST", path: "/api/auth/refresh", body: map[string]string{"refresh_token": refreshed.RefreshToken}, status: 401},
+		{name: "logout", method: "POST", path: "/api/auth/logout", body: map[string]string{"refresh_token": bob.RefreshToken}, status: 200},
+		{name: "refresh after logout", method: "POST", path: "/api/auth/refresh", body: map[string]string{"refresh_token": bob.RefreshToken}, status: 401},
+	})
+}
+
+// TestHealthProbes checks that liveness ignores dependencies while readiness
+// reports each registered check and fails when one of them does, and that
+// only admins see the checks' errors and details.
+func TestHealthProbes(t *testing.T) {
+	app, db := newTestServer(t)
+
+	var admin cliAuthResponse
+	runCases(t, app, []apiCase{
+		{name: "register", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "root", "email": "root@example.com", "password": "secret123"}, status: 201, shape: &admin},
+	})
+	if err := db.Model(&models.User{}).Where("id = ?", admin.User.ID).Update("role", "admin").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	probe := func(path, token string) (int, health.Report, string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		var report health.Report
+		if err := json.Unmarshal(body, &report); err != nil {
+			t.Fatalf("decode %s: %v", path, err)
+		}
+		return resp.StatusCode, report, string(body)
+	}
+
+	if status, report, _ := probe("/livez", ""); status != 200 || report.Status != health.StatusOK {
+		t.Fatalf("livez = %d %+v", status, report)
+	}
+	status, report, body := probe("/readyz", "")
+	if status != 200 || !report.OK() {
+		t.Fatalf("readyz = %d %+v", status, report)
+	}
+	for _, name := range []string{"database", "migrations"} {
+		if result, ok := report.Checks[name]; !ok || result.Status != health.StatusOK || result.LatencyMS < 0 {
+			t.Fatalf("check %s = %+v", name, result)
+		}
+	}
+	if strings.Contains(body, "details") || strings.Contains(body, "sqlite") {
+		t.Fatalf("public readyz exposes details: %s", body)
+	}
+	if status, report, _ = probe("/api/admin/health", admin.Token); status != 200 || report.Checks["database"].Details["driver"] != "sqlite" {
+		t.Fatalf("admin health = %d, database details = %v", status, report.Checks["database"].Details)
+	}
+	if status, _, _ = probe("/api/admin/health", ""); status != 401 {
+		t.Fatalf("admin health without a token = %d", status)
+	}
+
+	// A pending migration takes the instance out of rotation
+	if _, err := migrations.Down(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	status, report, body = probe("/readyz", "")
+	if status != 503 || report.Status != health.StatusUnavailable || report.Checks["migrations"].Status != health.StatusUnavailable {
+		t.Fatalf("readyz with pending migration = %d %+v", status, report)
+	}
+	if strings.Contains(body, "error") {
+		t.Fatalf("public readyz exposes the failure: %s", body)
+	}
+	if status, _, _ := probe("/livez", ""); status != 200 {
+		t.Fatalf("livez with pending migration = %d", status)
+	}
+}
+
+// TestAuditExportEscapesFormulas checks that the CSV export keeps
+// user-supplied values that start like a spreadsheet formula as text.
+func TestAuditExportEscapesFormulas(t *testing.T) {
+	app, db := newTestServer(t)
+
+	var admin cliAuthResponse
+	runCases(t, app, []apiCase{
+		{name: "register", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "root", "email": "root@example.com", "password": "secret123"}, status: 201, shape: &admin},
+	})
+	if err := db.Model(&models.User{}).Where("id = ?", admin.User.ID).Update("role", "admin").Error; err != nil {
+		t.Fatal(err)
+	}
+	entry := models.AuditLog{Action: "login_failed", ActorName: "@evil", TargetID: "-1", UserAgent: `=HYPERLINK("https://example.com")`}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/admin/audit-logs/export?action=login_failed", nil)
+	req.Header.Set("Authorization", "Bearer "+admin.Token)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	rows, err := csv.NewReader(resp.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || len(rows) != 2 {
+		t.Fatalf("export = %d, %d rows", resp.StatusCode, len(rows))
+	}
+	row := rows[1]
+	if row[4] != "'@evil" || row[6] != "'-1" || row[8] != `'=HYPERLINK("https://example.com")` {
+		t.Fatalf("export row = %q", row)
+	}
+}
+
+// totpNow computes the current RFC 6238 code for secret, as an authenticator app would.
+func totpNow(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+// TestTwoFactorChallenges checks that only password accounts can enroll and
+// that a login challenge completes one login and allows a bounded number of
+// wrong codes.
+func TestTwoFactorChallenges(t *testing.T) {
+	app, db := newTestServer(t)
+	// Isolate the per-challenge limit from the per-account lockout
+	t.Setenv("LOGIN_LOCKOUT_THRESHOLD", "0")
+
+	var carol, dave cliAuthResponse
+	var enrollment struct {
+		Secret string `json:"secret"`
+	}
+	runCases(t, app, []apiCase{
+		{name: "register", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "carol", "email": "carol@example.com", "password": "secret123"}, status: 201, shape: &carol},
+		{name: "register passwordless", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "dave", "email": "dave@example.com", "password": "secret123"}, status: 201, shape: &dave},
+	})
+	// dave stands in for an account created by an identity provider
+	if err := db.Model(&models.User{}).Where("id = ?", dave.User.ID).Update("has_password", false).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var recovery struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	runCases(t, app, []apiCase{
+		{name: "enroll without password", method: "POST", path: "/api/auth/2fa/enroll", token: dave.Token, status: 400, code: apierror.CodePasswordRequired},
+		{name: "enroll", method: "POST", path: "/api/auth/2fa/enroll", token: carol.Token, status: 200, shape: &enrollment},
+	})
+	runCases(t, app, []apiCase{
+		{
+			name: "confirm", method: "POST", path: "/api/auth/2fa/confirm", token: carol.Token,
+			body: map[string]string{"code": totpNow(t, enrollment.Secret)}, status: 200, shape: &recovery,
+		},
+	})
+	if len(recovery.RecoveryCodes) < 3 {
+		t.Fatalf("recovery codes = %v", recovery.RecoveryCodes)
+	}
+
+	login := func() string {
+		t.Helper()
+		var challenge struct {
+			TwoFactorRequired bool   `json:"two_factor_required"`
+			ChallengeToken    string `json:"challenge_token"`
+		}
+		runCases(t, app, []apiCase{
+			{name: "login", method: "POST", path: "/api/auth/login", body: map[string]string{"email": "carol@example.com", "password": "secret123"}, status: 200, shape: &challenge},
+		})
+		if !challenge.TwoFactorRequired || challenge.ChallengeToken == "" {
+			t.Fatalf("login = %+v, want a challenge", challenge)
+		}
+		return challenge.ChallengeToken
+	}
+	verify := func(name, challenge, recoveryCode string, status int, code string) apiCase {
+		return apiCase{
+			name: name, method: "POST", path: "/api/auth/2fa/verify",
+			body:   map[string]string{"challenge_token": challenge, "recovery_code": recoveryCode},
+			status: status, code: code,
+		}
+	}
+
+	// A challenge completes one login
+	challenge := login()
+	runCases(t, app, []apiCase{
+		verify("verify", challenge, recovery.RecoveryCodes[0], 200, ""),
+		verify("reuse completed challenge", challenge, recovery.RecoveryCodes[1], 401, apierror.CodeInvalidChallenge),
+	})
+	// A request that loses the claim to a concurrent one doesn't spend its
+	// recovery code
+	challenge = login()
+	store := ratelimit.Default()
+	ratelimit.SetDefault(lostClaimStore{store})
+	runCases(t, app, []apiCase{
+		verify("claimed concurrently", challenge, recovery.RecoveryCodes[1], 401, apierror.CodeInvalidChallenge),
+	})
+	ratelimit.SetDefault(store)
+	runCases(t, app, []apiCase{
+		verify("unspent recovery code", login(), recovery.RecoveryCodes[1], 200, ""),
+	})
+
+	// Wrong codes use the challenge up, after which even a right code fails
+	challenge = login()
+	var cases []apiCase
+	for i := range 5 {
+		cases = append(cases, verify(fmt.Sprintf("wrong code %d", i+1), challenge, "wrong-code", 401, apierror.CodeInvalidTwoFactorCode))
+	}
+	cases = append(cases, verify("exhausted challenge", challenge, recovery.RecoveryCodes[2], 401, apierror.CodeInvalidChallenge))
+	runCases(t, app, cases)
+}
+
+// lostClaimStore behaves as if another request claimed every login challenge
+// between the spent check and the claim.
+type lostClaimStore struct {
+	ratelimit.Store
+}
+
+func (s lostClaimStore) CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error) {
+	if strings.HasPrefix(key, "2fa_challenge_used:") {
+		return false, nil
+	}
+	return s.Store.CompareAndSwap(ctx, key, old, new, ttl)
+}
+
+// TestResendVerificationLimits checks that verification emails are limited
+// per address, whether or not it has an account, and per client IP.
+func TestResendVerificationLimits(t *testing.T) {
+	app, _ := newTestServer(t)
+
+	resend := func(name, email string, status int) apiCase {
+		c := apiCase{name: name, method: "POST", path: "/api/auth/email/resend", body: map[string]string{"email": email}, status: status}
+		if status == 429 {
+			c.code = apierror.CodeRateLimited
+		}
+		return c
+	}
+	runCases(t, app, []apiCase{
+		resend("first", "nobody@example.com", 200),
+		resend("second", "nobody@example.com", 200),
+		resend("third", "NOBODY@example.com", 200),
+		resend("address limit", "nobody@example.com", 429),
+		resend("other address", "someone@example.com", 200),
+		resend("IP limit", "else@example.com", 429),
+	})
+}
+
+// fakeProvider is an identity provider that signs everyone in as profile.
+type fakeProvider struct {
+	profile identity.Profile
+}
+
+func (fakeProvider) Name() string        { return "fake" }
+func (fakeProvider) DisplayName() string { return "Fake" }
+
+func (fakeProvider) AuthCodeURL(_ context.Context, state, _, _ string) (string, error) {
+	return "https://idp.example/authorize?state=" + url.QueryEscape(state), nil
+}
+
+func (p fakeProvider) Exchange(context.Context, string, string, string) (*identity.Profile, error) {
+	profile := p.profile
+	return &profile, nil
+}
+
+// TestProviderLinkState checks that linking is held on the server and bound
+// to the session that started it: the callback takes the account from there,
+// not from anything the browser sends, and only once.
+func TestProviderLinkState(t *testing.T) {
+	app, db := newTestServer(t)
+	identity.Register(fakeProvider{profile: identity.Profile{Subject: "mallory-42", Email: "mallory@idp.example", Username: "mallory"}})
+	t.Cleanup(identity.Reset)
+
+	var mallory cliAuthResponse
+	runCases(t, app, []apiCase{
+		{name: "register", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "mallory", "email": "mallory@example.com", "password": "secret123"}, status: 201, shape: &mallory},
+	})
+
+	// startLink starts linking and returns the state in the authorization URL
+	startLink := func(token string) string {
+		t.Helper()
+		var link struct {
+			LoginURL string `json:"login_url"`
+		}
+		runCases(t, app, []apiCase{
+			{name: "start linking", method: "POST", path: "/api/auth/identities/fake/link", token: token, status: 200, shape: &link},
+		})
+		authURL, err := url.Parse(link.LoginURL)
+		if err != nil || authURL.Host != "idp.example" || strings.Contains(link.LoginURL, token) {
+			t.Fatalf("login_url = %q", link.LoginURL)
+		}
+		return authURL.Query().Get("state")
+	}
+	// callback returns from the provider as a browser without a state cookie would
+	callback := func(state string) (int, string) {
+		t.Helper()
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/auth/providers/fake/callback?code=code&state="+url.QueryEscape(state), nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body cliAPIError
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Code
+	}
+	linkedTo := func() uint {
+		t.Helper()
+		var linked models.UserIdentity
+		if err := db.Where("provider = ? AND subject = ?", "fake", "mallory-42").First(&linked).Error; err != nil {
+			return 0
+		}
+		return linked.UserID
+	}
+
+	// A link whose session has since signed out is refused
+	state := startLink(mallory.Token)
+	runCases(t, app, []apiCase{
+		{name: "logout", method: "POST", path: "/api/auth/logout", body: map[string]string{"refresh_token": mallory.RefreshToken}, status: 200},
+	})
+	if status, code := callback(state); status != 401 || code != apierror.CodeInvalidLinkToken {
+		t.Fatalf("link after logout = %d %s", status, code)
+	}
+	if owner := linkedTo(); owner != 0 {
+		t.Fatalf("link of an ended session linked the identity to user %d", owner)
+	}
+
+	// A live session's link completes once
+	runCases(t, app, []apiCase{
+		{name: "login", method: "POST", path: "/api/auth/login", body: map[string]string{"email": "mallory@example.com", "password": "secret123"}, status: 200, shape: &mallory},
+	})
+	state = startLink(mallory.Token)
+	if status, code := callback(state); status != 200 {
+		t.Fatalf("genuine link = %d %s", status, code)
+	}
+	if owner := linkedTo(); owner != mallory.User.ID {
+		t.Fatalf("identity linked to user %d, want %d", owner, mallory.User.ID)
+	}
+	if status, code := callback(state); status != 400 || code != apierror.CodeInvalidOAuthState {
+		t.Fatalf("replayed link = %d %s", status, code)
+	}
+}
+
+// providerRoundTrip opens loginURL, then calls the provider callback with the
+// state cookie it set, as a browser returning from the provider would. It
+// returns the callback's status and body and the decoded state cookie.
+func providerRoundTrip(t *testing.T, app *fiber.App, loginURL string) (int, []byte, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", loginURL, nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	var cookie string
+	for _, c := range resp.Cookies() {
+		if c.Name == "oauth_state" {
+			cookie = c.Value
+		}
+	}
+	raw, _ := base64.RawURLEncoding.DecodeString(cookie)
+	var saved struct {
+		State string `json:"s"`
+	}
+	if resp.StatusCode != 302 || json.Unmarshal(raw, &saved) != nil {
+		t.Fatalf("provider login = %d with state cookie %q", resp.StatusCode, raw)
+	}
+
+	req := httptest.NewRequest("GET", "/api/auth/providers/fake/callback?code=code&state="+url.QueryEscape(saved.State), nil)
+	req.Header.Set("Cookie", "oauth_state="+cookie)
+	resp, err = app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, string(raw)
+}
+
+// TestProviderSignupUsernames checks that accounts created from provider
+// profiles get usernames that satisfy the local username rule.
+func TestProviderSignupUsernames(t *testing.T) {
+	app, _ := newTestServer(t)
+	t.Cleanup(identity.Reset)
+
+	for _, tc := range []struct {
+		username, email, want string
+	}{
+		{"Mona Lisa!", "mona@idp.example", "Mona_Lisa_"},
+		{"Mona Lisa!", "mona2@idp.example", "Mona_Lisa_1"},
+		{"", "jo+chat@idp.example", "jo_chat"},
+		{"", "x@idp.example", "user_x"},
+	} {
+		identity.Register(fakeProvider{profile: identity.Profile{Subject: tc.email, Email: tc.email, EmailVerified: true, Username: tc.username}})
+		status, body, _ := providerRoundTrip(t, app, "/api/auth/providers/fake/login")
+		var login cliAuthResponse
+		if status != 200 || json.Unmarshal(body, &login) != nil {
+			t.Fatalf("sign-up as %q: %d %s", tc.username, status, body)
+		}
+		if login.User.Username != tc.want {
+			t.Errorf("sign-up as %q <%s>: username %q, want %q", tc.username, tc.email, login.User.Username, tc.want)
+		}
+	}
+}
+
+// TestMetrics checks that /metrics reports requests by route template and
+// counts sign-ins.
+func TestMetrics(t *testing.T) {
+	app, _ := newTestServer(t)
+
+	runCases(t, app, []apiCase{
+		{name: "register", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "metrics", "email": "metrics@example.com", "password": "secret123"}, status: 201},
+		{name: "wrong password", method: "POST", path: "/api/auth/login", body: map[string]string{"email": "metrics@example.com", "password": "wrong-password"}, status: 401},
+		{name: "messages of missing room", method: "GET", path: "/api/v1/rooms/4242/messages", status: 401},
+		{name: "unknown endpoint", method: "GET", path: "/nowhere/42", status: 404},
+	})
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d: %s", resp.StatusCode, raw)
+	}
+	for _, want := range []string{
+		`windgo_http_requests_total{method="POST",route="/api/auth/register",status="201"}`,
+		`windgo_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`windgo_http_request_duration_seconds_bucket{method="POST",route="/api/auth/login",status="401",le="+Inf"}`,
+		`windgo_logins_total{provider="password",result="failure"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+	if strings.Contains(string(raw), "/4242/") || strings.Contains(string(raw), "/nowhere/") {
+		t.Error("metrics are labelled with raw paths instead of route templates")
+	}
+}
+
+// TestRequestQueriesAreTraced checks that the queries made while serving a
+// request, from authentication through the handler, are spans of its trace.
+func TestRequestQueriesAreTraced(t *testing.T) {
+	// The test database has the GORM tracing plugin, like the real one
+	app, _ := newTestServer(t)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	var erin cliAuthResponse
+	runCases(t, app, []apiCase{
+		{name: "register", method: "POST", path: "/api/auth/register", body: map[string]string{"username": "erin", "email": "erin@example.com", "password": "secret123"}, status: 201, shape: &erin},
+	})
+	exporter.Reset()
+
+	req := httptest.NewRequest("GET", "/api/auth/sessions", nil)
+	req.Header.Set("Authorization", "Bearer "+erin.Token)
+	if resp, err := app.Test(req, -1); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("list sessions = %v, %v", resp, err)
+	}
+
+	var server trace.SpanContext
+	var names []string
+	queries := 0
+	for _, span := range exporter.GetSpans() {
+		names = append(names, span.Name)
+		if span.SpanKind == trace.SpanKindServer {
+			server = span.SpanContext
+		}
+	}
+	for _, span := range exporter.GetSpans() {
+		if span.Name != "select sessions" {
+			continue
+		}
+		queries++
+		if span.SpanContext.TraceID() != server.TraceID() {
+			t.Errorf("%s is not part of the request's trace", span.Name)
+		}
+	}
+	// One query validates the token's session, the other lists the sessions
+	if !server.IsValid() || queries < 2 {
+		t.Fatalf("request recorded %d session queries; spans: %v", queries, names)
+	}
+}
+
+// TestRequestIDs checks that request IDs are accepted from well-behaved
+// clients, generated otherwise, and attached to the request's log records.
+func TestRequestIDs(t *testing.T) {
+	app, _ := newTestServer(t)
+
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(slog.NewJSONHandler(&logs, nil))))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	for _, tc := range []struct {
+		name, sent string
+		kept       bool
+	}{
+		{"client ID", "cli-7f3a.2", true},
+		{"no ID", "", false},
+		{"forged log line", "x\n{\"level\":\"ERROR\"}", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("GET", "/api/v1/rooms", nil)
+			if tc.sent != "" {
+				req.Header.Set("X-Request-ID", tc.sent)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			id := resp.Header.Get("X-Request-ID")
+			if id == "" || (id == tc.sent) != tc.kept {
+				t.Fatalf("X-Request-ID = %q for %q", id, tc.sent)
+			}
+			var record struct {
+				Msg       string `json:"msg"`
+				RequestID string `json:"request_id"`
+				Status    int    `json:"status"`
+			}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("decode log %q: %v", logs.String(), err)
+			}
+			if record.Msg != "request" || record.RequestID != id || record.Status != resp.StatusCode {
+				t.Fatalf("log record = %+v, want request %s with status %d", record, id, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// TestOpenAPIDocumentsEveryRoute keeps openapi/openapi.json in step with the
+// routes: every registered endpoint must be documented and vice versa.
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	app, _ := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/openapi.json", nil)
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Fatalf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+
+	documented := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	routed := map[string]bool{}
+	for _, r := range app.GetRoutes(true) {
+		if r.Method == fiber.MethodHead {
+			continue
+		}
+		path := r.Path
+		if len(path) > 1 {
+			path = strings.TrimSuffix(path, "/")
+		}
+		segments := strings.Split(path, "/")
+		for i, s := range segments {
+			if strings.HasPrefix(s, ":") {
+				segments[i] = "{" + strings.TrimPrefix(s, ":") + "}"
+			}
+		}
+		routed[r.Method+" "+strings.Join(segments, "/")] = true
+	}
+
+	for route := range routed {
+		if !documented[route] {
+			t.Errorf("%s is not documented in openapi/openapi.json", route)
+		}
+	}
+	for route := range documented {
+		if !routed[route] {
+			t.Errorf("%s is documented but not routed", route)
+		}
+	}
+}
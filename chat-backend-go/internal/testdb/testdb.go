@@ -0,0 +1,38 @@
+// Package testdb opens throwaway SQLite databases with the full schema
+// applied, for tests that exercise handlers and repositories.
+package testdb
+
+import (
+	"chat-backend-go/config"
+	"chat-backend-go/migrations"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// New returns a migrated in-memory SQLite database and installs it as
+// config.DB for code that still uses the global. The database is closed when
+// the test ends.
+func New(t testing.TB) *gorm.DB {
+	t.Helper()
+
+	db, err := config.OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.Logger = logger.Discard
+	if _, err := migrations.Up(db, 0); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
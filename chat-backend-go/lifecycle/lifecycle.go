@@ -0,0 +1,142 @@
+// Package lifecycle coordinates an orderly shutdown. Subsystems register a
+// shutdown hook when they start, and fire-and-forget work runs through Go so
+// that it is waited for instead of being killed mid-write when the process exits.
+//
+// Hooks run in reverse registration order, so something started later (the HTTP
+// server) stops before what it depends on (the database).
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Hook stops one subsystem. It should return once the subsystem is stopped or
+// ctx expires, whichever comes first.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	stop Hook
+}
+
+// Manager tracks shutdown hooks and background goroutines.
+type Manager struct {
+	mu       sync.Mutex
+	hooks    []namedHook
+	draining bool
+	shutdown bool
+	workers  sync.WaitGroup
+	running  atomic.Int64
+}
+
+// New returns an empty manager.
+func New() *Manager {
+	return &Manager{}
+}
+
+// OnShutdown registers stop to run during Shutdown. Hooks registered after
+// Shutdown has started are ignored.
+func (m *Manager) OnShutdown(name string, stop Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shutdown {
+		return
+	}
+	m.hooks = append(m.hooks, namedHook{name: name, stop: stop})
+}
+
+// Go runs fn in a tracked goroutine. Once the manager is draining its workers,
+// new work is refused and Go reports false; callers treat that as best-effort
+// work that was skipped.
+func (m *Manager) Go(name string, fn func()) bool {
+	m.mu.Lock()
+	if m.draining {
+		m.mu.Unlock()
+		return false
+	}
+	m.workers.Add(1)
+	m.running.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.workers.Done()
+		defer m.running.Add(-1)
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("Lifecycle: background task panicked", "task", name, "panic", r)
+			}
+		}()
+		fn()
+	}()
+	return true
+}
+
+// Wait refuses new background work and waits for the running goroutines, up
+// to ctx's deadline. Register it as a hook between the producers of work and
+// the resources that work uses.
+func (m *Manager) Wait(ctx context.Context) error {
+	m.mu.Lock()
+	m.draining = true
+	m.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d background tasks still running: %w", m.running.Load(), ctx.Err())
+	}
+}
+
+// Shutdown runs the registered hooks in reverse order. A hook that fails or
+// times out doesn't stop the rest; all errors are returned together.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	if m.shutdown {
+		m.mu.Unlock()
+		return errors.New("lifecycle: shutdown already started")
+	}
+	m.shutdown = true
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		started := time.Now()
+		if err := hook.stop(ctx); err != nil {
+			slog.Error("Lifecycle: stopping failed", "hook", hook.name, "elapsed", time.Since(started).Round(time.Millisecond), "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", hook.name, err))
+			continue
+		}
+		slog.Info("Lifecycle: stopped", "hook", hook.name, "elapsed", time.Since(started).Round(time.Millisecond))
+	}
+	return errors.Join(errs...)
+}
+
+var defaultManager = New()
+
+// Default returns the process-wide manager.
+func Default() *Manager {
+	return defaultManager
+}
+
+// OnShutdown registers a hook with the default manager.
+func OnShutdown(name string, stop Hook) {
+	defaultManager.OnShutdown(name, stop)
+}
+
+// Go runs fn as tracked background work on the default manager.
+func Go(name string, fn func()) bool {
+	return defaultManager.Go(name, fn)
+}
@@ -0,0 +1,66 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdownRunsHooksInReverseOrder(t *testing.T) {
+	m := New()
+	var order []string
+	for _, name := range []string{"database", "workers", "server"} {
+		m.OnShutdown(name, func(context.Context) error {
+			order = append(order, name)
+			return nil
+		})
+	}
+	failing := errors.New("boom")
+	m.OnShutdown("broken", func(context.Context) error { return failing })
+
+	err := m.Shutdown(context.Background())
+	if !errors.Is(err, failing) {
+		t.Fatalf("Shutdown error = %v, want the failing hook's error", err)
+	}
+	if want := []string{"server", "workers", "database"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("hooks ran in order %v, want %v", order, want)
+	}
+	if err := m.Shutdown(context.Background()); err == nil {
+		t.Fatal("second Shutdown succeeded")
+	}
+}
+
+func TestWaitDrainsBackgroundWork(t *testing.T) {
+	m := New()
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	if !m.Go("slow", func() {
+		<-release
+		close(finished)
+	}) {
+		t.Fatal("Go refused work before shutdown")
+	}
+	m.Go("panics", func() { panic("recovered") })
+
+	// The deadline passes while the slow task is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := m.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want deadline exceeded", err)
+	}
+	if m.Go("late", func() { t.Error("work started after Wait") }) {
+		t.Fatal("Go accepted work after Wait")
+	}
+
+	close(release)
+	if err := m.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Wait returned before the task finished")
+	}
+}
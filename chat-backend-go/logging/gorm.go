@@ -0,0 +1,89 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is the duration above which a query is logged at warn level.
+const slowQuery = 200 * time.Millisecond
+
+// Gorm returns a GORM logger that writes through slog with the query's
+// context, so database logs carry the request ID. Failed queries are logged at
+// error level, slow ones at warn, and every query at debug. Queries are logged
+// with placeholders rather than values, and missing rows are not errors.
+func Gorm() gormlogger.Interface {
+	return gormLog{level: gormlogger.Info}
+}
+
+type gormLog struct {
+	level gormlogger.LogLevel
+}
+
+func (l gormLog) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return gormLog{level: level}
+}
+
+func (l gormLog) Info(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Info {
+		slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLog) Warn(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Warn {
+		slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLog) Error(ctx context.Context, msg string, args ...any) {
+	if l.level >= gormlogger.Error {
+		slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l gormLog) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	level, msg := slog.LevelDebug, "Database query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		level, msg = slog.LevelError, "Database query failed"
+	case elapsed > slowQuery && l.level >= gormlogger.Warn:
+		level, msg = slog.LevelWarn, "Slow database query"
+	case l.level < gormlogger.Info:
+		return
+	}
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("elapsed_ms", milliseconds(elapsed)),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	slog.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter drops bound values, so logged SQL keeps its placeholders.
+func (l gormLog) ParamsFilter(_ context.Context, sql string, _ ...any) (string, []any) {
+	return sql, nil
+}
+
+// milliseconds converts d to fractional milliseconds for log attributes.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
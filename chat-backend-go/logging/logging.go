@@ -0,0 +1,110 @@
+// Package logging configures the process-wide structured logger (log/slog).
+// Records are written as JSON by default, carry the IDs of the request and
+// trace they were logged for, and have tokens, passwords and email addresses redacted
+// before they reach the output. Code logs through slog with a request's
+// context (slog.InfoContext(c.UserContext(), ...)); the standard log package
+// is routed through the same handler.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Setup installs the default logger, writing to w at LOG_LEVEL (debug, info,
+// warn or error; default info) in LOG_FORMAT (json or text; default json).
+func Setup(w io.Writer) {
+	level, err := ParseLevel(os.Getenv("LOG_LEVEL"))
+	options := &slog.HandlerOptions{Level: level}
+
+	var base slog.Handler
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "text") {
+		base = slog.NewTextHandler(w, options)
+	} else {
+		base = slog.NewJSONHandler(w, options)
+	}
+	slog.SetDefault(slog.New(NewHandler(base)))
+
+	if err != nil {
+		slog.Warn("Ignoring LOG_LEVEL", "error", err)
+	}
+}
+
+// ParseLevel parses a level name. An empty name is info.
+func ParseLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unknown log level %q (use debug, info, warn or error)", name)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID, which every
+// record logged with that context includes.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Handler wraps another slog.Handler, redacting every record and adding the
+// request ID and the trace and span IDs from the record's context.
+type Handler struct {
+	next slog.Handler
+}
+
+// NewHandler returns a redacting handler that writes to next.
+func NewHandler(next slog.Handler) *Handler {
+	return &Handler{next: next}
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			out.AddAttrs(slog.String("request_id", id))
+		}
+		if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+			out.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+		}
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, out)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name)}
+}
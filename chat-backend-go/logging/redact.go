@@ -0,0 +1,87 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces sensitive values in log output.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are substrings of attribute keys whose values are always
+// redacted, whatever they look like.
+var sensitiveKeys = []string{
+	"password", "passwd", "secret", "token", "authorization", "cookie",
+	"email", "api_key", "apikey", "verifier", "recovery_code",
+}
+
+// sensitiveValues find secrets inside free text such as messages and errors.
+var sensitiveValues = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	// JWTs
+	{regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), Redacted},
+	// Personal access tokens (utils.PersonalAccessTokenPrefix)
+	{regexp.MustCompile(`wgp_[A-Za-z0-9_-]+`), Redacted},
+	// Authorization header values
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`), "$1 " + Redacted},
+	// key=value pairs in query strings and form bodies
+	{regexp.MustCompile(`(?i)\b((?:[a-z_]*_)?(?:token|password|secret|code|verifier))=[^&\s"']+`), "$1=" + Redacted},
+	// "key": "value" pairs in JSON
+	{regexp.MustCompile(`(?i)"((?:[a-z_]*_)?(?:token|password|secret|code|verifier))"\s*:\s*"[^"]*"`), `"$1":"` + Redacted + `"`},
+	// Email addresses
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`), Redacted},
+}
+
+// Redact removes tokens, credentials and email addresses from s.
+func Redact(s string) string {
+	for _, v := range sensitiveValues {
+		s = v.pattern.ReplaceAllString(s, v.replace)
+	}
+	return s
+}
+
+// sensitiveKey reports whether values logged under key are always redacted.
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range sensitiveKeys {
+		if strings.Contains(key, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr redacts a by key, then by value, descending into groups. It also
+// writes durations as text ("1.5s") rather than as nanoseconds.
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		redacted := make([]any, len(attrs))
+		for i, attr := range attrs {
+			redacted[i] = redactAttr(attr)
+		}
+		return slog.Group(a.Key, redacted...)
+	}
+	if sensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindDuration:
+		return slog.String(a.Key, a.Value.Duration().String())
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(v.Error()))
+		case []byte:
+			return slog.String(a.Key, Redact(string(v)))
+		}
+	}
+	return a
+}
@@ -0,0 +1,40 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer is a development mailer that writes messages to a file, or to
+// standard error when Path is empty, instead of delivering them. Messages
+// bypass the structured logger so their links and tokens stay readable.
+type LogMailer struct {
+	Path string
+	From string
+
+	mu sync.Mutex
+}
+
+// Send records msg in the configured file or on standard error.
+func (m *LogMailer) Send(_ context.Context, msg Message) error {
+	entry := fmt.Sprintf("----- %s -----\nFrom: %s\nTo: %s\nSubject: %s\n\n%s\n",
+		time.Now().Format(time.RFC3339), m.From, msg.To, msg.Subject, msg.Body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Path == "" {
+		_, err := fmt.Fprint(os.Stderr, entry)
+		return err
+	}
+
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(entry)
+	return err
+}
@@ -0,0 +1,74 @@
+// Package mailer delivers transactional email such as password reset links.
+// This file defines the Mailer interface and selects an implementation from
+// environment variables so handlers never depend on a concrete transport.
+package mailer
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Message is a plain-text email addressed to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	defaultMu     sync.Mutex
+	defaultMailer Mailer
+)
+
+// Default returns the process-wide mailer, building it from the environment on first use.
+func Default() Mailer {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultMailer == nil {
+		defaultMailer = FromEnv()
+	}
+	return defaultMailer
+}
+
+// SetDefault overrides the process-wide mailer (useful for tests and custom transports).
+func SetDefault(m Mailer) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultMailer = m
+}
+
+// FromEnv builds a mailer from MAIL_DRIVER ("smtp" or "log", default "log").
+func FromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "WindGo Chat <no-reply@windgo.local>"
+	}
+
+	switch strings.ToLower(os.Getenv("MAIL_DRIVER")) {
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			host = "localhost"
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "1025"
+		}
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	default:
+		return &LogMailer{Path: os.Getenv("MAIL_LOG_FILE"), From: from}
+	}
+}
@@ -1,42 +1,229 @@
 package main
 
 import (
+	"chat-backend-go/apierror"
 	"chat-backend-go/config"
+	"chat-backend-go/handlers"
+	"chat-backend-go/health"
+	"chat-backend-go/identity"
+	"chat-backend-go/lifecycle"
+	"chat-backend-go/logging"
+	"chat-backend-go/metrics"
+	"chat-backend-go/middleware"
+	"chat-backend-go/models"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/repository"
 	"chat-backend-go/routes"
+	"chat-backend-go/tracing"
 	"chat-backend-go/utils"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+	"gorm.io/gorm"
 )
 
 func main() {
-	// Initialize database
+	// Read .env first so it can configure logging: structured JSON logs on
+	// stderr (LOG_LEVEL, LOG_FORMAT)
+	envErr := config.LoadEnv()
+	logging.Setup(os.Stderr)
+	if envErr != nil {
+		slog.Debug("No .env file found")
+	}
+
+	command := "serve"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "serve":
+		serve()
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "seed":
+		runSeed(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+const usage = `Usage: chat-backend-go [command]
+
+Commands:
+  serve                      run the API server (default)
+  migrate up [N]             apply all (or the next N) pending migrations
+  migrate down [N]           revert the last N migrations (default 1)
+  migrate status             list migrations and whether they are applied
+  migrate create NAME        add empty up/down files to each ./migrations/<dialect>
+  seed [-force]              create the demo users and rooms`
+
+// serve runs the API server.
+func serve() {
+	// Export traces (OTEL_TRACES_EXPORTER); the exporter is flushed last, once
+	// everything that records spans has stopped
+	stopTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
+	}
+	lifecycle.OnShutdown("tracing", stopTracing)
+
+	// Initialize database and bring the schema up to date. Shutdown hooks run
+	// in reverse order: the server stops first, then background work, then the database.
 	config.ConnectDB()
+	lifecycle.OnShutdown("database", func(context.Context) error {
+		return config.CloseDB()
+	})
+	lifecycle.OnShutdown("background tasks", lifecycle.Default().Wait)
+	ensureSchema()
+	registerHealthChecks(config.DB)
+	registerDBMetrics(config.DB)
+
+	// Load JWT signing keys (refuses to start without one in production)
+	if err := utils.LoadSigningKeys(); err != nil {
+		fatal("Failed to load JWT signing keys", "error", err)
+	}
+
+	// Register GitHub and OpenID Connect login providers
+	if err := identity.LoadProviders(); err != nil {
+		fatal("Failed to configure identity providers", "error", err)
+	}
 
-	// Seed demo users and rooms
-	utils.SeedDemoUsers()
-	utils.SeedDemoRooms()
+	// Rate limiter counters (memory or shared database store)
+	ratelimit.Configure(config.DB)
 
 	// Create Fiber app
-	app := fiber.New()
+	app := newApp(repository.NewGorm(config.DB))
 
-	// Logger middleware for debugging
-	app.Use(logger.New(logger.Config{
-		Format: "[${ip}]:${port} ${status} - ${method} ${path}\n",
-	}))
+	// Read port from environment (default 8080)
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	serveMetrics()
+	lifecycle.OnShutdown("http server", app.ShutdownWithContext)
 
-	// CORS middleware
-	corsOrigin := os.Getenv("CORS_ORIGIN")
-	if corsOrigin == "" {
-		corsOrigin = "http://localhost:3000"
+	// Stop on SIGINT/SIGTERM; a second signal kills the process immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "port", port, "cors_origin", corsOrigin())
+		listenErr <- app.Listen(":" + port)
+	}()
+	select {
+	case err := <-listenErr:
+		fatal("Server failed", "error", err)
+	case <-ctx.Done():
+	}
+	stop()
+
+	// Stop accepting connections, let in-flight requests finish, wait for
+	// background work and close the database, all within the deadline
+	timeout := shutdownTimeout()
+	slog.Info("Shutting down, waiting for in-flight work", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := lifecycle.Default().Shutdown(shutdownCtx); err != nil {
+		fatal("Shutdown incomplete", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// fatal logs msg at error level and exits with status 1.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// shutdownTimeout is how long a graceful shutdown may take (SHUTDOWN_TIMEOUT,
+// default 15s) before remaining connections and work are abandoned.
+func shutdownTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("SHUTDOWN_TIMEOUT")); err == nil && d > 0 {
+		return d
 	}
+	return 15 * time.Second
+}
+
+// registerHealthChecks adds the database checks to the readiness probe.
+func registerHealthChecks(db *gorm.DB) {
+	health.Register("database", health.Database(db))
+	health.Register("migrations", health.Migrations(db))
+}
+
+// registerDBMetrics exports the connection pool statistics and the number of
+// active sessions.
+func registerDBMetrics(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		metrics.RegisterDB(sqlDB, db.Dialector.Name())
+	}
+	metrics.RegisterActiveSessions(func(ctx context.Context) (int64, error) {
+		var n int64
+		err := db.WithContext(ctx).Model(&models.Session{}).
+			Where("revoked_at IS NULL AND expires_at > ?", time.Now()).
+			Count(&n).Error
+		return n, err
+	})
+}
+
+// serveMetrics starts a separate listener for /metrics when METRICS_ADDR is
+// set (e.g. "127.0.0.1:9090"), so metrics can stay off the public port. It
+// also serves the detailed readiness report at /readyz.
+func serveMetrics() {
+	addr := os.Getenv("METRICS_ADDR")
+	if addr == "" {
+		return
+	}
+	metricsApp := fiber.New(fiber.Config{DisableStartupMessage: true})
+	metricsApp.Get("/metrics", metrics.Handler())
+	metricsApp.Get("/readyz", handlers.ReadyzDetails)
+	lifecycle.OnShutdown("metrics server", metricsApp.ShutdownWithContext)
+	go func() {
+		slog.Info("Metrics server starting", "addr", addr)
+		if err := metricsApp.Listen(addr); err != nil {
+			slog.Error("Metrics server failed", "error", err)
+		}
+	}()
+}
+
+// newApp builds the Fiber app with its middleware and routes. It expects the
+// database, signing keys and identity providers to be set up already.
+func newApp(repos *repository.Repositories) *fiber.App {
+	// Handlers return *apierror.Error values; the error handler renders them
+	// (and anything unexpected) as the standard error envelope
+	app := fiber.New(fiber.Config{
+		ErrorHandler: apierror.Handler,
+	})
+
+	// Tag every request with an X-Request-ID (kept if the client sent a valid
+	// one); error responses and every log record of the request carry it
+	app.Use(middleware.RequestID())
+
+	// One structured log record per request
+	app.Use(middleware.RequestLogger())
+
+	// A span per request, continuing the caller's trace (traceparent); log
+	// records and error responses carry its trace ID
+	app.Use(tracing.Middleware())
+
+	// Request counts and latency by route for /metrics
+	app.Use(metrics.Middleware())
+
+	// CORS middleware
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: corsOrigin,
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, PUT, DELETE",
+		AllowOrigins:  corsOrigin(),
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, X-Request-ID, traceparent, tracestate",
+		AllowMethods:  "GET, POST, PUT, DELETE",
+		ExposeHeaders: "X-Request-ID, Retry-After",
 	}))
 
 	// Basic route
@@ -46,24 +233,34 @@ func main() {
 		})
 	})
 
-	// Health check
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status":   "healthy",
-			"database": "connected",
-		})
-	})
+	// Probes: liveness never touches dependencies, readiness runs the
+	// registered health checks. /health is kept as an alias of /readyz.
+	app.Get("/livez", handlers.Livez)
+	app.Get("/readyz", handlers.Readyz)
+	app.Get("/health", handlers.Readyz)
 
-	// Setup routes
-	routes.SetupAuthRoutes(app)
-	routes.UserRoutes(app)
-	routes.MessageRoutes(app)
+	// Prometheus metrics, unless they are served on their own address
+	if os.Getenv("METRICS_ADDR") == "" {
+		app.Get("/metrics", metrics.Handler())
+	}
 
-	// Read port from environment (default 8080)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// API description for clients and code generators
+	app.Get("/api/openapi.json", handlers.OpenAPISpec)
+
+	// Setup routes (sign-up, sign-in, room, message and user handlers go
+	// through the repository layer)
+	routes.SetupAuthRoutes(app, repos)
+	routes.UserRoutes(app, repos)
+	routes.MessageRoutes(app, repos)
+	routes.AdminRoutes(app)
+
+	return app
+}
+
+// corsOrigin returns the allowed browser origin (CORS_ORIGIN).
+func corsOrigin() string {
+	if origin := os.Getenv("CORS_ORIGIN"); origin != "" {
+		return origin
 	}
-	log.Printf("Server starting on :%s (CORS_ORIGIN=%s)", port, corsOrigin)
-	log.Fatal(app.Listen(":" + port))
+	return "http://localhost:3000"
 }
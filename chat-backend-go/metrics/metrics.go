@@ -0,0 +1,172 @@
+// Package metrics exposes Prometheus metrics for the API: HTTP traffic by
+// route and status, the database pool, chat activity, sign-ins and the latency
+// of calls to identity providers. Handlers record events through the helpers
+// here; Handler serves everything in the Prometheus text format.
+package metrics
+
+import (
+	"chat-backend-go/apierror"
+	"context"
+	"database/sql"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "windgo"
+
+// Registry holds every WindGo metric plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	messagesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Chat messages sent, by room.",
+	}, []string{"room_id"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Sign-in attempts by provider (password, two_factor, github, github_device or an OIDC provider) and result.",
+	}, []string{"provider", "result"})
+
+	providerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "identity_provider_request_duration_seconds",
+		Help:      "Latency of outgoing calls to identity providers (GitHub OAuth and API, OIDC issuers) by endpoint path and status.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"provider", "endpoint", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		messagesSent,
+		logins,
+		providerDuration,
+	)
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// Middleware records the count and latency of every request. Requests are
+// labelled with the matched route template (/api/v1/rooms/:roomId/messages)
+// rather than the raw path, and unknown paths share the "unmatched" route, so
+// the number of series stays bounded; requests rejected by a group's middleware
+// (a missing token, say) carry the group prefix. Install it after the logger so
+// the status of a returned error is known before the error handler runs.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		if err != nil {
+			apiErr := apierror.FromError(err)
+			status = apiErr.Status
+			if apiErr.Code == apierror.CodeRouteNotFound || apiErr.Code == apierror.CodeMethodNotAllowed {
+				route = "unmatched"
+			}
+		}
+
+		// Fiber reuses the method's buffer after the request; the label outlives it
+		labels := prometheus.Labels{"method": strings.Clone(c.Method()), "route": route, "status": strconv.Itoa(status)}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// RegisterDB exports the connection pool statistics of db (open, in use and
+// idle connections, waits) under the given name.
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterActiveSessions exports the number of active sessions, computed by
+// count on every scrape.
+func RegisterActiveSessions(count func(ctx context.Context) (int64, error)) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_sessions",
+		Help:      "Sessions that are neither revoked nor expired.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		n, err := count(ctx)
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(n)
+	}))
+}
+
+// MessageSent counts a message posted to a room.
+func MessageSent(roomID uint) {
+	messagesSent.WithLabelValues(strconv.FormatUint(uint64(roomID), 10)).Inc()
+}
+
+// Login counts a sign-in attempt through provider.
+func Login(provider string, success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	logins.WithLabelValues(provider, result).Inc()
+}
+
+// InstrumentClient wraps client's transport so that every request it makes
+// is timed under provider. The client is modified and returned.
+func InstrumentClient(provider string, client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = roundTripper{provider: provider, base: base}
+	return client
+}
+
+type roundTripper struct {
+	provider string
+	base     http.RoundTripper
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.base.RoundTrip(req)
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	providerDuration.WithLabelValues(rt.provider, req.URL.Path, status).Observe(time.Since(start).Seconds())
+	return resp, err
+}
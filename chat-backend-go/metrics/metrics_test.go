@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// observations returns how many samples the provider latency histogram holds
+// for the given labels.
+func observations(t *testing.T, provider, endpoint, status string) uint64 {
+	t.Helper()
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"provider": provider, "endpoint": endpoint, "status": status}
+	for _, family := range families {
+		if family.GetName() != "windgo_identity_provider_request_duration_seconds" {
+			continue
+		}
+	metrics:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if want[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+			return m.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestInstrumentClientTimesProviderCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/user" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client := InstrumentClient("test", &http.Client{})
+	for _, path := range []string{"/login/oauth/access_token", "/user", "/user?per_page=100"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Get("http://127.0.0.1:0/user"); err == nil {
+		t.Fatal("request to port 0 succeeded")
+	}
+
+	for _, tt := range []struct {
+		endpoint, status string
+		want             uint64
+	}{
+		{"/login/oauth/access_token", "200", 1},
+		{"/user", "401", 2},
+		{"/user", "error", 1},
+	} {
+		if got := observations(t, "test", tt.endpoint, tt.status); got != tt.want {
+			t.Errorf("%s %s: %d observations, want %d", tt.endpoint, tt.status, got, tt.want)
+		}
+	}
+}
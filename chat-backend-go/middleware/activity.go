@@ -2,13 +2,19 @@ package middleware
 
 import (
 	"chat-backend-go/config"
+	"chat-backend-go/lifecycle"
 	"chat-backend-go/models"
+	"context"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// TrackActivity updates the user's last_active_at timestamp on each request
+// TrackActivity updates the user's last_active_at timestamp on each request.
+// The update runs in the background but is tracked by the lifecycle manager,
+// so shutdown waits for it before closing the database. It keeps the
+// request's trace and log attributes but not its cancellation, since it
+// outlives the request.
 func TrackActivity() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Continue with the request first
@@ -18,16 +24,17 @@ func TrackActivity() fiber.Handler {
 		userID := c.Locals("userID")
 		if userID != nil {
 			now := time.Now()
+			db := config.DB.WithContext(context.WithoutCancel(c.UserContext()))
 			// Update asynchronously to not slow down response
-			go func() {
-				config.DB.Model(&models.User{}).
+			lifecycle.Go("activity update", func() {
+				db.Model(&models.User{}).
 					Where("id = ?", userID).
 					Updates(map[string]interface{}{
 						"last_active_at": now,
 						"is_online":      true,
 						"status":         "online",
 					})
-			}()
+			})
 		}
 
 		return err
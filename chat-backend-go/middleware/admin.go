@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin restricts a route to users with the "admin" role. Must run after AuthRequired.
+func RequireAdmin() fiber.Handler {
+	return RequireRole("admin")
+}
+
+// RequireRole restricts a route to users with one of the given roles. Must run after AuthRequired.
+func RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok {
+			return apierror.Unauthenticated
+		}
+
+		var user models.User
+		if err := config.DB.WithContext(c.UserContext()).Select("id", "role").First(&user, userID).Error; err != nil {
+			return apierror.Unauthenticated.WithMessage("User not found")
+		}
+		if !slices.Contains(roles, user.Role) {
+			Audit(c, utils.AuditAccessDenied, "route", c.Method()+" "+c.Path(), models.AuditDetails{"role": user.Role})
+			return apierror.Forbidden
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"chat-backend-go/models"
+	"chat-backend-go/utils"
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Audit records a security or moderation event for the current request,
+// attributed to the authenticated user if there is one. targetType and
+// targetID name what the action applied to; details may be nil.
+func Audit(c *fiber.Ctx, action, targetType, targetID string, details models.AuditDetails) {
+	actorID, _ := c.Locals("userID").(uint)
+	AuditAs(c, actorID, action, targetType, targetID, details)
+}
+
+// AuditAs is Audit with an explicit actor, for requests that authenticate the
+// user themselves (logins, provider callbacks). An actorID of 0 records an
+// anonymous actor. Failures are logged; they never fail the request.
+func AuditAs(c *fiber.Ctx, actorID uint, action, targetType, targetID string, details models.AuditDetails) {
+	entry := models.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IPAddress:  c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+		Details:    details,
+	}
+	if actorID != 0 {
+		entry.ActorID = &actorID
+	}
+	if err := utils.RecordAudit(c.UserContext(), &entry); err != nil {
+		slog.ErrorContext(c.UserContext(), "Audit: failed to record event", "action", action, "error", err)
+	}
+}
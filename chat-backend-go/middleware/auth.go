@@ -1,49 +1,40 @@
 package middleware
 
 import (
+	"chat-backend-go/apierror"
 	"chat-backend-go/utils"
+	"errors"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
 )
 
-// AuthRequired middleware validates JWT token and extracts user ID
+// AuthRequired middleware validates a JWT or personal access token and extracts user ID
 func AuthRequired() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Get Authorization header
 		authHeader := c.Get("Authorization")
 		if authHeader == "" {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Authorization header required",
-			})
+			return apierror.MissingToken
 		}
 
 		// Check if it's a Bearer token
 		if !strings.HasPrefix(authHeader, "Bearer ") {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Invalid authorization header format",
-			})
+			return apierror.InvalidToken.WithMessage("Invalid authorization header format")
 		}
 
 		// Extract token
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == "" {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Token required",
-			})
+			return apierror.MissingToken.WithMessage("Token required")
 		}
 
-		// Validate token and extract user ID
-		userID, err := utils.ValidateJWT(tokenString)
-		if err != nil {
-			return c.Status(401).JSON(fiber.Map{
-				"error": "Invalid or expired token",
-			})
+		// Validate token (JWT or personal access token) and extract user ID
+		if err := authenticate(c, tokenString); err != nil {
+			return err
 		}
 
-		// Store user ID in context for use in handlers
-		c.Locals("userID", userID)
-
 		return c.Next()
 	}
 }
@@ -54,10 +45,43 @@ func OptionalAuth() fiber.Handler {
 		authHeader := c.Get("Authorization")
 		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			if userID, err := utils.ValidateJWT(tokenString); err == nil {
-				c.Locals("userID", userID)
-			}
+			_ = authenticate(c, tokenString)
 		}
 		return c.Next()
 	}
 }
+
+// authenticate validates a bearer token and stores the caller in the context:
+// "userID" always, "sessionID" for JWTs, and "tokenScopes" for personal access
+// tokens. The returned error is an *apierror.Error.
+func authenticate(c *fiber.Ctx, tokenString string) error {
+	if utils.IsPersonalAccessToken(tokenString) {
+		pat, err := utils.ValidatePersonalAccessToken(c.UserContext(), tokenString)
+		if err != nil {
+			return apierror.InvalidToken
+		}
+		c.Locals("userID", pat.UserID)
+		c.Locals("tokenScopes", pat.ScopeList)
+		return nil
+	}
+
+	claims, err := utils.ParseJWT(tokenString)
+	var validation *jwt.ValidationError
+	if errors.As(err, &validation) && validation.Errors&jwt.ValidationErrorExpired != 0 {
+		// Clients refresh on this code rather than sending the user back to sign in
+		return apierror.TokenExpired
+	}
+	if err != nil {
+		return apierror.InvalidToken
+	}
+
+	// Reject tokens whose session was revoked (logout, password change, etc.)
+	if err := utils.ValidateSession(c.UserContext(), claims, c.IP()); err != nil {
+		return apierror.SessionRevoked
+	}
+
+	// Store user and session IDs in context for use in handlers
+	c.Locals("userID", claims.UserID)
+	c.Locals("sessionID", claims.SessionID)
+	return nil
+}
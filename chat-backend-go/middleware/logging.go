@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"chat-backend-go/logging"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// requestIDPattern limits client-supplied request IDs to printable tokens, so
+// they cannot forge log lines or bloat them.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID tags every request with an ID: the client's X-Request-ID if it is
+// well formed, otherwise a new UUID. The ID is echoed in the X-Request-ID
+// response header and error bodies, and stored in the request's user context
+// so that everything logged with c.UserContext() carries it.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if requestIDPattern.MatchString(id) {
+			// Fiber reuses the header buffer after the request
+			id = strings.Clone(id)
+		} else {
+			id = uuid.NewString()
+		}
+		c.Set(fiber.HeaderXRequestID, id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+		return c.Next()
+	}
+}
+
+// RequestLogger writes one structured record per request once the response
+// is known, with its latency in milliseconds. Like Fiber's logger it renders
+// a returned error itself, through the app's error handler, so the logged
+// status is the one sent. Server errors are logged at error level, everything
+// else at info.
+func RequestLogger() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(c.UserContext(), level, "request",
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+			slog.Int("bytes", len(c.Response().Body())),
+		)
+		return nil
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/ratelimit"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimit applies rule per client IP and answers 429 with Retry-After once
+// it is exceeded. If the limiter store fails, requests are let through.
+func RateLimit(rule ratelimit.Rule) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed, retryAfter, err := rule.Allow(c.UserContext(), ratelimit.Default(), "ip:"+c.IP())
+		if err != nil {
+			slog.ErrorContext(c.UserContext(), "Rate limiting: store error", "rule", rule.Name, "error", err)
+			return c.Next()
+		}
+		if !allowed {
+			return TooManyRequests(c, retryAfter, apierror.RateLimited)
+		}
+		return c.Next()
+	}
+}
+
+// TooManyRequests sets a Retry-After header in whole seconds and returns err
+// with the same value in its retry_after detail.
+func TooManyRequests(c *fiber.Ctx, retryAfter time.Duration, err *apierror.Error) error {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds))
+	return err.WithDetails(map[string]any{"retry_after": seconds})
+}
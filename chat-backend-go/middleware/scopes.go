@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"chat-backend-go/apierror"
+	"slices"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireScope restricts a route for personal access tokens: the token must
+// carry every listed scope. Interactive sessions (JWTs) have full access.
+// Must run after AuthRequired or OptionalAuth.
+func RequireScope(scopes ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, isToken := c.Locals("tokenScopes").([]string)
+		if !isToken {
+			return c.Next()
+		}
+		for _, scope := range scopes {
+			if !slices.Contains(granted, scope) {
+				return apierror.New(403, apierror.CodeMissingScope, "Token is missing required scope").
+					WithDetails(map[string]any{"required_scope": scope})
+			}
+		}
+		return c.Next()
+	}
+}
+
+// RequireSession rejects personal access tokens, for account management routes
+// that should only be reachable from an interactive login. Must run after AuthRequired.
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, isToken := c.Locals("tokenScopes").([]string); isToken {
+			return apierror.New(403, apierror.CodeSessionRequired, "This endpoint cannot be used with a personal access token")
+		}
+		return c.Next()
+	}
+}
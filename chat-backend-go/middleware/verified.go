@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"chat-backend-go/apierror"
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireVerifiedEmail enforces EMAIL_VERIFICATION for authenticated routes.
+// In "restrict" mode unverified users may only make read-only requests;
+// in "block" mode they are rejected outright. Must run after AuthRequired.
+func RequireVerifiedEmail() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		mode := config.GetEmailVerificationMode()
+		if mode == config.EmailVerificationOff {
+			return c.Next()
+		}
+		if mode == config.EmailVerificationRestrict && (c.Method() == fiber.MethodGet || c.Method() == fiber.MethodHead) {
+			return c.Next()
+		}
+
+		userID, ok := c.Locals("userID").(uint)
+		if !ok {
+			return apierror.Unauthenticated
+		}
+
+		var user models.User
+		if err := config.DB.WithContext(c.UserContext()).Select("id", "email_verified_at").First(&user, userID).Error; err != nil {
+			return apierror.Unauthenticated.WithMessage("User not found")
+		}
+		if user.EmailVerifiedAt == nil {
+			return apierror.EmailNotVerified
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,101 @@
+package migrations
+
+import (
+	"chat-backend-go/models"
+	"log/slog"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// adoptLegacySchema takes over a database that was created by GORM AutoMigrate
+// before versioned migrations existed: it brings the schema up to date one last
+// time with AutoMigrate, runs the data fixups that used to run on every boot,
+// and records the baseline migration as applied. Fresh databases and databases
+// that already have migration history are left alone.
+func adoptLegacySchema(db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&appliedMigration{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 || !db.Migrator().HasTable("users") {
+		return nil
+	}
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return err
+	}
+	baseline := migrations[0]
+
+	slog.Info("Adopting existing database into versioned migrations")
+
+	// Accounts that predate has_password were created by registering with a password
+	backfillHasPassword := !db.Migrator().HasColumn(&models.User{}, "has_password")
+
+	if err := db.AutoMigrate(&models.User{}, &models.UserIdentity{}, &models.Invite{}, &models.Room{}, &models.Message{}, &models.PasswordResetToken{}, &models.EmailVerificationToken{}, &models.RefreshToken{}, &models.Session{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.PersonalAccessToken{}, &models.RateLimitCounter{}, &models.AuditLog{}); err != nil {
+		return err
+	}
+	if backfillHasPassword {
+		db.Model(&models.User{}).Where("provider = ? OR provider IS NULL", "").Update("has_password", true)
+	}
+	if err := migrateUserIdentities(db); err != nil {
+		return err
+	}
+
+	// The baseline only creates what is missing, so running it fills in
+	// anything AutoMigrate doesn't manage (such as the audit log trigger)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(baseline.Up).Error; err != nil {
+			return err
+		}
+		return tx.Create(&appliedMigration{Version: baseline.Version, Name: baseline.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+// migrateUserIdentities moves the single GitHub/OpenID Connect link that used to
+// live on users (git_hub_id, external_id) into user_identities, then drops the
+// old columns. It does nothing once the columns are gone.
+func migrateUserIdentities(db *gorm.DB) error {
+	type legacyUser struct {
+		ID         uint
+		Email      string
+		GitHubID   *string `gorm:"column:git_hub_id"`
+		ExternalID *string `gorm:"column:external_id"`
+	}
+
+	for _, column := range []string{"git_hub_id", "external_id"} {
+		if !db.Migrator().HasColumn(&models.User{}, column) {
+			continue
+		}
+
+		var rows []legacyUser
+		if err := db.Table("users").Select("id, email, " + column).
+			Where(column + " IS NOT NULL AND " + column + " <> ''").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			identity := models.UserIdentity{UserID: row.ID, Email: row.Email}
+			if column == "git_hub_id" {
+				identity.Provider, identity.Subject = "github", *row.GitHubID
+			} else {
+				// Stored as "provider:subject"
+				name, subject, ok := strings.Cut(*row.ExternalID, ":")
+				if !ok {
+					continue
+				}
+				identity.Provider, identity.Subject = name, subject
+			}
+			if err := db.Where(models.UserIdentity{Provider: identity.Provider, Subject: identity.Subject}).
+				FirstOrCreate(&identity).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := db.Exec("ALTER TABLE users DROP COLUMN " + column).Error; err != nil {
+			return err
+		}
+		slog.Info("Moved linked identities to user_identities", "count", len(rows), "column", "users."+column)
+	}
+	return nil
+}
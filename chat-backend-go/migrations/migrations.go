@@ -0,0 +1,282 @@
+// Package migrations applies the versioned SQL schema migrations embedded in
+// the binary. Each dialect has its own directory of numbered files
+// (0001_name.up.sql / 0001_name.down.sql); applied versions are recorded in the
+// schema_migrations table.
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// tableName records applied migrations.
+const tableName = "schema_migrations"
+
+// advisoryLockID serializes migrations across instances on Postgres.
+const advisoryLockID = 727_465_001
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one schema change with its forward and reverse SQL.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and when it was applied (nil if pending).
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   int `gorm:"primaryKey"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string { return tableName }
+
+// Load returns the embedded migrations for a dialect ("postgres" or "sqlite"), in order.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("unexpected migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		sql, err := fs.ReadFile(files, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// List reports every known migration and whether it has been applied.
+func List(db *gorm.DB) ([]Status, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = Status{Migration: m}
+		if a, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &a.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied yet.
+func Pending(db *gorm.DB) ([]Migration, error) {
+	statuses, err := List(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies up to steps pending migrations (all of them when steps <= 0),
+// each in its own transaction, and returns the ones applied.
+func Up(db *gorm.DB, steps int) ([]Migration, error) {
+	var done []Migration
+	err := withLock(db, func(conn *gorm.DB) error {
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		if err := adoptLegacySchema(conn); err != nil {
+			return err
+		}
+
+		pending, err := Pending(conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			if steps > 0 && len(done) == steps {
+				break
+			}
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Create(&appliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the last steps applied migrations (at least one), newest first,
+// and returns the ones reverted.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	var done []Migration
+	err := withLock(db, func(conn *gorm.DB) error {
+		statuses, err := List(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+			m := statuses[i]
+			if m.AppliedAt == nil {
+				continue
+			}
+			if err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Delete(&appliedMigration{}, m.Version).Error
+			}); err != nil {
+				return fmt.Errorf("reverting migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m.Migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Dialects lists the databases migrations are written for. Every schema change
+// needs a file pair in each dialect's directory.
+var Dialects = []string{"postgres", "sqlite"}
+
+// Create writes an empty up/down pair for the next version into each of dirs
+// (one per dialect) and returns their paths. name may contain spaces or dashes.
+func Create(name string, dirs ...string) ([]string, error) {
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return nil, fmt.Errorf("migration name is required")
+	}
+
+	// Versions are shared across dialects
+	next := 1
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if match := fileName.FindStringSubmatch(entry.Name()); match != nil {
+				version, _ := strconv.Atoi(match[1])
+				next = max(next, version+1)
+			}
+		}
+	}
+
+	var paths []string
+	for _, dir := range dirs {
+		for _, direction := range []string{"up", "down"} {
+			p := filepath.Join(dir, fmt.Sprintf("%04d_%s.%s.sql", next, slug, direction))
+			body := fmt.Sprintf("-- %s: %s (%s)\n", slug, direction, time.Now().UTC().Format("2006-01-02"))
+			if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+				return nil, err
+			}
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+func ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS ` + tableName + ` (
+    version    bigint PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at timestamp NOT NULL
+)`).Error
+}
+
+// appliedVersions returns the recorded migrations keyed by version. A missing
+// table means nothing has been applied.
+func appliedVersions(db *gorm.DB) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+	if !db.Migrator().HasTable(tableName) {
+		return applied, nil
+	}
+	var rows []appliedMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// withLock runs fn while holding a Postgres advisory lock on one pooled
+// connection, so concurrently starting instances don't migrate twice.
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if db.Dialector.Name() != "postgres" {
+		return fn(db)
+	}
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", advisoryLockID).Error; err != nil {
+			return err
+		}
+		defer conn.Exec("SELECT pg_advisory_unlock(?)", advisoryLockID)
+		return fn(conn)
+	})
+}
@@ -0,0 +1,207 @@
+-- Baseline schema: every table the backend used before versioned migrations.
+
+CREATE TABLE IF NOT EXISTS users (
+    id                bigserial PRIMARY KEY,
+    username          text NOT NULL CONSTRAINT uni_users_username UNIQUE,
+    email             text NOT NULL CONSTRAINT uni_users_email UNIQUE,
+    password          text NOT NULL,
+    has_password      boolean NOT NULL DEFAULT false,
+    email_verified_at timestamptz,
+    role              text NOT NULL DEFAULT 'user',
+    provider          text,
+    avatar_url        text,
+    is_bot            boolean NOT NULL DEFAULT false,
+    owner_id          bigint,
+    last_active_at    timestamptz,
+    is_online         boolean DEFAULT false,
+    status            text DEFAULT 'offline',
+    created_at        timestamptz,
+    updated_at        timestamptz,
+    deleted_at        timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_user_username ON users (username);
+CREATE INDEX IF NOT EXISTS idx_user_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_user_role ON users (role);
+CREATE INDEX IF NOT EXISTS idx_user_provider ON users (provider);
+CREATE INDEX IF NOT EXISTS idx_user_owner ON users (owner_id);
+CREATE INDEX IF NOT EXISTS idx_user_last_active ON users (last_active_at);
+CREATE INDEX IF NOT EXISTS idx_user_created ON users (created_at);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint NOT NULL CONSTRAINT fk_user_identities_user REFERENCES users (id),
+    provider     text NOT NULL,
+    subject      text NOT NULL,
+    email        text,
+    username     text,
+    last_used_at timestamptz,
+    created_at   timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_user_provider ON user_identities (user_id, provider);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_identity_provider_subject ON user_identities (provider, subject);
+
+CREATE TABLE IF NOT EXISTS invites (
+    id            bigserial PRIMARY KEY,
+    code_hash     text NOT NULL,
+    prefix        text,
+    email         text,
+    note          text,
+    max_uses      bigint NOT NULL DEFAULT 1,
+    uses          bigint NOT NULL DEFAULT 0,
+    created_by_id bigint NOT NULL CONSTRAINT fk_invites_created_by REFERENCES users (id),
+    expires_at    timestamptz,
+    revoked_at    timestamptz,
+    created_at    timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invites_code_hash ON invites (code_hash);
+CREATE INDEX IF NOT EXISTS idx_invite_created_by ON invites (created_by_id);
+
+CREATE TABLE IF NOT EXISTS rooms (
+    id                bigserial PRIMARY KEY,
+    name              text NOT NULL,
+    slow_mode_seconds bigint NOT NULL DEFAULT 0,
+    created_at        timestamptz,
+    updated_at        timestamptz,
+    deleted_at        timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_room_name ON rooms (name);
+CREATE INDEX IF NOT EXISTS idx_room_created ON rooms (created_at);
+CREATE INDEX IF NOT EXISTS idx_rooms_deleted_at ON rooms (deleted_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id         bigserial PRIMARY KEY,
+    content    text NOT NULL,
+    user_id    bigint NOT NULL CONSTRAINT fk_messages_user REFERENCES users (id),
+    room_id    bigint NOT NULL CONSTRAINT fk_rooms_messages REFERENCES rooms (id),
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_message_user ON messages (user_id);
+CREATE INDEX IF NOT EXISTS idx_message_room ON messages (room_id);
+CREATE INDEX IF NOT EXISTS idx_message_created ON messages (created_at);
+CREATE INDEX IF NOT EXISTS idx_messages_deleted_at ON messages (deleted_at);
+
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL CONSTRAINT fk_password_reset_tokens_user REFERENCES users (id),
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_password_reset_user ON password_reset_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL CONSTRAINT fk_email_verification_tokens_user REFERENCES users (id),
+    email      text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_email_verification_tokens_token_hash ON email_verification_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_email_verification_user ON email_verification_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL CONSTRAINT fk_refresh_tokens_user REFERENCES users (id),
+    family_id  text NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    rotated_at timestamptz,
+    revoked_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_user ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_token_family ON refresh_tokens (family_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id           varchar(36) PRIMARY KEY,
+    user_id      bigint NOT NULL CONSTRAINT fk_sessions_user REFERENCES users (id),
+    token_id     text,
+    device_name  text,
+    user_agent   text,
+    ip_address   text,
+    last_used_at timestamptz,
+    expires_at   timestamptz,
+    revoked_at   timestamptz,
+    created_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_session_user ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_session_token ON sessions (token_id);
+CREATE INDEX IF NOT EXISTS idx_session_last_used ON sessions (last_used_at);
+
+CREATE TABLE IF NOT EXISTS totp_credentials (
+    id             bigserial PRIMARY KEY,
+    user_id        bigint NOT NULL CONSTRAINT fk_totp_credentials_user REFERENCES users (id),
+    secret         text NOT NULL,
+    confirmed_at   timestamptz,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at     timestamptz,
+    updated_at     timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_totp_credentials_user_id ON totp_credentials (user_id);
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id         bigserial PRIMARY KEY,
+    user_id    bigint NOT NULL CONSTRAINT fk_recovery_codes_user REFERENCES users (id),
+    code_hash  text NOT NULL,
+    used_at    timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+CREATE INDEX IF NOT EXISTS idx_recovery_code_user ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+    id           bigserial PRIMARY KEY,
+    user_id      bigint NOT NULL CONSTRAINT fk_personal_access_tokens_user REFERENCES users (id),
+    name         text NOT NULL,
+    token_hash   text NOT NULL,
+    prefix       text,
+    scopes       text NOT NULL,
+    expires_at   timestamptz,
+    last_used_at timestamptz,
+    revoked_at   timestamptz,
+    created_at   timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_personal_access_tokens_token_hash ON personal_access_tokens (token_hash);
+CREATE INDEX IF NOT EXISTS idx_pat_user ON personal_access_tokens (user_id);
+
+CREATE TABLE IF NOT EXISTS rate_limit_counters (
+    key        text PRIMARY KEY,
+    value      bigint NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_expires ON rate_limit_counters (expires_at);
+
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id          bigserial PRIMARY KEY,
+    action      text NOT NULL,
+    actor_id    bigint,
+    actor_name  text,
+    target_type text,
+    target_id   text,
+    ip_address  text,
+    user_agent  text,
+    details     text,
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_target ON audit_logs (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_created ON audit_logs (created_at);
+
+-- Audit entries are append-only, even outside the application
+CREATE OR REPLACE FUNCTION audit_logs_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS audit_logs_append_only ON audit_logs;
+CREATE TRIGGER audit_logs_append_only BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW EXECUTE FUNCTION audit_logs_append_only();
@@ -0,0 +1,73 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the AuditLog model: an append-only record of security and
+// moderation events. Entries are never updated or deleted by the application.
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogAppendOnly is returned when code tries to modify or remove an audit entry.
+var ErrAuditLogAppendOnly = errors.New("audit log entries are append-only")
+
+type AuditLog struct {
+	ID         uint         `json:"id" gorm:"primaryKey"`
+	Action     string       `json:"action" gorm:"not null;index:idx_audit_action"`
+	ActorID    *uint        `json:"actor_id" gorm:"index:idx_audit_actor"` // nil for anonymous requests such as failed logins
+	ActorName  string       `json:"actor_name,omitempty"`                  // username at the time of the event
+	TargetType string       `json:"target_type,omitempty" gorm:"index:idx_audit_target,priority:1"`
+	TargetID   string       `json:"target_id,omitempty" gorm:"index:idx_audit_target,priority:2"`
+	IPAddress  string       `json:"ip_address,omitempty"`
+	UserAgent  string       `json:"user_agent,omitempty"`
+	Details    AuditDetails `json:"details,omitempty" gorm:"type:text"`
+	CreatedAt  time.Time    `json:"created_at" gorm:"index:idx_audit_created"`
+}
+
+// BeforeUpdate refuses changes to recorded entries.
+func (AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// BeforeDelete refuses to remove recorded entries.
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+// AuditDetails holds event-specific context, stored as a JSON object.
+type AuditDetails map[string]string
+
+// Value implements driver.Valuer.
+func (d AuditDetails) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(d)
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner.
+func (d *AuditDetails) Scan(value any) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("unsupported audit details type %T", value)
+	}
+	if len(raw) == 0 {
+		*d = nil
+		return nil
+	}
+	return json.Unmarshal(raw, d)
+}
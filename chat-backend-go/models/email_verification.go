@@ -0,0 +1,16 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the EmailVerificationToken model. Only a SHA-256 hash of each token is stored.
+package models
+
+import "time"
+
+type EmailVerificationToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index:idx_email_verification_user"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	Email     string     `json:"email" gorm:"not null"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the Invite model: admin-issued sign-up codes used when
+// SIGNUP_MODE=invite. Only a hash of each code is stored.
+package models
+
+import "time"
+
+type Invite struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	CodeHash    string     `json:"-" gorm:"not null;uniqueIndex"`
+	Prefix      string     `json:"prefix"`          // first characters of the code, to help admins identify it
+	Email       string     `json:"email,omitempty"` // if set, only this address may use the invite
+	Note        string     `json:"note,omitempty"`
+	MaxUses     int        `json:"max_uses" gorm:"not null;default:1"`
+	Uses        int        `json:"uses" gorm:"not null;default:0"`
+	CreatedByID uint       `json:"created_by_id" gorm:"not null;index:idx_invite_created_by"`
+	CreatedBy   User       `json:"-" gorm:"foreignKey:CreatedByID"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
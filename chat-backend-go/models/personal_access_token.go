@@ -0,0 +1,22 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the PersonalAccessToken model: named, scoped, expiring API
+// tokens for scripts and bot accounts. Only a hash of each token is stored.
+package models
+
+import "time"
+
+type PersonalAccessToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index:idx_pat_user"`
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Name       string     `json:"name" gorm:"not null"`
+	TokenHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	Prefix     string     `json:"prefix"`            // first characters of the token, to help users identify it
+	Scopes     string     `json:"-" gorm:"not null"` // space-separated
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// ScopeList is Scopes split into a list (computed, not stored)
+	ScopeList []string `json:"scopes" gorm:"-"`
+}
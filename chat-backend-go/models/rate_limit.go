@@ -0,0 +1,11 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the RateLimitCounter model used by the shared rate limiter store.
+package models
+
+import "time"
+
+type RateLimitCounter struct {
+	Key       string    `gorm:"primaryKey"`
+	Value     int64     `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null;index:idx_rate_limit_expires"`
+}
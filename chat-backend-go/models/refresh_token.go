@@ -0,0 +1,18 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the RefreshToken model. Tokens are opaque, stored as SHA-256 hashes,
+// and grouped into families so reuse of a rotated token can revoke the whole chain.
+package models
+
+import "time"
+
+type RefreshToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index:idx_refresh_token_user"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	FamilyID  string     `json:"family_id" gorm:"not null;index:idx_refresh_token_family"`
+	TokenHash string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -9,10 +9,11 @@ import (
 )
 
 type Room struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"not null;index:idx_room_name"`
-	Messages  []Message      `json:"messages,omitempty"`
-	CreatedAt time.Time      `json:"created_at" gorm:"index:idx_room_created"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID              uint           `json:"id" gorm:"primaryKey"`
+	Name            string         `json:"name" gorm:"not null;index:idx_room_name"`
+	SlowModeSeconds int            `json:"slow_mode_seconds" gorm:"not null;default:0"` // minimum gap between one user's messages; 0 = off
+	Messages        []Message      `json:"messages,omitempty"`
+	CreatedAt       time.Time      `json:"created_at" gorm:"index:idx_room_created"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `json:"-" gorm:"index"`
 }
@@ -0,0 +1,22 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the Session model: one row per login, shared by the access tokens
+// (via the "sid" claim) and the refresh token family issued for that login.
+package models
+
+import "time"
+
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey;size:36"`
+	UserID     uint       `json:"user_id" gorm:"not null;index:idx_session_user"`
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	TokenID    string     `json:"-" gorm:"index:idx_session_token"` // jti of the latest access token
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	LastUsedAt time.Time  `json:"last_used_at" gorm:"index:idx_session_last_used"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	// Current marks the session making the request (computed, not stored)
+	Current bool `json:"current" gorm:"-"`
+}
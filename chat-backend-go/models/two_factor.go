@@ -0,0 +1,28 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the TOTP two-factor models: one credential per user and
+// single-use recovery codes stored as SHA-256 hashes.
+package models
+
+import "time"
+
+type TOTPCredential struct {
+	ID     uint   `json:"id" gorm:"primaryKey"`
+	UserID uint   `json:"user_id" gorm:"not null;uniqueIndex"`
+	User   User   `json:"-" gorm:"foreignKey:UserID"`
+	Secret string `json:"-" gorm:"not null"`
+	// ConfirmedAt is nil while enrollment is pending
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep is the last accepted TOTP time step; codes can't be replayed
+	LastUsedStep int64     `json:"-" gorm:"not null;default:0"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index:idx_recovery_code_user"`
+	User      User       `json:"-" gorm:"foreignKey:UserID"`
+	CodeHash  string     `json:"-" gorm:"not null;uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
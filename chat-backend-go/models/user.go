@@ -13,11 +13,18 @@ type User struct {
     Username     string         `json:"username" gorm:"unique;not null;index:idx_user_username"`
     Email        string         `json:"email" gorm:"unique;not null;index:idx_user_email"`
     Password     string         `json:"-" gorm:"not null"`
+    // False for accounts created through an identity provider until a password is set
+    HasPassword  bool           `json:"has_password" gorm:"not null;default:false"`
+    EmailVerifiedAt *time.Time  `json:"email_verified_at"`
+    // Role is "user", "moderator" (can moderate rooms) or "admin"
     Role         string         `json:"role" gorm:"not null;default:'user';index:idx_user_role"`
-    // Social login fields
+    // Social login fields; Provider records how the account was created,
+    // linked logins live in UserIdentity
     Provider     string         `json:"provider" gorm:"index:idx_user_provider"`
-    GitHubID     string         `json:"github_id" gorm:"uniqueIndex"`
     AvatarURL    string         `json:"avatar_url"`
+    // Bot accounts authenticate only with personal access tokens and belong to a human user
+    IsBot        bool           `json:"is_bot" gorm:"not null;default:false"`
+    OwnerID      *uint          `json:"owner_id,omitempty" gorm:"index:idx_user_owner"`
     // Activity tracking
     LastActiveAt *time.Time     `json:"last_active_at" gorm:"index:idx_user_last_active"`
     IsOnline     bool           `json:"is_online" gorm:"default:false"`
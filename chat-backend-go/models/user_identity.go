@@ -0,0 +1,18 @@
+// Package models defines database models with optimized indexes for the chat application.
+// This file contains the UserIdentity model: an external login (GitHub, OpenID Connect)
+// linked to a local account. A user may link several providers, one identity each.
+package models
+
+import "time"
+
+type UserIdentity struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"not null;uniqueIndex:idx_identity_user_provider"`
+	User       User       `json:"-" gorm:"foreignKey:UserID"`
+	Provider   string     `json:"provider" gorm:"not null;uniqueIndex:idx_identity_user_provider;uniqueIndex:idx_identity_provider_subject"`
+	Subject    string     `json:"subject" gorm:"not null;uniqueIndex:idx_identity_provider_subject"`
+	Email      string     `json:"email"`
+	Username   string     `json:"username"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
@@ -0,0 +1,11 @@
+// Package openapi embeds the OpenAPI 3 description of the HTTP API, served at
+// /api/openapi.json. Update openapi.json together with the routes in package
+// routes; the integration tests fail when the two disagree.
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI 3 document as JSON.
+//
+//go:embed openapi.json
+var Spec []byte
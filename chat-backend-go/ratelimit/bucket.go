@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Bucket is a token bucket holding up to Burst tokens and refilling one token
+// every Refill. A Burst of zero disables it.
+//
+// It is implemented as GCRA: the store keeps one "theoretical arrival time"
+// per key, updated with compare-and-swap so concurrent requests on several
+// instances can't overspend the bucket.
+type Bucket struct {
+	Name   string
+	Burst  int
+	Refill time.Duration
+}
+
+// BucketFromEnv returns a bucket allowing burst events and refilling the full
+// bucket over window, overridden by RATE_LIMIT_<NAME> as "burst/window" (e.g.
+// "10/10s"), or "off" to disable it.
+func BucketFromEnv(name string, burst int, window time.Duration) Bucket {
+	rule := RuleFromEnv(name, burst, window)
+	if rule.Limit <= 0 {
+		return Bucket{Name: name}
+	}
+	return Bucket{Name: name, Burst: rule.Limit, Refill: rule.Window / time.Duration(rule.Limit)}
+}
+
+// Take removes one token for key. If the bucket is empty it reports how long
+// until a token is available; rejected calls don't consume tokens.
+func (b Bucket) Take(ctx context.Context, store Store, key string) (allowed bool, retryAfter time.Duration, err error) {
+	if b.Burst <= 0 || b.Refill <= 0 {
+		return true, 0, nil
+	}
+
+	storeKey := "tb:" + b.Name + ":" + key
+	tolerance := int64(b.Burst) * int64(b.Refill)
+	for attempt := 0; attempt < 5; attempt++ {
+		tat, _, err := store.Get(ctx, storeKey)
+		if err != nil {
+			return true, 0, err
+		}
+		now := time.Now().UnixNano()
+		next := max(tat, now) + int64(b.Refill)
+		if next-now > tolerance {
+			return false, time.Duration(next - now - tolerance), nil
+		}
+		swapped, err := store.CompareAndSwap(ctx, storeKey, tat, next, time.Duration(next-now))
+		if err != nil {
+			return true, 0, err
+		}
+		if swapped {
+			return true, 0, nil
+		}
+	}
+
+	// Heavy contention on one key; let the event through rather than fail it
+	slog.WarnContext(ctx, "Rate limiting: gave up updating contended bucket", "bucket", b.Name, "key", key)
+	return true, 0, nil
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule allows at most Limit events per sliding Window for each key. A Limit of
+// zero disables the rule.
+type Rule struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// RuleFromEnv returns a rule with the given defaults, overridden by
+// RATE_LIMIT_<NAME> as "limit/window" (e.g. "20/1m"), or "off" to disable it.
+func RuleFromEnv(name string, limit int, window time.Duration) Rule {
+	rule := Rule{Name: name, Limit: limit, Window: window}
+	env := "RATE_LIMIT_" + strings.ToUpper(name)
+	value := strings.TrimSpace(os.Getenv(env))
+	if value == "" {
+		return rule
+	}
+	if strings.EqualFold(value, "off") {
+		rule.Limit = 0
+		return rule
+	}
+	parsed, err := parseRule(value)
+	if err != nil {
+		slog.Warn("Rate limiting: ignoring invalid rule", "env", env, "value", value, "error", err)
+		return rule
+	}
+	rule.Limit, rule.Window = parsed.Limit, parsed.Window
+	return rule
+}
+
+func parseRule(value string) (Rule, error) {
+	countPart, windowPart, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("expected limit/window")
+	}
+	limit, err := strconv.Atoi(strings.TrimSpace(countPart))
+	if err != nil || limit < 1 {
+		return Rule{}, fmt.Errorf("invalid limit %q", countPart)
+	}
+	window, err := time.ParseDuration(strings.TrimSpace(windowPart))
+	if err != nil || window <= 0 {
+		return Rule{}, fmt.Errorf("invalid window %q", windowPart)
+	}
+	return Rule{Limit: limit, Window: window}, nil
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+// When it isn't, retryAfter estimates how long until the next event would be
+// allowed. Rejected events count too, so hammering a limit keeps it closed.
+//
+// The window slides using two fixed buckets: the previous bucket's count is
+// weighted by how much of it still overlaps the window.
+func (r Rule) Allow(ctx context.Context, store Store, key string) (allowed bool, retryAfter time.Duration, err error) {
+	if r.Limit <= 0 {
+		return true, 0, nil
+	}
+
+	now := time.Now()
+	bucket := now.UnixNano() / int64(r.Window)
+	elapsed := time.Duration(now.UnixNano() - bucket*int64(r.Window))
+	prefix := "rl:" + r.Name + ":" + key + ":"
+
+	current, err := store.Incr(ctx, prefix+strconv.FormatInt(bucket, 10), 2*r.Window)
+	if err != nil {
+		return true, 0, err
+	}
+	previous, _, err := store.Get(ctx, prefix+strconv.FormatInt(bucket-1, 10))
+	if err != nil {
+		return true, 0, err
+	}
+
+	overlap := 1 - float64(elapsed)/float64(r.Window)
+	if float64(previous)*overlap+float64(current) <= float64(r.Limit) {
+		return true, 0, nil
+	}
+	return false, r.retryAfter(current, previous, elapsed), nil
+}
+
+// retryAfter solves for the time at which one more event fits under the limit,
+// assuming no other events arrive in the meantime.
+func (r Rule) retryAfter(current, previous int64, elapsed time.Duration) time.Duration {
+	limit := float64(r.Limit)
+	var wait time.Duration
+	if float64(current)+1 <= limit {
+		// Wait in this bucket for the previous bucket's share to shrink
+		fraction := 1 - (limit-float64(current)-1)/float64(previous)
+		wait = time.Duration(fraction*float64(r.Window)) - elapsed
+	} else {
+		// Wait for the next bucket, then for this one's share to shrink
+		fraction := math.Max(0, 1-(limit-1)/float64(current))
+		wait = r.Window - elapsed + time.Duration(fraction*float64(r.Window))
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait
+}
@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"chat-backend-go/models"
+	"context"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresStore keeps counters in the rate_limit_counters table so every
+// instance sees the same limits. Increments are single upserts, so concurrent
+// requests can't lose updates.
+type PostgresStore struct {
+	db *gorm.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewPostgresStore returns a store backed by db.
+func NewPostgresStore(db *gorm.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	now := time.Now()
+	s.prune(ctx, now)
+
+	var value int64
+	err := s.db.WithContext(ctx).Raw(`
+		INSERT INTO rate_limit_counters (key, value, expires_at) VALUES (?, 1, ?)
+		ON CONFLICT (key) DO UPDATE SET
+			value = CASE WHEN rate_limit_counters.expires_at <= ? THEN 1 ELSE rate_limit_counters.value + 1 END,
+			expires_at = CASE WHEN rate_limit_counters.expires_at <= ? THEN excluded.expires_at ELSE rate_limit_counters.expires_at END
+		RETURNING value`,
+		key, now.Add(ttl), now, now).Scan(&value).Error
+	return value, err
+}
+
+func (s *PostgresStore) Get(ctx context.Context, key string) (int64, time.Time, error) {
+	// Find rather than First: a missing counter is the common case, not an error
+	var counters []models.RateLimitCounter
+	err := s.db.WithContext(ctx).Where("key = ? AND expires_at > ?", key, time.Now()).Limit(1).Find(&counters).Error
+	if err != nil || len(counters) == 0 {
+		return 0, time.Time{}, err
+	}
+	return counters[0].Value, counters[0].ExpiresAt, nil
+}
+
+func (s *PostgresStore) Set(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	counter := models.RateLimitCounter{Key: key, Value: value, ExpiresAt: time.Now().Add(ttl)}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at"}),
+	}).Create(&counter).Error
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, key string) error {
+	return s.db.WithContext(ctx).Where("key = ?", key).Delete(&models.RateLimitCounter{}).Error
+}
+
+func (s *PostgresStore) CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	db := s.db.WithContext(ctx)
+	var result *gorm.DB
+	if old == 0 {
+		// Insert, or replace a row that has expired
+		result = db.Exec(`
+			INSERT INTO rate_limit_counters (key, value, expires_at) VALUES (?, ?, ?)
+			ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at
+			WHERE rate_limit_counters.expires_at <= ?`,
+			key, new, now.Add(ttl), now)
+	} else {
+		result = db.Model(&models.RateLimitCounter{}).
+			Where("key = ? AND value = ? AND expires_at > ?", key, old, now).
+			Updates(map[string]any{"value": new, "expires_at": now.Add(ttl)})
+	}
+	return result.RowsAffected == 1, result.Error
+}
+
+// prune deletes expired counters at most once every five minutes.
+func (s *PostgresStore) prune(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastPrune) < 5*time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mu.Unlock()
+	s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RateLimitCounter{})
+}
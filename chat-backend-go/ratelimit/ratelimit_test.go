@@ -0,0 +1,218 @@
+package ratelimit
+
+import (
+	"chat-backend-go/internal/testdb"
+	"context"
+	"testing"
+	"time"
+)
+
+// testStore runs the Store contract against store.
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	expect := func(what string, got, want int64, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", what, err)
+		}
+		if got != want {
+			t.Fatalf("%s = %d, want %d", what, got, want)
+		}
+	}
+	swap := func(key string, old, new int64, ttl time.Duration, want bool) {
+		t.Helper()
+		swapped, err := store.CompareAndSwap(ctx, key, old, new, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if swapped != want {
+			t.Fatalf("CompareAndSwap(%s, %d, %d) = %v, want %v", key, old, new, swapped, want)
+		}
+	}
+
+	// Incr counts up and Get reads the counter with its expiry
+	for want := int64(1); want <= 3; want++ {
+		got, err := store.Incr(ctx, "incr", time.Hour)
+		expect("Incr", got, want, err)
+	}
+	value, expiresAt, err := store.Get(ctx, "incr")
+	expect("Get", value, 3, err)
+	if until := time.Until(expiresAt); until <= 59*time.Minute || until > time.Hour {
+		t.Fatalf("counter expires in %v, want about an hour", until)
+	}
+	value, _, err = store.Get(ctx, "missing")
+	expect("Get missing", value, 0, err)
+
+	// Set overwrites and Delete removes
+	expect("Set", 0, 0, store.Set(ctx, "incr", 42, time.Hour))
+	value, _, err = store.Get(ctx, "incr")
+	expect("Get after Set", value, 42, err)
+	expect("Delete", 0, 0, store.Delete(ctx, "incr"))
+	value, _, err = store.Get(ctx, "incr")
+	expect("Get after Delete", value, 0, err)
+
+	// CompareAndSwap only replaces the expected value; 0 means missing
+	swap("cas", 0, 5, time.Hour, true)
+	swap("cas", 0, 6, time.Hour, false)
+	swap("cas", 4, 6, time.Hour, false)
+	swap("cas", 5, 6, time.Hour, true)
+	value, _, err = store.Get(ctx, "cas")
+	expect("Get after CompareAndSwap", value, 6, err)
+
+	// Expired counters read as missing, restart on Incr and match 0 in CompareAndSwap
+	expect("Set", 0, 0, store.Set(ctx, "short", 7, 20*time.Millisecond))
+	expect("Set", 0, 0, store.Set(ctx, "short-cas", 7, 20*time.Millisecond))
+	time.Sleep(40 * time.Millisecond)
+	value, _, err = store.Get(ctx, "short")
+	expect("Get expired", value, 0, err)
+	value, err = store.Incr(ctx, "short", time.Hour)
+	expect("Incr expired", value, 1, err)
+	swap("short-cas", 7, 8, time.Hour, false)
+	swap("short-cas", 0, 8, time.Hour, true)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+// TestPostgresStoreSQL runs the Postgres store's upserts and compare-and-swap
+// against SQLite, which accepts the same ON CONFLICT syntax.
+func TestPostgresStoreSQL(t *testing.T) {
+	testStore(t, NewPostgresStore(testdb.New(t)))
+}
+
+func TestRuleAllow(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	rule := Rule{Name: "test", Limit: 3, Window: time.Hour}
+
+	for i := 1; i <= 3; i++ {
+		if allowed, _, err := rule.Allow(ctx, store, "a"); err != nil || !allowed {
+			t.Fatalf("event %d: allowed = %v, err = %v", i, allowed, err)
+		}
+	}
+	for i := 4; i <= 5; i++ {
+		allowed, retryAfter, err := rule.Allow(ctx, store, "a")
+		if err != nil || allowed {
+			t.Fatalf("event %d: allowed = %v, err = %v", i, allowed, err)
+		}
+		if retryAfter < time.Second || retryAfter > 2*time.Hour {
+			t.Fatalf("event %d: retryAfter = %v", i, retryAfter)
+		}
+	}
+	if allowed, _, _ := rule.Allow(ctx, store, "b"); !allowed {
+		t.Fatal("a different key shares the limit")
+	}
+	if allowed, _, _ := (Rule{Name: "off", Window: time.Hour}).Allow(ctx, store, "a"); !allowed {
+		t.Fatal("a rule without a limit rejected an event")
+	}
+}
+
+func TestRuleRetryAfter(t *testing.T) {
+	rule := Rule{Limit: 10, Window: time.Minute}
+	tests := []struct {
+		name              string
+		current, previous int64
+		elapsed           time.Duration
+		want              time.Duration
+	}{
+		// 10*(1-e/60) + 5 + 1 <= 10 once e reaches 36s
+		{"previous bucket drains", 5, 10, 15 * time.Second, 21 * time.Second},
+		// Next bucket: 10*(1-x/60) + 1 <= 10 once x reaches 6s
+		{"current bucket full", 10, 0, 30 * time.Second, 36 * time.Second},
+		{"heavily over the limit", 40, 0, 30 * time.Second, 30*time.Second + time.Duration(0.775*float64(time.Minute))},
+		{"at least a second", 1, 10, 59500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rule.retryAfter(tt.current, tt.previous, tt.elapsed)
+			if diff := got - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Fatalf("retryAfter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucket(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	bucket := Bucket{Name: "test", Burst: 3, Refill: time.Hour}
+	for i := 1; i <= 3; i++ {
+		if allowed, _, err := bucket.Take(ctx, store, "a"); err != nil || !allowed {
+			t.Fatalf("take %d: allowed = %v, err = %v", i, allowed, err)
+		}
+	}
+	tat, _, _ := store.Get(ctx, "tb:test:a")
+	allowed, retryAfter, err := bucket.Take(ctx, store, "a")
+	if err != nil || allowed {
+		t.Fatalf("take 4: allowed = %v, err = %v", allowed, err)
+	}
+	if retryAfter <= 59*time.Minute || retryAfter > time.Hour {
+		t.Fatalf("retryAfter = %v, want about one refill", retryAfter)
+	}
+	if after, _, _ := store.Get(ctx, "tb:test:a"); after != tat {
+		t.Fatal("a rejected take consumed a token")
+	}
+
+	// Tokens come back one per refill interval
+	fast := Bucket{Name: "fast", Burst: 2, Refill: 50 * time.Millisecond}
+	for i := 0; i < 2; i++ {
+		fast.Take(ctx, store, "a")
+	}
+	if allowed, _, _ := fast.Take(ctx, store, "a"); allowed {
+		t.Fatal("empty bucket allowed a take")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if allowed, _, _ := fast.Take(ctx, store, "a"); !allowed {
+		t.Fatal("bucket did not refill")
+	}
+	if allowed, _, _ := fast.Take(ctx, store, "a"); allowed {
+		t.Fatal("bucket refilled more than one token")
+	}
+
+	if allowed, _, _ := (Bucket{Name: "off"}).Take(ctx, store, "a"); !allowed {
+		t.Fatal("a bucket without a burst rejected a take")
+	}
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	lockout := Lockout{Threshold: 3, Base: time.Minute, Max: 5 * time.Minute, Reset: time.Hour}
+
+	// Every third failure locks again for twice as long, up to Max
+	wantLocks := map[int]time.Duration{3: time.Minute, 6: 2 * time.Minute, 9: 4 * time.Minute, 12: 5 * time.Minute, 15: 5 * time.Minute}
+	for failure := 1; failure <= 15; failure++ {
+		locked, err := lockout.Fail(ctx, store, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if locked != wantLocks[failure] {
+			t.Fatalf("failure %d locked for %v, want %v", failure, locked, wantLocks[failure])
+		}
+		if failure == 3 {
+			remaining, err := lockout.Locked(ctx, store, "a")
+			if err != nil || remaining <= 59*time.Second || remaining > time.Minute {
+				t.Fatalf("Locked = %v, %v; want about a minute", remaining, err)
+			}
+			if remaining, _ := lockout.Locked(ctx, store, "b"); remaining != 0 {
+				t.Fatalf("another key is locked for %v", remaining)
+			}
+		}
+	}
+
+	// A success starts the count over
+	if err := lockout.Succeed(ctx, store, "a"); err != nil {
+		t.Fatal(err)
+	}
+	for failure := 1; failure <= 3; failure++ {
+		locked, _ := lockout.Fail(ctx, store, "a")
+		if (locked != 0) != (failure == 3) {
+			t.Fatalf("failure %d after success locked for %v", failure, locked)
+		}
+	}
+	if locked, _ := (Lockout{}).Fail(ctx, store, "a"); locked != 0 {
+		t.Fatal("a disabled lockout locked")
+	}
+}
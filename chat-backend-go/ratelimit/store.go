@@ -0,0 +1,67 @@
+// Package ratelimit throttles abusive clients: sliding-window rate limits and
+// progressive account lockout. Counters live in a pluggable Store so limits
+// can be shared between instances (Postgres) or kept per process (memory).
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Store keeps expiring counters.
+type Store interface {
+	// Incr adds one to key and returns the new value. A missing or expired
+	// counter restarts at 1 and expires after ttl.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Get returns the value and expiry of key, or 0 if it is missing or expired.
+	Get(ctx context.Context, key string) (int64, time.Time, error)
+	// Set stores value under key until ttl elapses.
+	Set(ctx context.Context, key string, value int64, ttl time.Duration) error
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+	// CompareAndSwap sets key to new, expiring after ttl, only if its value is
+	// old; an old value of 0 matches a missing or expired key. It reports
+	// whether the swap happened.
+	CompareAndSwap(ctx context.Context, key string, old, new int64, ttl time.Duration) (bool, error)
+}
+
+var (
+	defaultMu    sync.Mutex
+	defaultStore Store
+)
+
+// Default returns the process-wide store. Call Configure at startup to select
+// it from the environment; until then an in-memory store is used.
+func Default() Store {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultStore == nil {
+		defaultStore = NewMemoryStore()
+	}
+	return defaultStore
+}
+
+// SetDefault overrides the process-wide store (useful for tests).
+func SetDefault(s Store) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultStore = s
+}
+
+// Configure selects the store from RATE_LIMIT_STORE ("memory" or "postgres",
+// default "memory"). The Postgres store shares limits between instances.
+func Configure(db *gorm.DB) {
+	switch strings.ToLower(os.Getenv("RATE_LIMIT_STORE")) {
+	case "postgres", "database":
+		SetDefault(NewPostgresStore(db))
+		slog.Info("Rate limiting: using database store")
+	default:
+		SetDefault(NewMemoryStore())
+	}
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"chat-backend-go/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NewGorm returns repositories backed by a GORM connection. The queries are
+// portable, so the same implementation serves Postgres and SQLite.
+func NewGorm(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:    &gormUsers{db: db},
+		Rooms:    &gormRooms{db: db},
+		Messages: &gormMessages{db: db},
+	}
+}
+
+// notFound maps GORM's not-found error to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *gormUsers) Search(ctx context.Context, excludeID uint, query string) ([]models.User, error) {
+	users := []models.User{}
+	q := r.db.WithContext(ctx).Model(&models.User{}).Where("id <> ?", excludeID)
+	if query = strings.ToLower(strings.TrimSpace(query)); query != "" {
+		like := "%" + query + "%"
+		q = q.Where("LOWER(username) LIKE ? OR LOWER(email) LIKE ?", like, like)
+	}
+	if err := q.Order("username ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUsers) ClaimInvite(ctx context.Context, codeHash, email string) error {
+	result := r.db.WithContext(ctx).Model(&models.Invite{}).
+		Where("code_hash = ? AND revoked_at IS NULL AND uses < max_uses", codeHash).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("email = '' OR LOWER(email) = ?", strings.ToLower(email)).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormUsers) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *gormUsers) WithTx(ctx context.Context, fn func(users UserRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormUsers{db: tx})
+	})
+}
+
+type gormRooms struct {
+	db *gorm.DB
+}
+
+func (r *gormRooms) List(ctx context.Context) ([]models.Room, error) {
+	rooms := []models.Room{}
+	if err := r.db.WithContext(ctx).Find(&rooms).Error; err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (r *gormRooms) GetByID(ctx context.Context, id uint) (*models.Room, error) {
+	var room models.Room
+	if err := r.db.WithContext(ctx).First(&room, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &room, nil
+}
+
+func (r *gormRooms) SetSlowMode(ctx context.Context, room *models.Room, seconds int) error {
+	return r.db.WithContext(ctx).Model(room).Update("slow_mode_seconds", seconds).Error
+}
+
+func (r *gormRooms) Delete(ctx context.Context, room *models.Room) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("room_id = ?", room.ID).Delete(&models.Message{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return tx.Delete(room).Error
+	})
+	return deleted, err
+}
+
+type gormMessages struct {
+	db *gorm.DB
+}
+
+func (r *gormMessages) Create(ctx context.Context, message *models.Message) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Create(message).Error; err != nil {
+		return err
+	}
+	return db.Preload("User").First(message, message.ID).Error
+}
+
+func (r *gormMessages) GetByID(ctx context.Context, id uint) (*models.Message, error) {
+	var message models.Message
+	if err := r.db.WithContext(ctx).First(&message, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &message, nil
+}
+
+func (r *gormMessages) ListByRoom(ctx context.Context, roomID uint, limit, offset int) ([]models.Message, int64, error) {
+	db := r.db.WithContext(ctx)
+	messages := []models.Message{}
+	if err := db.Preload("User").
+		Where("room_id = ?", roomID).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := db.Model(&models.Message{}).Where("room_id = ?", roomID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+func (r *gormMessages) Delete(ctx context.Context, message *models.Message) error {
+	return r.db.WithContext(ctx).Delete(message).Error
+}
@@ -0,0 +1,209 @@
+package repository_test
+
+import (
+	"chat-backend-go/internal/testdb"
+	"chat-backend-go/models"
+	"chat-backend-go/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func seed(t *testing.T, db *gorm.DB, values ...any) {
+	t.Helper()
+	for _, v := range values {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatalf("seed %T: %v", v, err)
+		}
+	}
+}
+
+func TestUserSearch(t *testing.T) {
+	db := testdb.New(t)
+	repos := repository.NewGorm(db)
+	ctx := context.Background()
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com"}
+	bob := &models.User{Username: "Bob", Email: "bob@example.com"}
+	carol := &models.User{Username: "carol", Email: "carol@test.org"}
+	seed(t, db, alice, bob, carol)
+
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"all except self", "", []string{"Bob", "carol"}},
+		{"username case-insensitive", "BO", []string{"Bob"}},
+		{"email", "test.org", []string{"carol"}},
+		{"no match", "zed", []string{}},
+		{"self excluded", "alice", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := repos.Users.Search(ctx, alice.ID, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, u := range users {
+				got = append(got, u.Username)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	if _, err := repos.Users.GetByEmail(ctx, "bob@example.com"); err != nil {
+		t.Fatalf("GetByEmail: %v", err)
+	}
+	if _, err := repos.Users.GetByID(ctx, 9999); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByID(missing) = %v, want ErrNotFound", err)
+	}
+	if _, err := repos.Users.GetByUsername(ctx, "dave"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByUsername(missing) = %v, want ErrNotFound", err)
+	}
+
+	dave := &models.User{Username: "dave", Email: "dave@example.com", Role: "user"}
+	if err := repos.Users.Create(ctx, dave); err != nil || dave.ID == 0 {
+		t.Fatalf("Create = %v (id %d)", err, dave.ID)
+	}
+	if found, err := repos.Users.GetByUsername(ctx, "dave"); err != nil || found.ID != dave.ID {
+		t.Fatalf("GetByUsername = %+v, %v", found, err)
+	}
+	if err := repos.Users.Create(ctx, &models.User{Username: "dave", Email: "other@example.com"}); err == nil {
+		t.Fatal("Create accepted a duplicate username")
+	}
+}
+
+func TestUserWithTx(t *testing.T) {
+	db := testdb.New(t)
+	repos := repository.NewGorm(db)
+	ctx := context.Background()
+
+	admin := &models.User{Username: "admin", Email: "admin@example.com"}
+	seed(t, db, admin)
+	invite := &models.Invite{CodeHash: "hash", MaxUses: 1, CreatedByID: admin.ID}
+	seed(t, db, invite)
+
+	// A failure later in the transaction gives the invite back
+	failed := errors.New("identity failed")
+	err := repos.Users.WithTx(ctx, func(tx repository.UserRepository) error {
+		if err := tx.ClaimInvite(ctx, "hash", "erin@example.com"); err != nil {
+			return err
+		}
+		if err := tx.Create(ctx, &models.User{Username: "erin", Email: "erin@example.com"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("WithTx = %v, want the callback's error", err)
+	}
+	if _, err := repos.Users.GetByUsername(ctx, "erin"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("rolled back user found: %v", err)
+	}
+
+	erin := &models.User{Username: "erin", Email: "erin@example.com"}
+	err = repos.Users.WithTx(ctx, func(tx repository.UserRepository) error {
+		if err := tx.ClaimInvite(ctx, "hash", erin.Email); err != nil {
+			return err
+		}
+		if err := tx.Create(ctx, erin); err != nil {
+			return err
+		}
+		return tx.CreateIdentity(ctx, &models.UserIdentity{UserID: erin.ID, Provider: "github", Subject: "1"})
+	})
+	if err != nil {
+		t.Fatalf("WithTx = %v", err)
+	}
+	var identities int64
+	db.Model(&models.UserIdentity{}).Where("user_id = ?", erin.ID).Count(&identities)
+	if identities != 1 {
+		t.Fatalf("identities = %d, want 1", identities)
+	}
+	if err := repos.Users.ClaimInvite(ctx, "hash", "frank@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("ClaimInvite(used up) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMessagesListByRoom(t *testing.T) {
+	db := testdb.New(t)
+	repos := repository.NewGorm(db)
+	ctx := context.Background()
+
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	room := &models.Room{Name: "general"}
+	other := &models.Room{Name: "random"}
+	seed(t, db, user, room, other)
+
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		seed(t, db, &models.Message{UserID: user.ID, RoomID: room.ID, Content: string(rune('a' + i)), CreatedAt: start.Add(time.Duration(i) * time.Minute)})
+	}
+	seed(t, db, &models.Message{UserID: user.ID, RoomID: other.ID, Content: "elsewhere"})
+
+	page, total, err := repos.Messages.ListByRoom(ctx, room.ID, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(page) != 2 {
+		t.Fatalf("got %d messages of %d, want 2 of 5", len(page), total)
+	}
+	if page[0].Content != "e" || page[1].Content != "d" {
+		t.Fatalf("first page = %q, %q; want newest first", page[0].Content, page[1].Content)
+	}
+	if page[0].User.Username != "alice" {
+		t.Fatalf("author not loaded: %+v", page[0].User)
+	}
+
+	last, _, err := repos.Messages.ListByRoom(ctx, room.ID, 2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(last) != 1 || last[0].Content != "a" {
+		t.Fatalf("last page = %+v, want the oldest message", last)
+	}
+}
+
+func TestRoomDelete(t *testing.T) {
+	db := testdb.New(t)
+	repos := repository.NewGorm(db)
+	ctx := context.Background()
+
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+	room := &models.Room{Name: "general"}
+	seed(t, db, user, room)
+	for i := 0; i < 3; i++ {
+		msg := &models.Message{UserID: user.ID, RoomID: room.ID, Content: "hi"}
+		if err := repos.Messages.Create(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.User.ID != user.ID {
+			t.Fatalf("Create did not load the author")
+		}
+	}
+
+	deleted, err := repos.Rooms.Delete(ctx, room)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Fatalf("deleted %d messages, want 3", deleted)
+	}
+	if _, err := repos.Rooms.GetByID(ctx, room.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("GetByID(deleted room) = %v, want ErrNotFound", err)
+	}
+	if _, total, _ := repos.Messages.ListByRoom(ctx, room.ID, 10, 0); total != 0 {
+		t.Fatalf("%d messages left in deleted room", total)
+	}
+}
@@ -0,0 +1,68 @@
+// Package repository defines the data access interfaces for users, rooms and
+// messages that handlers depend on, so they can run against any database
+// (Postgres in production, SQLite for tests and local development).
+package repository
+
+import (
+	"chat-backend-go/models"
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when the requested record doesn't exist.
+var ErrNotFound = errors.New("record not found")
+
+// UserRepository reads and creates user accounts and their identities.
+type UserRepository interface {
+	// GetByID returns the user with the given ID.
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	// GetByEmail returns the user with the given email address.
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// GetByUsername returns the user with the given username.
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	// Create stores a new user and sets its ID.
+	Create(ctx context.Context, user *models.User) error
+	// Search lists users other than excludeID ordered by username, optionally
+	// filtered by a case-insensitive substring of the username or email.
+	Search(ctx context.Context, excludeID uint, query string) ([]models.User, error)
+	// ClaimInvite uses one slot of the unexpired, unrevoked invite with the
+	// given code hash that allows email, or returns ErrNotFound if there is none.
+	ClaimInvite(ctx context.Context, codeHash, email string) error
+	// CreateIdentity stores a provider identity linked to a user.
+	CreateIdentity(ctx context.Context, identity *models.UserIdentity) error
+	// WithTx runs fn with a repository bound to one transaction, which is
+	// committed if fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(users UserRepository) error) error
+}
+
+// RoomRepository reads and moderates chat rooms.
+type RoomRepository interface {
+	// List returns all rooms.
+	List(ctx context.Context) ([]models.Room, error)
+	// GetByID returns the room with the given ID.
+	GetByID(ctx context.Context, id uint) (*models.Room, error)
+	// SetSlowMode updates the room's slow mode interval.
+	SetSlowMode(ctx context.Context, room *models.Room, seconds int) error
+	// Delete removes the room and its messages, returning how many messages were removed.
+	Delete(ctx context.Context, room *models.Room) (int64, error)
+}
+
+// MessageRepository stores chat messages.
+type MessageRepository interface {
+	// Create stores a message and loads its author.
+	Create(ctx context.Context, message *models.Message) error
+	// GetByID returns the message with the given ID.
+	GetByID(ctx context.Context, id uint) (*models.Message, error)
+	// ListByRoom returns one page of a room's messages, newest first, with
+	// their authors, and the room's total message count.
+	ListByRoom(ctx context.Context, roomID uint, limit, offset int) ([]models.Message, int64, error)
+	// Delete removes a message.
+	Delete(ctx context.Context, message *models.Message) error
+}
+
+// Repositories bundles the repositories handlers are built with.
+type Repositories struct {
+	Users    UserRepository
+	Rooms    RoomRepository
+	Messages MessageRepository
+}
@@ -0,0 +1,29 @@
+package routes
+
+import (
+	"chat-backend-go/handlers"
+	"chat-backend-go/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminRoutes exposes administration endpoints restricted to admin users
+func AdminRoutes(app *fiber.App) {
+	admin := app.Group("/api/admin", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity(), middleware.RequireAdmin())
+
+	// User roles ("user", "moderator", "admin")
+	admin.Put("/users/:id/role", handlers.SetUserRole)
+
+	// Readiness report with each check's error and details
+	admin.Get("/health", handlers.ReadyzDetails)
+
+	// Audit log of security and moderation events
+	admin.Get("/audit-logs", handlers.ListAuditLogs)
+	admin.Get("/audit-logs/export", handlers.ExportAuditLogs)
+
+	// Sign-up invites (SIGNUP_MODE=invite)
+	invites := admin.Group("/invites")
+	invites.Get("/", handlers.ListInvites)
+	invites.Post("/", handlers.CreateInvite)
+	invites.Delete("/:id", handlers.RevokeInvite)
+}
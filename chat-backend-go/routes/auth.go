@@ -5,44 +5,88 @@ package routes
 import (
 	"chat-backend-go/handlers"
 	"chat-backend-go/middleware"
-	"chat-backend-go/utils"
+	"chat-backend-go/ratelimit"
+	"chat-backend-go/repository"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// SetupAuthRoutes sets up authentication related routes
-func SetupAuthRoutes(app *fiber.App) {
+// SetupAuthRoutes sets up authentication related routes; sign-up and sign-in
+// find and create users through repos
+func SetupAuthRoutes(app *fiber.App, repos *repository.Repositories) {
+    h := handlers.NewAuthHandler(repos.Users)
+
+    // Public verification keys for other services
+    app.Get("/.well-known/jwks.json", handlers.JWKS)
+
     // Create auth group
     auth := app.Group("/api/auth")
 
-    // Public routes (no authentication required)
-    auth.Post("/register", handlers.Register)
-    auth.Post("/login", handlers.Login)
+    // Public routes (no authentication required); per-IP rate limits are
+    // overridable with RATE_LIMIT_<NAME>, see ratelimit.RuleFromEnv
+    auth.Post("/register", middleware.RateLimit(ratelimit.RuleFromEnv("register_ip", 5, time.Hour)), h.Register)
+    auth.Post("/login", middleware.RateLimit(ratelimit.RuleFromEnv("login_ip", 20, time.Minute)), h.Login)
+    // Token renewal (authenticated by the refresh token itself)
+    auth.Post("/refresh", h.RefreshToken)
+    auth.Post("/logout", handlers.Logout)
+    // Password recovery
+    auth.Post("/password/forgot", middleware.RateLimit(ratelimit.RuleFromEnv("password_forgot_ip", 5, 15*time.Minute)), h.ForgotPassword)
+    auth.Post("/password/reset", middleware.RateLimit(ratelimit.RuleFromEnv("password_reset_ip", 10, 15*time.Minute)), handlers.ResetPassword)
+    // Second step of a 2FA login
+    auth.Post("/2fa/verify", middleware.RateLimit(ratelimit.RuleFromEnv("two_factor_ip", 10, time.Minute)), handlers.VerifyTwoFactorLogin)
+    // Email verification
+    auth.Get("/email/verify", handlers.VerifyEmail)
+    auth.Post("/email/verify", handlers.VerifyEmail)
+    auth.Post("/email/resend", middleware.RateLimit(ratelimit.RuleFromEnv("email_resend_ip", 5, 15*time.Minute)), middleware.OptionalAuth(), h.ResendVerification)
+    // External identity providers (GitHub, OpenID Connect)
+    auth.Get("/providers", handlers.ListProviders)
+    auth.Get("/providers/:provider/login", handlers.ProviderLogin)
+    auth.Get("/providers/:provider/callback", h.ProviderCallback)
     // OAuth with GitHub (web)
     auth.Get("/github/login", handlers.GitHubLogin)
-    auth.Get("/github/callback", handlers.GitHubCallback)
+    auth.Get("/github/callback", h.GitHubCallback)
     auth.Get("/github/status", handlers.GitHubConfigStatus)
 
     // OAuth with GitHub (Device Flow for CLI)
-    auth.Post("/github/device/start", handlers.GitHubDeviceStart)
-    auth.Post("/github/device/poll", handlers.GitHubDevicePoll)
+    auth.Post("/github/device/start", middleware.RateLimit(ratelimit.RuleFromEnv("device_start_ip", 10, 15*time.Minute)), handlers.GitHubDeviceStart)
+    auth.Post("/github/device/poll", middleware.RateLimit(ratelimit.RuleFromEnv("device_poll_ip", 60, time.Minute)), h.GitHubDevicePoll)
 
     // Protected routes (authentication required with activity tracking)
-    auth.Get("/profile", middleware.AuthRequired(), middleware.TrackActivity(), handlers.GetProfile)
-	auth.Post("/refresh", middleware.AuthRequired(), middleware.TrackActivity(), func(c *fiber.Ctx) error {
-		// Get user ID from middleware
-		userID := c.Locals("userID").(uint)
-
-		// Generate new token
-		token, err := utils.GenerateJWT(userID)
-		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "Failed to refresh token",
-			})
-		}
-
-		return c.JSON(fiber.Map{
-			"token": token,
-		})
-	})
+    auth.Get("/profile", middleware.AuthRequired(), middleware.TrackActivity(), h.GetProfile)
+    auth.Post("/password/change", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity(), middleware.RequireVerifiedEmail(), handlers.ChangePassword)
+
+    // Two-factor authentication management
+    twoFactor := auth.Group("/2fa", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity())
+    twoFactor.Get("/", handlers.TwoFactorStatus)
+    twoFactor.Post("/enroll", handlers.EnrollTwoFactor)
+    twoFactor.Post("/confirm", handlers.ConfirmTwoFactor)
+    twoFactor.Post("/recovery-codes", handlers.RegenerateRecoveryCodes)
+    twoFactor.Post("/disable", handlers.DisableTwoFactor)
+
+    // Session management
+    sessions := auth.Group("/sessions", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity())
+    sessions.Get("/", handlers.ListSessions)
+    sessions.Delete("/", handlers.RevokeOtherSessions)
+    sessions.Delete("/:id", handlers.RevokeSession)
+
+    // Personal access tokens (managed only from an interactive login)
+    tokens := auth.Group("/tokens", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity())
+    tokens.Get("/", handlers.ListTokens)
+    tokens.Post("/", middleware.RequireVerifiedEmail(), handlers.CreateToken)
+    tokens.Delete("/:id", handlers.RevokeToken)
+
+    // Linked identity providers
+    identities := auth.Group("/identities", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity())
+    identities.Get("/", handlers.ListIdentities)
+    identities.Post("/:provider/link", handlers.LinkIdentity)
+    identities.Delete("/:provider", handlers.UnlinkIdentity)
+
+    // Bot accounts owned by the current user
+    bots := auth.Group("/bots", middleware.AuthRequired(), middleware.RequireSession(), middleware.TrackActivity(), middleware.RequireVerifiedEmail())
+    bots.Get("/", handlers.ListBots)
+    bots.Post("/", handlers.CreateBot)
+    bots.Delete("/:id", handlers.DeleteBot)
+    bots.Get("/:id/tokens", handlers.ListBotTokens)
+    bots.Post("/:id/tokens", handlers.CreateBotToken)
 }
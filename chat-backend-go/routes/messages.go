@@ -3,18 +3,27 @@ package routes
 import (
 	"chat-backend-go/handlers"
 	"chat-backend-go/middleware"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func MessageRoutes(app *fiber.App) {
+// MessageRoutes exposes room and message endpoints backed by repos
+func MessageRoutes(app *fiber.App, repos *repository.Repositories) {
+	h := handlers.NewMessageHandler(repos)
 	api := app.Group("/api/v1")
 
-	// Public routes
-	api.Get("/rooms", handlers.GetRooms)
+	// Public routes (personal access tokens still need the rooms:read scope)
+	api.Get("/rooms", middleware.OptionalAuth(), middleware.RequireScope(utils.ScopeRoomsRead), h.GetRooms)
 
 	// Protected routes (require authentication and track activity)
-	protected := api.Use(middleware.AuthRequired(), middleware.TrackActivity())
-	protected.Post("/messages", handlers.SendMessage)
-	protected.Get("/rooms/:roomId/messages", handlers.GetMessages)
+	protected := api.Use(middleware.AuthRequired(), middleware.TrackActivity(), middleware.RequireVerifiedEmail())
+	protected.Post("/messages", middleware.RequireScope(utils.ScopeMessagesWrite), h.SendMessage)
+	protected.Get("/rooms/:roomId/messages", middleware.RequireScope(utils.ScopeRoomsRead, utils.ScopeMessagesRead), h.GetMessages)
+	protected.Delete("/messages/:id", middleware.RequireScope(utils.ScopeMessagesWrite), h.DeleteMessage)
+
+	// Moderation
+	protected.Put("/rooms/:roomId/slow-mode", middleware.RequireSession(), middleware.RequireRole("admin", "moderator"), h.SetSlowMode)
+	protected.Delete("/rooms/:roomId", middleware.RequireSession(), middleware.RequireRole("admin"), h.DeleteRoom)
 }
@@ -3,14 +3,17 @@ package routes
 import (
 	"chat-backend-go/handlers"
 	"chat-backend-go/middleware"
+	"chat-backend-go/repository"
+	"chat-backend-go/utils"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // UserRoutes exposes user directory endpoints required by the CLI
-func UserRoutes(app *fiber.App) {
+func UserRoutes(app *fiber.App, repos *repository.Repositories) {
+	h := handlers.NewUserHandler(repos.Users)
 	api := app.Group("/api/v1")
 	// Apply activity tracking to all authenticated routes
-	users := api.Group("/users", middleware.AuthRequired(), middleware.TrackActivity())
-	users.Get("/", handlers.ListUsers)
+	users := api.Group("/users", middleware.AuthRequired(), middleware.TrackActivity(), middleware.RequireVerifiedEmail())
+	users.Get("/", middleware.RequireScope(utils.ScopeUsersRead), h.ListUsers)
 }
@@ -0,0 +1,127 @@
+// Package tracing sets up OpenTelemetry tracing. Every HTTP request gets a
+// server span; GORM queries and calls to identity providers made with the
+// request's context (c.UserContext()) become its children, so a slow request
+// shows where its time went. Spans are exported over OTLP or written as JSON
+// for local use. Tracing is off unless OTEL_TRACES_EXPORTER is set, and the
+// instrumentation then records nothing.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the default service.name; OTEL_SERVICE_NAME overrides it.
+const serviceName = "windgo-chat"
+
+// tracer follows the global provider, so spans started before Setup (or
+// without it, in tests) cost nothing.
+var tracer = otel.Tracer("chat-backend-go")
+
+// Setup installs the global tracer provider selected by OTEL_TRACES_EXPORTER:
+//
+//	otlp    OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_* variables
+//	stdout  one JSON document per span on standard output, or in the file
+//	        named by OTEL_TRACES_FILE
+//	none    no tracing (the default)
+//
+// Incoming W3C traceparent headers are honored and sampling follows
+// OTEL_TRACES_SAMPLER. The returned function flushes pending spans and stops
+// the exporter.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var (
+		option sdktrace.TracerProviderOption
+		closer io.Closer
+	)
+	switch exporter := strings.ToLower(os.Getenv("OTEL_TRACES_EXPORTER")); exporter {
+	case "", "none":
+		return noop, nil
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return noop, fmt.Errorf("create OTLP exporter: %w", err)
+		}
+		option = sdktrace.WithBatcher(exp)
+	case "stdout", "console":
+		var w io.Writer = os.Stdout
+		if path := os.Getenv("OTEL_TRACES_FILE"); path != "" {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+			if err != nil {
+				return noop, fmt.Errorf("open OTEL_TRACES_FILE: %w", err)
+			}
+			w, closer = f, f
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return noop, fmt.Errorf("create stdout exporter: %w", err)
+		}
+		// Write each span as it ends; local runs should not lose the last batch
+		option = sdktrace.WithSyncer(exp)
+	default:
+		return noop, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q (use otlp, stdout or none)", exporter)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("build resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(option, sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Start starts an internal span as a child of the span in ctx, for work worth
+// timing on its own (hashing a password, say). End the returned span.
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name)
+}
+
+// InstrumentClient wraps client's transport so that every request made with
+// a traced context gets a client span and carries a traceparent header. The
+// client is modified and returned.
+func InstrumentClient(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Host + r.URL.Path
+		}),
+		// Untraced calls (background work, startup discovery) stay untraced
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return trace.SpanContextFromContext(r.Context()).IsValid()
+		}),
+	)
+	return client
+}
@@ -0,0 +1,182 @@
+package tracing
+
+import (
+	"chat-backend-go/apierror"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// exporter collects every span ended during the tests. The package tracer
+// binds to the first global provider, so there is one for the whole run.
+var exporter = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	os.Exit(m.Run())
+}
+
+// spans returns the spans ended since the last call.
+func spans(t *testing.T) tracetest.SpanStubs {
+	t.Helper()
+	defer exporter.Reset()
+	return exporter.GetSpans()
+}
+
+func attr(span tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestMiddlewareContinuesTraceAndNamesSpanByRoute(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: apierror.Handler})
+	app.Use(Middleware())
+	app.Get("/rooms/:id", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+	app.Get("/boom", func(c *fiber.Ctx) error {
+		return apierror.Internal("Internal server error")
+	})
+	spans(t)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/rooms/42", nil)
+	req.Header.Set("traceparent", traceparent)
+	if _, err := app.Test(req, -1); err != nil {
+		t.Fatal(err)
+	}
+	got := spans(t)
+	if len(got) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(got))
+	}
+	span := got[0]
+	if span.Name != "GET /rooms/:id" || span.SpanKind != trace.SpanKindServer {
+		t.Errorf("span = %q (%v), want server span GET /rooms/:id", span.Name, span.SpanKind)
+	}
+	if span.SpanContext.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" || span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("span did not continue the incoming trace: trace %s, parent %s", span.SpanContext.TraceID(), span.Parent.SpanID())
+	}
+	if attr(span, "http.response.status_code").AsInt64() != 200 || attr(span, "url.path").AsString() != "/rooms/42" {
+		t.Errorf("attributes = %v", span.Attributes)
+	}
+
+	if _, err := app.Test(httptest.NewRequest(http.MethodGet, "/boom", nil), -1); err != nil {
+		t.Fatal(err)
+	}
+	got = spans(t)
+	if len(got) != 1 || got[0].Status.Code != codes.Error || attr(got[0], "http.response.status_code").AsInt64() != 500 {
+		t.Errorf("failed request span = %+v", got)
+	}
+}
+
+type widget struct {
+	ID   uint
+	Name string
+}
+
+func TestGormPluginTracesStatementsWithoutValues(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(GormPlugin()); err != nil {
+		t.Fatal(err)
+	}
+	// Without a trace in the context nothing is recorded
+	if err := db.AutoMigrate(&widget{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := spans(t); len(got) != 0 {
+		t.Fatalf("untraced statements recorded %d spans", len(got))
+	}
+
+	ctx, parent := Start(context.Background(), "request")
+	tx := db.WithContext(ctx)
+	if err := tx.Create(&widget{Name: "secret-name"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var missing widget
+	if err := tx.First(&missing, 999).Error; err != gorm.ErrRecordNotFound {
+		t.Fatalf("First = %v, want ErrRecordNotFound", err)
+	}
+	parent.End()
+
+	got := spans(t)
+	if len(got) != 3 {
+		t.Fatalf("recorded %d spans, want 3: %v", len(got), got)
+	}
+	insert, query := got[0], got[1]
+	if insert.Name != "insert widgets" || query.Name != "select widgets" {
+		t.Errorf("span names = %q, %q", insert.Name, query.Name)
+	}
+	for _, span := range []tracetest.SpanStub{insert, query} {
+		if span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the request span", span.Name)
+		}
+		if attr(span, "db.system.name").AsString() != "sqlite" {
+			t.Errorf("%s attributes = %v", span.Name, span.Attributes)
+		}
+	}
+	if sql := attr(insert, "db.query.text").AsString(); !strings.Contains(sql, "INSERT INTO") || strings.Contains(sql, "secret-name") {
+		t.Errorf("insert SQL = %q, want placeholders only", sql)
+	}
+	if query.Status.Code == codes.Error {
+		t.Error("a missing row marked the span as failed")
+	}
+}
+
+func TestInstrumentClientPropagatesTrace(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("traceparent")
+	}))
+	defer server.Close()
+	client := InstrumentClient(&http.Client{})
+	spans(t)
+
+	get := func(ctx context.Context) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/user", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	get(context.Background())
+	if header != "" || len(spans(t)) != 0 {
+		t.Fatalf("untraced call was traced (traceparent %q)", header)
+	}
+
+	ctx, parent := Start(context.Background(), "request")
+	get(ctx)
+	parent.End()
+	got := spans(t)
+	if len(got) != 2 || !strings.HasSuffix(got[0].Name, "/user") || got[0].SpanKind != trace.SpanKindClient {
+		t.Fatalf("spans = %v, want a client span for /user", got)
+	}
+	if !strings.Contains(header, parent.SpanContext().TraceID().String()) {
+		t.Errorf("traceparent = %q, want trace %s", header, parent.SpanContext().TraceID())
+	}
+}
@@ -0,0 +1,51 @@
+// Package utils provides helpers shared by handlers and middleware.
+// This file writes the audit log of security and moderation events.
+package utils
+
+import (
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"context"
+)
+
+// Audit actions. Names are "<area>.<event>" so they can be filtered by prefix.
+const (
+	AuditLogin              = "auth.login"
+	AuditLoginFailed        = "auth.login_failed"
+	AuditAccountLocked      = "auth.account_locked"
+	AuditLogout             = "auth.logout"
+	AuditRefreshTokenReused = "auth.refresh_token_reused"
+	AuditPasswordChanged    = "auth.password_changed"
+	AuditPasswordReset      = "auth.password_reset"
+	AuditAccessDenied       = "auth.access_denied"
+	AuditIdentityLinked     = "identity.linked"
+	AuditIdentityUnlinked   = "identity.unlinked"
+	AuditSessionRevoked     = "session.revoked"
+	AuditTokenRevoked       = "token.revoked"
+	AuditBotDeleted         = "bot.deleted"
+	AuditRoleChanged        = "user.role_changed"
+	AuditInviteCreated      = "invite.created"
+	AuditInviteRevoked      = "invite.revoked"
+	AuditRoomDeleted        = "room.deleted"
+	AuditSlowModeChanged    = "room.slow_mode_changed"
+	AuditMessageDeleted     = "message.deleted"
+	AuditUserMuted          = "moderation.user_muted"
+)
+
+// maxAuditUserAgentLength keeps oversized headers out of the log.
+const maxAuditUserAgentLength = 255
+
+// RecordAudit appends an entry to the audit log, filling in the actor's
+// username when only the ID is set.
+func RecordAudit(ctx context.Context, entry *models.AuditLog) error {
+	if entry.ActorID != nil && entry.ActorName == "" {
+		var actor models.User
+		if err := config.DB.WithContext(ctx).Select("id", "username").First(&actor, *entry.ActorID).Error; err == nil {
+			entry.ActorName = actor.Username
+		}
+	}
+	if len(entry.UserAgent) > maxAuditUserAgentLength {
+		entry.UserAgent = entry.UserAgent[:maxAuditUserAgentLength]
+	}
+	return config.DB.WithContext(ctx).Create(entry).Error
+}
@@ -2,92 +2,118 @@ package utils
 
 import (
 	"errors"
-	"log"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 type Claims struct {
-	UserID uint `json:"user_id"`
+	UserID    uint   `json:"user_id"`
+	SessionID string `json:"sid"`
+	// Purpose is empty for access tokens and set for single-purpose tokens
+	// such as 2FA login challenges, which must never be accepted as access tokens
+	Purpose string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// getJWTSecret returns the JWT secret from environment or a secure default
-func getJWTSecret() string {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Println("Warning: JWT_SECRET not set, using default secret. Set JWT_SECRET environment variable for production!")
-		jwtSecret = "windgo-chat-default-secret-please-change-in-production-2024"
-	}
-	return jwtSecret
-}
-
-// GenerateJWT creates a new JWT token for a user
-func GenerateJWT(userID uint) (string, error) {
-	// Get JWT secret
-	jwtSecret := getJWTSecret()
+const (
+	purposeTwoFactorChallenge = "2fa_challenge"
+	// TwoFactorChallengeTTL is how long a user has to enter their 2FA code after the password step
+	TwoFactorChallengeTTL = 5 * time.Minute
+)
 
+// GenerateJWT creates a new access token for a user's session and returns it
+// together with its unique token ID (jti)
+func GenerateJWT(userID uint, sessionID string) (string, string, error) {
 	// Create claims
+	tokenID := uuid.NewString()
 	claims := Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // Token expires in 24 hours
+			ID:        tokenID,
+			Issuer:    jwtIssuer(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL())), // Short-lived; renewed with a refresh token
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign token with secret
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	// Sign token with the currently scheduled key
+	tokenString, err := signToken(claims)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return tokenString, nil
+	return tokenString, tokenID, nil
 }
 
-// ValidateJWT validates a JWT token and returns the user ID
-func ValidateJWT(tokenString string) (uint, error) {
-	// Get JWT secret
-	jwtSecret := getJWTSecret()
-
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("invalid signing method")
-		}
-		return []byte(jwtSecret), nil
-	})
+// ParseJWT validates a JWT token and returns its claims
+func ParseJWT(tokenString string) (*Claims, error) {
+	// Parse token; keyFunc picks the verification key by kid and checks the algorithm
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keyFunc)
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	// Extract claims
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims.UserID, nil
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.Purpose == "" {
+		return claims, nil
 	}
 
-	return 0, errors.New("invalid token")
+	return nil, errors.New("invalid token")
+}
+
+// GenerateChallengeJWT issues a short-lived token proving the password step of a 2FA login
+func GenerateChallengeJWT(userID uint) (string, error) {
+	return generatePurposeJWT(userID, purposeTwoFactorChallenge, TwoFactorChallengeTTL)
+}
+
+// ParseChallengeJWT validates a 2FA challenge token and returns the user ID
+// and the challenge's token ID (jti), which identifies it for single use
+func ParseChallengeJWT(tokenString string) (uint, string, error) {
+	claims, err := parsePurposeClaims(tokenString, purposeTwoFactorChallenge)
+	if err != nil {
+		return 0, "", err
+	}
+	return claims.UserID, claims.ID, nil
 }
 
-// ExtractUserID extracts user ID from Authorization header
-func ExtractUserID(authHeader string) (uint, error) {
-	if len(authHeader) < 7 || authHeader[:7] != "Bearer " {
-		return 0, errors.New("invalid authorization header format")
+// generatePurposeJWT signs a single-purpose token; see Claims.Purpose
+func generatePurposeJWT(userID uint, purpose string, ttl time.Duration) (string, error) {
+	claims := Claims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    jwtIssuer(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
 	}
+	return signToken(claims)
+}
 
-	tokenString := authHeader[7:]
-	return ValidateJWT(tokenString)
+// parsePurposeClaims validates a single-purpose token and returns its claims
+func parsePurposeClaims(tokenString, purpose string) (*Claims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && claims.Purpose == purpose && claims.ID != "" {
+		return claims, nil
+	}
+	return nil, errors.New("invalid " + purpose + " token")
 }
 
-// RefreshToken generates a new token for an existing user
-func RefreshToken(userID uint) (string, error) {
-	return GenerateJWT(userID)
+// jwtIssuer returns the "iss" claim from JWT_ISSUER (default "windgo-chat")
+func jwtIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "windgo-chat"
 }
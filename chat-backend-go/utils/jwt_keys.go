@@ -0,0 +1,408 @@
+// Package utils provides helpers shared by handlers and middleware.
+// This file manages JWT signing keys: RS256/EdDSA keys loaded from PEM files,
+// scheduled rotation selected by "kid", the public JWKS, and the legacy HS256 secret.
+package utils
+
+import (
+	"chat-backend-go/config"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const defaultJWTSecret = "windgo-chat-default-secret-please-change-in-production-2024"
+
+// publicJWTSecrets are secrets published in this repository (the built-in
+// default and the .env.example value), refused in production.
+var publicJWTSecrets = []string{defaultJWTSecret, "change-me-in-production"}
+
+// signingKey is one entry of the key set. Verify-only keys have no private half.
+type signingKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+	ActiveFrom time.Time
+	RetireAt   time.Time // zero means never
+}
+
+func (k *signingKey) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && !now.Before(k.RetireAt)
+}
+
+// keySet holds asymmetric keys ordered by activation time plus the optional
+// HS256 secret. With asymmetric keys the secret only verifies tokens until
+// secretUntil, to let sessions signed before the migration run out.
+type keySet struct {
+	keys        []*signingKey
+	secret      []byte
+	secretUntil time.Time
+}
+
+// keyManifest is the JSON format of JWT_KEYS_FILE.
+type keyManifest struct {
+	Keys []struct {
+		ID             string `json:"kid"`
+		PrivateKeyFile string `json:"private_key_file"`
+		PublicKeyFile  string `json:"public_key_file"`
+		ActiveFrom     string `json:"active_from"`
+		RetireAt       string `json:"retire_at"`
+	} `json:"keys"`
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+var (
+	keysMu     sync.RWMutex
+	loadedKeys *keySet
+)
+
+// LoadSigningKeys reads the signing configuration from the environment:
+//
+//   - JWT_KEYS_FILE: JSON manifest of RS256/EdDSA keys with activation and retirement times
+//   - JWT_PRIVATE_KEY_FILE (+ optional JWT_KEY_ID): a single RS256/EdDSA signing key
+//   - JWT_SECRET: HS256 secret, used only when no asymmetric key is configured
+//   - JWT_HS256_ACCEPT_UNTIL: RFC 3339 time until which HS256 tokens signed
+//     with JWT_SECRET are still accepted after moving to asymmetric keys
+//
+// With none of these set the server falls back to a built-in HS256 secret.
+// APP_ENV=production refuses that secret and the one from .env.example.
+func LoadSigningKeys() error {
+	ks, err := loadKeySet()
+	if err != nil {
+		return err
+	}
+	keysMu.Lock()
+	loadedKeys = ks
+	keysMu.Unlock()
+	return nil
+}
+
+func currentKeySet() (*keySet, error) {
+	keysMu.RLock()
+	ks := loadedKeys
+	keysMu.RUnlock()
+	if ks != nil {
+		return ks, nil
+	}
+	if err := LoadSigningKeys(); err != nil {
+		return nil, err
+	}
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return loadedKeys, nil
+}
+
+func loadKeySet() (*keySet, error) {
+	ks := &keySet{}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if config.IsProduction() && slices.Contains(publicJWTSecrets, secret) {
+			return nil, errors.New("JWT_SECRET is the published example value; set a secret of your own in production")
+		}
+		ks.secret = []byte(secret)
+	}
+
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		keys, err := loadKeyManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys = append(ks.keys, keys...)
+	} else if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		key, err := loadKeyFile(path, true)
+		if err != nil {
+			return nil, err
+		}
+		if kid := os.Getenv("JWT_KEY_ID"); kid != "" {
+			key.ID = kid
+		}
+		ks.keys = append(ks.keys, key)
+	}
+
+	// Once asymmetric keys exist, HS256 tokens are only accepted during an
+	// explicit transition window
+	if len(ks.keys) > 0 && ks.secret != nil {
+		if until := os.Getenv("JWT_HS256_ACCEPT_UNTIL"); until != "" {
+			t, err := time.Parse(time.RFC3339, until)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JWT_HS256_ACCEPT_UNTIL: %w", err)
+			}
+			ks.secretUntil = t
+			slog.Warn("Accepting HS256 tokens signed with JWT_SECRET during the key migration", "until", t)
+		} else {
+			slog.Info("Ignoring JWT_SECRET: asymmetric signing keys are configured")
+			ks.secret = nil
+		}
+	}
+
+	if len(ks.keys) == 0 && ks.secret == nil {
+		if config.IsProduction() {
+			return nil, errors.New("no JWT signing key configured: set JWT_KEYS_FILE, JWT_PRIVATE_KEY_FILE or JWT_SECRET in production")
+		}
+		slog.Warn("No JWT signing key configured, using default HS256 secret. Set JWT_PRIVATE_KEY_FILE or JWT_KEYS_FILE for production!")
+		ks.secret = []byte(defaultJWTSecret)
+	}
+
+	sort.SliceStable(ks.keys, func(i, j int) bool {
+		return ks.keys[i].ActiveFrom.Before(ks.keys[j].ActiveFrom)
+	})
+
+	seen := map[string]bool{}
+	for _, k := range ks.keys {
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate JWT key id %q", k.ID)
+		}
+		seen[k.ID] = true
+	}
+
+	if len(ks.keys) > 0 {
+		if _, err := ks.signingKey(time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+func loadKeyManifest(path string) ([]*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT_KEYS_FILE: %w", err)
+	}
+	var manifest keyManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parse JWT_KEYS_FILE: %w", err)
+	}
+
+	base := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(base, p)
+	}
+
+	var keys []*signingKey
+	for i, entry := range manifest.Keys {
+		var key *signingKey
+		switch {
+		case entry.PrivateKeyFile != "":
+			key, err = loadKeyFile(resolve(entry.PrivateKeyFile), true)
+		case entry.PublicKeyFile != "":
+			key, err = loadKeyFile(resolve(entry.PublicKeyFile), false)
+		default:
+			err = errors.New("private_key_file or public_key_file is required")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWT key #%d: %w", i+1, err)
+		}
+		if entry.ID != "" {
+			key.ID = entry.ID
+		}
+		if entry.ActiveFrom != "" {
+			if key.ActiveFrom, err = time.Parse(time.RFC3339, entry.ActiveFrom); err != nil {
+				return nil, fmt.Errorf("JWT key %q: invalid active_from: %w", key.ID, err)
+			}
+		}
+		if entry.RetireAt != "" {
+			if key.RetireAt, err = time.Parse(time.RFC3339, entry.RetireAt); err != nil {
+				return nil, fmt.Errorf("JWT key %q: invalid retire_at: %w", key.ID, err)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// loadKeyFile parses an RSA or Ed25519 key from PEM. The kid defaults to the
+// RFC 7638 thumbprint of the public key.
+func loadKeyFile(path string, private bool) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	key := &signingKey{}
+	if private {
+		var parsed any
+		if parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+				return nil, fmt.Errorf("%s: unsupported private key: %w", path, err)
+			}
+		}
+		switch k := parsed.(type) {
+		case *rsa.PrivateKey:
+			key.PrivateKey, key.PublicKey = k, &k.PublicKey
+		case ed25519.PrivateKey:
+			key.PrivateKey, key.PublicKey = k, k.Public()
+		default:
+			return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+		}
+	} else {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: unsupported public key: %w", path, err)
+		}
+		key.PublicKey = parsed
+	}
+
+	switch key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%s: only RSA and Ed25519 keys are supported", path)
+	}
+
+	jwk := publicJWK(key)
+	key.ID = jwkThumbprint(jwk)
+	return key, nil
+}
+
+// signingKey returns the newest key that is active, not retired and has a private half.
+func (ks *keySet) signingKey(now time.Time) (*signingKey, error) {
+	for i := len(ks.keys) - 1; i >= 0; i-- {
+		k := ks.keys[i]
+		if k.PrivateKey != nil && !k.ActiveFrom.After(now) && !k.retired(now) {
+			return k, nil
+		}
+	}
+	if len(ks.keys) == 0 && ks.secret != nil {
+		return nil, nil
+	}
+	return nil, errors.New("no active JWT signing key")
+}
+
+// verificationKey selects the key for a token by its kid and algorithm. Tokens
+// without a kid are HS256 tokens and need the shared secret, which stops
+// working at secretUntil when asymmetric keys are configured.
+func (ks *keySet) verificationKey(token *jwt.Token, now time.Time) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok || ks.secret == nil {
+			return nil, errors.New("invalid signing method")
+		}
+		if len(ks.keys) > 0 && !now.Before(ks.secretUntil) {
+			return nil, errors.New("HS256 tokens are no longer accepted")
+		}
+		return ks.secret, nil
+	}
+	for _, k := range ks.keys {
+		if k.ID != kid {
+			continue
+		}
+		if k.retired(now) {
+			return nil, errors.New("signing key has been retired")
+		}
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, errors.New("invalid signing method")
+		}
+		return k.PublicKey, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// signToken signs claims with the currently scheduled key.
+func signToken(claims jwt.Claims) (string, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return "", err
+	}
+	key, err := ks.signingKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+	if key == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// keyFunc resolves verification keys for jwt.Parse.
+func keyFunc(token *jwt.Token) (interface{}, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+	return ks.verificationKey(token, time.Now())
+}
+
+// PublicJWKS returns every non-retired asymmetric key, including keys scheduled
+// for future activation so verifiers can cache them before they are used.
+func PublicJWKS() ([]JWK, error) {
+	ks, err := currentKeySet()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	keys := []JWK{}
+	for _, k := range ks.keys {
+		if !k.retired(now) {
+			keys = append(keys, publicJWK(k))
+		}
+	}
+	return keys, nil
+}
+
+func publicJWK(k *signingKey) JWK {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	}
+	return jwk
+}
+
+// jwkThumbprint computes the RFC 7638 thumbprint of a public JWK.
+func jwkThumbprint(jwk JWK) string {
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
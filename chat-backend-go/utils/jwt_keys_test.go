@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// writeKey writes a new Ed25519 private key as PKCS#8 PEM into dir.
+func writeKey(t *testing.T, dir, name string) string {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// setKeyEnv clears the signing configuration, applies env and reloads the keys.
+func setKeyEnv(t *testing.T, env map[string]string) error {
+	t.Helper()
+	for _, key := range []string{"APP_ENV", "JWT_SECRET", "JWT_KEYS_FILE", "JWT_PRIVATE_KEY_FILE", "JWT_KEY_ID", "JWT_HS256_ACCEPT_UNTIL"} {
+		t.Setenv(key, env[key])
+	}
+	t.Cleanup(func() {
+		keysMu.Lock()
+		loadedKeys = nil
+		keysMu.Unlock()
+	})
+	return LoadSigningKeys()
+}
+
+// hs256Token signs an access token the way the server did before it had
+// asymmetric keys: HS256 and no kid.
+func hs256Token(t *testing.T, secret string) string {
+	t.Helper()
+	claims := Claims{
+		UserID:    1,
+		SessionID: "s",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestSigningKeySelectionAndRetirement(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "old.pem")
+	writeKey(t, dir, "current.pem")
+	writeKey(t, dir, "next.pem")
+	now := time.Now().UTC()
+	manifest := map[string]any{"keys": []map[string]string{
+		{"kid": "old", "private_key_file": "old.pem", "active_from": now.Add(-48 * time.Hour).Format(time.RFC3339), "retire_at": now.Add(-time.Hour).Format(time.RFC3339)},
+		{"kid": "current", "private_key_file": "current.pem", "active_from": now.Add(-24 * time.Hour).Format(time.RFC3339)},
+		{"kid": "next", "private_key_file": "next.pem", "active_from": now.Add(24 * time.Hour).Format(time.RFC3339)},
+	}}
+	data, _ := json.Marshal(manifest)
+	path := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := setKeyEnv(t, map[string]string{"JWT_KEYS_FILE": path}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens are signed with the newest active key
+	token, _, err := GenerateJWT(1, "s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "current" || parsed.Method.Alg() != "EdDSA" {
+		t.Fatalf("signed with kid %v (%s), want current (EdDSA)", parsed.Header["kid"], parsed.Method.Alg())
+	}
+	if _, err := ParseJWT(token); err != nil {
+		t.Fatalf("ParseJWT: %v", err)
+	}
+
+	// The JWKS publishes the current and the upcoming key, not the retired one
+	jwks, err := PublicJWKS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kids []string
+	for _, k := range jwks {
+		kids = append(kids, k.Kid)
+	}
+	if strings.Join(kids, ",") != "current,next" {
+		t.Fatalf("JWKS kids = %v", kids)
+	}
+
+	// Tokens naming a retired key are rejected even with a valid signature
+	ks, _ := currentKeySet()
+	retired := jwt.NewWithClaims(jwt.SigningMethodEdDSA, Claims{UserID: 1, SessionID: "s"})
+	retired.Header["kid"] = "old"
+	signed, err := retired.SignedString(ks.keys[0].PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseJWT(signed); err == nil || !strings.Contains(err.Error(), "retired") {
+		t.Fatalf("token of retired key: err = %v", err)
+	}
+}
+
+func TestHS256Fallback(t *testing.T) {
+	const secret = "test-secret-for-hs256"
+	keyFile := writeKey(t, t.TempDir(), "key.pem")
+
+	tests := []struct {
+		name   string
+		env    map[string]string
+		accept bool
+	}{
+		{"secret only", map[string]string{"JWT_SECRET": secret}, true},
+		{"asymmetric key", map[string]string{"JWT_SECRET": secret, "JWT_PRIVATE_KEY_FILE": keyFile}, false},
+		{"transition window open", map[string]string{
+			"JWT_SECRET": secret, "JWT_PRIVATE_KEY_FILE": keyFile,
+			"JWT_HS256_ACCEPT_UNTIL": time.Now().Add(time.Hour).Format(time.RFC3339),
+		}, true},
+		{"transition window over", map[string]string{
+			"JWT_SECRET": secret, "JWT_PRIVATE_KEY_FILE": keyFile,
+			"JWT_HS256_ACCEPT_UNTIL": time.Now().Add(-time.Hour).Format(time.RFC3339),
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := setKeyEnv(t, tt.env); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ParseJWT(hs256Token(t, secret))
+			if accepted := err == nil; accepted != tt.accept {
+				t.Fatalf("HS256 token accepted = %v (err %v), want %v", accepted, err, tt.accept)
+			}
+		})
+	}
+
+	bad := map[string]string{"JWT_SECRET": secret, "JWT_PRIVATE_KEY_FILE": keyFile, "JWT_HS256_ACCEPT_UNTIL": "soon"}
+	if err := setKeyEnv(t, bad); err == nil {
+		t.Error("malformed JWT_HS256_ACCEPT_UNTIL accepted")
+	}
+}
+
+func TestProductionRefusesPublishedSecrets(t *testing.T) {
+	for _, secret := range []string{"", "change-me-in-production", defaultJWTSecret} {
+		if err := setKeyEnv(t, map[string]string{"APP_ENV": "production", "JWT_SECRET": secret}); err == nil {
+			t.Errorf("JWT_SECRET=%q accepted in production", secret)
+		}
+	}
+	if err := setKeyEnv(t, map[string]string{"APP_ENV": "production", "JWT_SECRET": "a-real-secret-from-the-vault"}); err != nil {
+		t.Errorf("own secret refused in production: %v", err)
+	}
+}
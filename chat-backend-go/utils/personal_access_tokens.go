@@ -0,0 +1,130 @@
+// Package utils provides helpers shared by handlers and middleware.
+// This file manages personal access tokens (PATs): long-lived, scoped API
+// credentials for scripts, CI jobs and bot accounts.
+package utils
+
+import (
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PersonalAccessTokenPrefix marks PATs so they can be told apart from JWTs
+// (and spotted by secret scanners).
+const PersonalAccessTokenPrefix = "wgp_"
+
+// Scopes that can be granted to a personal access token.
+const (
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+	ScopeRoomsRead     = "rooms:read"
+	ScopeUsersRead     = "users:read"
+)
+
+var knownScopes = map[string]bool{
+	ScopeMessagesRead:  true,
+	ScopeMessagesWrite: true,
+	ScopeRoomsRead:     true,
+	ScopeUsersRead:     true,
+}
+
+// ErrInvalidPersonalAccessToken means the token is unknown, revoked or expired.
+var ErrInvalidPersonalAccessToken = errors.New("invalid personal access token")
+
+// patTouchInterval limits how often last-use bookkeeping writes to the database.
+const patTouchInterval = time.Minute
+
+// KnownScopes returns every grantable scope, sorted.
+func KnownScopes() []string {
+	scopes := make([]string, 0, len(knownScopes))
+	for scope := range knownScopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// IsKnownScope reports whether scope can be granted to a token.
+func IsKnownScope(scope string) bool {
+	return knownScopes[scope]
+}
+
+// IsPersonalAccessToken reports whether a bearer token looks like a PAT.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// IssuePersonalAccessToken creates a token for the user. The raw token is
+// returned once and only its hash is stored.
+func IssuePersonalAccessToken(ctx context.Context, userID uint, name string, scopes []string, expiresAt time.Time) (string, *models.PersonalAccessToken, error) {
+	raw, _, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", nil, err
+	}
+	token := PersonalAccessTokenPrefix + raw
+
+	pat := models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: HashToken(token),
+		Prefix:    token[:len(PersonalAccessTokenPrefix)+6],
+		Scopes:    strings.Join(scopes, " "),
+		ExpiresAt: expiresAt,
+	}
+	if err := config.DB.WithContext(ctx).Create(&pat).Error; err != nil {
+		return "", nil, err
+	}
+	pat.ScopeList = scopes
+	return token, &pat, nil
+}
+
+// ValidatePersonalAccessToken looks up an active token and records its use, at
+// most once per patTouchInterval.
+func ValidatePersonalAccessToken(ctx context.Context, token string) (*models.PersonalAccessToken, error) {
+	var pat models.PersonalAccessToken
+	err := config.DB.WithContext(ctx).
+		Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", HashToken(token), time.Now()).
+		First(&pat).Error
+	if err != nil {
+		return nil, ErrInvalidPersonalAccessToken
+	}
+
+	if pat.LastUsedAt == nil || time.Since(*pat.LastUsedAt) >= patTouchInterval {
+		config.DB.WithContext(ctx).Model(&pat).Update("last_used_at", time.Now())
+	}
+	pat.ScopeList = strings.Fields(pat.Scopes)
+	return &pat, nil
+}
+
+// ListPersonalAccessTokens returns the active tokens belonging to the given users, newest first.
+func ListPersonalAccessTokens(ctx context.Context, userIDs ...uint) ([]models.PersonalAccessToken, error) {
+	var tokens []models.PersonalAccessToken
+	err := config.DB.WithContext(ctx).
+		Where("user_id IN ? AND revoked_at IS NULL AND expires_at > ?", userIDs, time.Now()).
+		Order("created_at DESC").
+		Find(&tokens).Error
+	for i := range tokens {
+		tokens[i].ScopeList = strings.Fields(tokens[i].Scopes)
+	}
+	return tokens, err
+}
+
+// RevokePersonalAccessToken revokes a token owned by one of the given users.
+// It reports false if no such active token exists.
+func RevokePersonalAccessToken(ctx context.Context, id uint, userIDs ...uint) (bool, error) {
+	result := config.DB.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("id = ? AND user_id IN ? AND revoked_at IS NULL", id, userIDs).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// RevokeAllPersonalAccessTokens revokes every token belonging to the user.
+func RevokeAllPersonalAccessTokens(ctx context.Context, userID uint) error {
+	return config.DB.WithContext(ctx).Model(&models.PersonalAccessToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -6,27 +6,21 @@ package utils
 import (
 	"chat-backend-go/config"
 	"chat-backend-go/models"
+	"context"
 	"time"
 )
 
-// GetUserByEmail - Optimized query using email index
-func GetUserByEmail(email string) (*models.User, error) {
-	var user models.User
-	err := config.DB.Where("email = ?", email).First(&user).Error
-	return &user, err
-}
-
 // GetUserByUsername - Optimized query using username index
-func GetUserByUsername(username string) (*models.User, error) {
+func GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
-	err := config.DB.Where("username = ?", username).First(&user).Error
+	err := config.DB.WithContext(ctx).Where("username = ?", username).First(&user).Error
 	return &user, err
 }
 
 // GetRecentMessages - Optimized query using room and created_at indexes
-func GetRecentMessages(roomID uint, limit int) ([]models.Message, error) {
+func GetRecentMessages(ctx context.Context, roomID uint, limit int) ([]models.Message, error) {
 	var messages []models.Message
-	err := config.DB.Where("room_id = ?", roomID).
+	err := config.DB.WithContext(ctx).Where("room_id = ?", roomID).
 		Order("created_at DESC").
 		Limit(limit).
 		Preload("User").
@@ -35,9 +29,9 @@ func GetRecentMessages(roomID uint, limit int) ([]models.Message, error) {
 }
 
 // GetMessagesByUser - Optimized query using user index
-func GetMessagesByUser(userID uint, limit int) ([]models.Message, error) {
+func GetMessagesByUser(ctx context.Context, userID uint, limit int) ([]models.Message, error) {
 	var messages []models.Message
-	err := config.DB.Where("user_id = ?", userID).
+	err := config.DB.WithContext(ctx).Where("user_id = ?", userID).
 		Order("created_at DESC").
 		Limit(limit).
 		Preload("Room").
@@ -46,33 +40,33 @@ func GetMessagesByUser(userID uint, limit int) ([]models.Message, error) {
 }
 
 // GetRoomByName - Optimized query using room name index
-func GetRoomByName(name string) (*models.Room, error) {
+func GetRoomByName(ctx context.Context, name string) (*models.Room, error) {
 	var room models.Room
-	err := config.DB.Where("name = ?", name).First(&room).Error
+	err := config.DB.WithContext(ctx).Where("name = ?", name).First(&room).Error
 	return &room, err
 }
 
 // GetRecentRooms - Optimized query using created_at index
-func GetRecentRooms(limit int) ([]models.Room, error) {
+func GetRecentRooms(ctx context.Context, limit int) ([]models.Room, error) {
 	var rooms []models.Room
-	err := config.DB.Order("created_at DESC").
+	err := config.DB.WithContext(ctx).Order("created_at DESC").
 		Limit(limit).
 		Find(&rooms).Error
 	return rooms, err
 }
 
 // GetRoomWithRecentMessages - Optimized compound query
-func GetRoomWithRecentMessages(roomID uint, messageLimit int) (*models.Room, error) {
+func GetRoomWithRecentMessages(ctx context.Context, roomID uint, messageLimit int) (*models.Room, error) {
 	var room models.Room
 
 	// First get the room
-	err := config.DB.First(&room, roomID).Error
+	err := config.DB.WithContext(ctx).First(&room, roomID).Error
 	if err != nil {
 		return nil, err
 	}
 
 	// Then get recent messages using optimized query
-	err = config.DB.Where("room_id = ?", roomID).
+	err = config.DB.WithContext(ctx).Where("room_id = ?", roomID).
 		Order("created_at DESC").
 		Limit(messageLimit).
 		Preload("User").
@@ -82,9 +76,9 @@ func GetRoomWithRecentMessages(roomID uint, messageLimit int) (*models.Room, err
 }
 
 // GetMessagesInTimeRange - Optimized time range query
-func GetMessagesInTimeRange(roomID uint, start, end time.Time) ([]models.Message, error) {
+func GetMessagesInTimeRange(ctx context.Context, roomID uint, start, end time.Time) ([]models.Message, error) {
 	var messages []models.Message
-	err := config.DB.Where("room_id = ? AND created_at BETWEEN ? AND ?", roomID, start, end).
+	err := config.DB.WithContext(ctx).Where("room_id = ? AND created_at BETWEEN ? AND ?", roomID, start, end).
 		Order("created_at ASC").
 		Preload("User").
 		Find(&messages).Error
@@ -92,12 +86,12 @@ func GetMessagesInTimeRange(roomID uint, start, end time.Time) ([]models.Message
 }
 
 // GetUserStats - Efficient aggregation query
-func GetUserStats(userID uint) (map[string]interface{}, error) {
+func GetUserStats(ctx context.Context, userID uint) (map[string]interface{}, error) {
 	var stats map[string]interface{} = make(map[string]interface{})
 
 	// Count total messages
 	var messageCount int64
-	err := config.DB.Model(&models.Message{}).Where("user_id = ?", userID).Count(&messageCount).Error
+	err := config.DB.WithContext(ctx).Model(&models.Message{}).Where("user_id = ?", userID).Count(&messageCount).Error
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +99,7 @@ func GetUserStats(userID uint) (map[string]interface{}, error) {
 
 	// Get first message date
 	var firstMessage models.Message
-	err = config.DB.Where("user_id = ?", userID).Order("created_at ASC").First(&firstMessage).Error
+	err = config.DB.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").First(&firstMessage).Error
 	if err == nil {
 		stats["member_since"] = firstMessage.CreatedAt
 	}
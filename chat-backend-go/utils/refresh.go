@@ -0,0 +1,129 @@
+// Package utils provides helpers shared by handlers and middleware.
+// This file implements opaque, rotating refresh tokens with reuse detection.
+package utils
+
+import (
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+)
+
+var (
+	// ErrInvalidRefreshToken means the token is unknown or expired.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused means an already-rotated token was presented again;
+	// the whole token family has been revoked as a precaution.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// AccessTokenTTL returns the access token lifetime from ACCESS_TOKEN_TTL (default 15m).
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL returns the refresh token lifetime from REFRESH_TOKEN_TTL (default 30 days).
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		slog.Warn("Ignoring invalid duration", "env", key, "value", v, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+// IssueRefreshToken creates a refresh token in the given family. Each login
+// session owns one family, so familyID is the session ID.
+func IssueRefreshToken(ctx context.Context, userID uint, familyID string) (string, error) {
+	token, hash, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	refresh := models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}
+	if err := config.DB.WithContext(ctx).Create(&refresh).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family,
+// returning the user ID, the family (session) ID and the new token.
+// Presenting a token that was already rotated or revoked revokes the family;
+// the user and family IDs are still returned with ErrRefreshTokenReused.
+func RotateRefreshToken(ctx context.Context, token string) (uint, string, string, error) {
+	hash := HashToken(token)
+	now := time.Now()
+
+	// Atomically mark the token as rotated so concurrent use can't fork the family
+	db := config.DB.WithContext(ctx)
+	result := db.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND rotated_at IS NULL AND revoked_at IS NULL AND expires_at > ?", hash, now).
+		Update("rotated_at", now)
+	if result.Error != nil {
+		return 0, "", "", result.Error
+	}
+
+	var refresh models.RefreshToken
+	if err := db.Where("token_hash = ?", hash).First(&refresh).Error; err != nil {
+		return 0, "", "", ErrInvalidRefreshToken
+	}
+
+	if result.RowsAffected == 0 {
+		if refresh.RotatedAt != nil || refresh.RevokedAt != nil {
+			slog.WarnContext(ctx, "Refresh token reuse detected, revoking token family", "user_id", refresh.UserID)
+			if err := RevokeRefreshTokenFamily(ctx, refresh.FamilyID); err != nil {
+				return 0, "", "", err
+			}
+			return refresh.UserID, refresh.FamilyID, "", ErrRefreshTokenReused
+		}
+		return 0, "", "", ErrInvalidRefreshToken
+	}
+
+	next, err := IssueRefreshToken(ctx, refresh.UserID, refresh.FamilyID)
+	if err != nil {
+		return 0, "", "", err
+	}
+	return refresh.UserID, refresh.FamilyID, next, nil
+}
+
+// RevokeRefreshToken ends the session the given token belongs to (used on logout)
+// and returns the session's user ID.
+func RevokeRefreshToken(ctx context.Context, token string) (uint, error) {
+	var refresh models.RefreshToken
+	if err := config.DB.WithContext(ctx).Where("token_hash = ?", HashToken(token)).First(&refresh).Error; err != nil {
+		return 0, ErrInvalidRefreshToken
+	}
+	_, err := RevokeSession(ctx, refresh.UserID, refresh.FamilyID)
+	return refresh.UserID, err
+}
+
+// RevokeRefreshTokenFamily revokes every outstanding token in a family and the
+// session that owns it.
+func RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	if err := config.DB.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+	return config.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
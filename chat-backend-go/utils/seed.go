@@ -3,13 +3,17 @@ package utils
 import (
 	"chat-backend-go/config"
 	"chat-backend-go/models"
-	"log"
+	"log/slog"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // SeedDemoUsers creates demo users if they don't exist
 func SeedDemoUsers() {
+	// Demo accounts are pre-verified so they work with EMAIL_VERIFICATION enabled
+	verifiedAt := time.Now()
+
 	// Check if admin user exists
 	var adminUser models.User
 	err := config.DB.Where("email = ?", "admin@windgo.com").First(&adminUser).Error
@@ -17,15 +21,18 @@ func SeedDemoUsers() {
 		// Create admin user
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
 		admin := models.User{
-			Username: "admin",
-			Email:    "admin@windgo.com",
-			Password: string(hashedPassword),
-			Role:     "admin",
+			Username:    "admin",
+			Email:       "admin@windgo.com",
+			Password:    string(hashedPassword),
+			HasPassword: true,
+			Role:        "admin",
+
+			EmailVerifiedAt: &verifiedAt,
 		}
 		if err := config.DB.Create(&admin).Error; err != nil {
-			log.Printf("Failed to create admin user: %v", err)
+			slog.Error("Failed to create demo user", "username", admin.Username, "error", err)
 		} else {
-			log.Println("Admin user created: admin@windgo.com / admin123")
+			slog.Info("Created demo user", "username", admin.Username)
 		}
 	}
 
@@ -36,15 +43,18 @@ func SeedDemoUsers() {
 		// Create demo user
 		hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
 		demo := models.User{
-			Username: "demo",
-			Email:    "demo@windgo.com",
-			Password: string(hashedPassword),
-			Role:     "user",
+			Username:    "demo",
+			Email:       "demo@windgo.com",
+			Password:    string(hashedPassword),
+			HasPassword: true,
+			Role:        "user",
+
+			EmailVerifiedAt: &verifiedAt,
 		}
 		if err := config.DB.Create(&demo).Error; err != nil {
-			log.Printf("Failed to create demo user: %v", err)
+			slog.Error("Failed to create demo user", "username", demo.Username, "error", err)
 		} else {
-			log.Println("Demo user created: demo@windgo.com / admin123")
+			slog.Info("Created demo user", "username", demo.Username)
 		}
 	}
 
@@ -65,31 +75,39 @@ func SeedDemoUsers() {
 		if err != nil {
 			hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(userData.Password), bcrypt.DefaultCost)
 			newUser := models.User{
-				Username: userData.Username,
-				Email:    userData.Email,
-				Password: string(hashedPassword),
-				Role:     "user",
+				Username:    userData.Username,
+				Email:       userData.Email,
+				Password:    string(hashedPassword),
+				HasPassword: true,
+				Role:        "user",
+
+				EmailVerifiedAt: &verifiedAt,
 			}
 			if err := config.DB.Create(&newUser).Error; err != nil {
-				log.Printf("Failed to create user %s: %v", userData.Username, err)
+				slog.Error("Failed to create demo user", "username", userData.Username, "error", err)
 			} else {
-				log.Printf("User created: %s / %s", userData.Email, userData.Password)
+				slog.Info("Created demo user", "username", userData.Username)
 			}
 		}
 	}
 }
 
-// SeedDemoRooms creates demo chat rooms if they don't exist
+// SeedDemoRooms creates demo chat rooms if they don't exist, each with a
+// welcome message from the admin user when there is one
 func SeedDemoRooms() {
 	defaultRooms := []struct {
-		Name string
+		Name    string
+		Welcome string
 	}{
-		{"General"},
-		{"Random"},
-		{"Tech Talk"},
-		{"Gaming"},
+		{"General", "Welcome to WindGo Chat! This is the general discussion room where everyone can chat."},
+		{"Random", "Feel free to chat about anything here! This is our random discussion room."},
+		{"Tech Talk", "Share your tech knowledge and learn from others! Let's discuss programming, frameworks, and development."},
+		{"Gaming", "Talk about the games you're playing, find teammates and share your best moments."},
 	}
 
+	var admin models.User
+	hasAdmin := config.DB.Where("email = ?", "admin@windgo.com").First(&admin).Error == nil
+
 	for _, roomData := range defaultRooms {
 		var room models.Room
 		err := config.DB.Where("name = ?", roomData.Name).First(&room).Error
@@ -98,9 +116,16 @@ func SeedDemoRooms() {
 				Name: roomData.Name,
 			}
 			if err := config.DB.Create(&newRoom).Error; err != nil {
-				log.Printf("Failed to create room %s: %v", roomData.Name, err)
-			} else {
-				log.Printf("Room created: %s", roomData.Name)
+				slog.Error("Failed to create demo room", "room", roomData.Name, "error", err)
+				continue
+			}
+			slog.Info("Created demo room", "room", roomData.Name)
+
+			if hasAdmin {
+				welcome := models.Message{UserID: admin.ID, RoomID: newRoom.ID, Content: roomData.Welcome}
+				if err := config.DB.Create(&welcome).Error; err != nil {
+					slog.Error("Failed to create welcome message", "room", roomData.Name, "error", err)
+				}
 			}
 		}
 	}
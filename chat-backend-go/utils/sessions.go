@@ -0,0 +1,147 @@
+// Package utils provides helpers shared by handlers and middleware.
+// This file manages server-side login sessions. Each session owns a refresh
+// token family (family ID == session ID) and is referenced by the "sid" claim
+// of every access token issued for it, so revoking it takes effect immediately.
+package utils
+
+import (
+	"chat-backend-go/config"
+	"chat-backend-go/models"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrSessionRevoked means the session behind a token was revoked, expired or never existed.
+var ErrSessionRevoked = errors.New("session has been revoked")
+
+// sessionTouchInterval limits how often last-use bookkeeping writes to the database.
+const sessionTouchInterval = time.Minute
+
+// SessionInfo describes the client that opened a session.
+type SessionInfo struct {
+	DeviceName string
+	UserAgent  string
+	IPAddress  string
+}
+
+// CreateSession records a new login for the user.
+func CreateSession(ctx context.Context, userID uint, info SessionInfo) (*models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		DeviceName: info.DeviceName,
+		UserAgent:  info.UserAgent,
+		IPAddress:  info.IPAddress,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(RefreshTokenTTL()),
+	}
+	if err := config.DB.WithContext(ctx).Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// IssueSessionTokens mints an access token for the session and records its ID.
+// The session's expiry is extended to match the newest refresh token.
+func IssueSessionTokens(ctx context.Context, session *models.Session) (string, error) {
+	token, tokenID, err := GenerateJWT(session.UserID, session.ID)
+	if err != nil {
+		return "", err
+	}
+	session.TokenID = tokenID
+	session.ExpiresAt = time.Now().Add(RefreshTokenTTL())
+	if err := config.DB.WithContext(ctx).Model(session).Updates(map[string]any{
+		"token_id":   session.TokenID,
+		"expires_at": session.ExpiresAt,
+	}).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateSession checks that the session referenced by the claims is still
+// active and that the token is the latest one issued for it, so access tokens
+// replaced by a refresh stop working. It records the session's use, at most
+// once per sessionTouchInterval.
+func ValidateSession(ctx context.Context, claims *Claims, ipAddress string) error {
+	if claims.SessionID == "" {
+		return ErrSessionRevoked
+	}
+
+	var session models.Session
+	err := config.DB.WithContext(ctx).
+		Where("id = ? AND user_id = ? AND token_id = ? AND revoked_at IS NULL AND expires_at > ?", claims.SessionID, claims.UserID, claims.ID, time.Now()).
+		First(&session).Error
+	if err != nil {
+		return ErrSessionRevoked
+	}
+
+	if time.Since(session.LastUsedAt) >= sessionTouchInterval || session.IPAddress != ipAddress {
+		config.DB.WithContext(ctx).Model(&session).Updates(map[string]any{
+			"last_used_at": time.Now(),
+			"ip_address":   ipAddress,
+		})
+	}
+	return nil
+}
+
+// SessionActive reports whether the user's session is neither revoked nor expired.
+func SessionActive(ctx context.Context, userID uint, sessionID string) bool {
+	var count int64
+	config.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", sessionID, userID, time.Now()).
+		Count(&count)
+	return count > 0
+}
+
+// ListSessions returns the user's active sessions, most recently used first.
+func ListSessions(ctx context.Context, userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := config.DB.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession revokes one of the user's sessions and its refresh tokens.
+// It reports false if no active session with that ID belongs to the user.
+func RevokeSession(ctx context.Context, userID uint, sessionID string) (bool, error) {
+	result := config.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionID, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	return true, RevokeRefreshTokenFamily(ctx, sessionID)
+}
+
+// RevokeOtherSessions revokes every session of the user except keepID and
+// returns how many were revoked.
+func RevokeOtherSessions(ctx context.Context, userID uint, keepID string) (int64, error) {
+	now := time.Now()
+	result := config.DB.WithContext(ctx).Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	err := config.DB.WithContext(ctx).Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", now).Error
+	return result.RowsAffected, err
+}
+
+// RevokeUserTokens revokes all of the user's sessions, which invalidates every
+// access token and refresh token issued to them.
+func RevokeUserTokens(ctx context.Context, userID uint) error {
+	_, err := RevokeOtherSessions(ctx, userID, "")
+	return err
+}
@@ -0,0 +1,198 @@
+// Package validation enforces the `validate` struct tags on request bodies and
+// reports every failing field in a validation.failed API error:
+//
+//	{"code": "validation.failed", "message": "email must be a valid email address",
+//	 "details": {"fields": [{"field": "email", "rule": "email", "message": "must be a valid email address"}]}}
+//
+// Tags use github.com/go-playground/validator syntax plus the rules registered
+// here: username and notblank.
+package validation
+
+import (
+	"chat-backend-go/apierror"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// MinUsernameLength and MaxUsernameLength bound the username rule.
+	MinUsernameLength = 3
+	MaxUsernameLength = 50
+)
+
+// usernamePattern allows letters, digits, '.', '_' and '-', starting with a
+// letter or digit so names can't be confused with flags or hidden files.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// FieldError describes one field that failed validation. Field is the JSON
+// name, with the path for nested fields (for example "scopes[2]").
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(jsonName)
+	must(v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return IsUsername(fl.Field().String())
+	}))
+	must(v.RegisterValidation("notblank", func(fl validator.FieldLevel) bool {
+		return strings.TrimSpace(fl.Field().String()) != ""
+	}))
+	return v
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// IsUsername reports whether name satisfies the username rule.
+func IsUsername(name string) bool {
+	return len(name) >= MinUsernameLength && len(name) <= MaxUsernameLength && usernamePattern.MatchString(name)
+}
+
+// SanitizeUsername turns a name from elsewhere (a provider handle, an email's
+// local part) into one that satisfies the username rule: characters the rule
+// doesn't allow become '_', leading punctuation is dropped, long names are
+// cut and short ones get a "user" prefix.
+func SanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)), r == '.', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	s := strings.TrimLeft(b.String(), "._-")
+	if len(s) > MaxUsernameLength {
+		s = s[:MaxUsernameLength]
+	}
+	if len(s) < MinUsernameLength {
+		s = strings.TrimSuffix("user_"+s, "_")
+	}
+	return s
+}
+
+// Parse decodes the request body into v and validates it. A body that can't be
+// decoded is a request.invalid_body error; invalid fields are validation.failed.
+func Parse(c *fiber.Ctx, v any) error {
+	if err := c.BodyParser(v); err != nil {
+		return apierror.InvalidBody
+	}
+	return Struct(v)
+}
+
+// Struct validates v, which must be a struct or a pointer to one, and returns
+// nil or a validation.failed *apierror.Error listing every invalid field.
+func Struct(v any) error {
+	err := validate.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		// Only reachable with a non-struct argument, which is a programming error
+		panic(err)
+	}
+
+	fields := make([]FieldError, 0, len(invalid))
+	messages := make([]string, 0, len(invalid))
+	for _, fe := range invalid {
+		field := fieldPath(fe)
+		message := describe(fe)
+		fields = append(fields, FieldError{Field: field, Rule: fe.Tag(), Message: message})
+		messages = append(messages, field+" "+message)
+	}
+	return apierror.Validation(strings.Join(messages, "; ")).WithDetails(map[string]any{
+		"fields": fields,
+	})
+}
+
+// fieldPath drops the struct name from the error's namespace, leaving the
+// JSON path of the field.
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+// describe turns a failed rule into a short phrase that follows the field name.
+func describe(fe validator.FieldError) string {
+	kind := fe.Kind()
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "required_without":
+		return "is required unless " + snakeCase(fe.Param()) + " is set"
+	case "notblank":
+		return "must not be blank"
+	case "email":
+		return "must be a valid email address"
+	case "username":
+		return fmt.Sprintf("must be %d to %d characters of letters, digits, '.', '_' or '-', starting with a letter or digit",
+			MinUsernameLength, MaxUsernameLength)
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "min", "gte":
+		switch kind {
+		case reflect.String:
+			return "must be at least " + fe.Param() + " characters"
+		case reflect.Slice, reflect.Map, reflect.Array:
+			return "must contain at least " + fe.Param() + " items"
+		}
+		return "must be at least " + fe.Param()
+	case "max", "lte":
+		switch kind {
+		case reflect.String:
+			return "must be at most " + fe.Param() + " characters"
+		case reflect.Slice, reflect.Map, reflect.Array:
+			return "must contain at most " + fe.Param() + " items"
+		}
+		return "must be at most " + fe.Param()
+	}
+	return "is invalid (" + fe.Tag() + ")"
+}
+
+// jsonName reports fields by their JSON name, as clients know them.
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return snakeCase(field.Name)
+	}
+	return name
+}
+
+// snakeCase converts a Go field name such as RecoveryCode to recovery_code.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
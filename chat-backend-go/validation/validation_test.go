@@ -0,0 +1,124 @@
+package validation
+
+import (
+	"chat-backend-go/apierror"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type signup struct {
+	Username     string   `json:"username" validate:"required,username"`
+	Email        string   `json:"email" validate:"required,email"`
+	Password     string   `json:"password" validate:"required,min=6,max=72"`
+	Content      string   `json:"content" validate:"omitempty,notblank,max=10"`
+	Tags         []string `json:"tags" validate:"omitempty,max=2,dive,max=3"`
+	Code         string   `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string   `json:"recovery_code"`
+}
+
+func fieldErrors(t *testing.T, err error) map[string]FieldError {
+	t.Helper()
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierror.CodeValidationFailed || apiErr.Status != 400 {
+		t.Fatalf("error = %v, want a validation.failed API error", err)
+	}
+	fields, ok := apiErr.Details["fields"].([]FieldError)
+	if !ok {
+		t.Fatalf("details = %v, want fields", apiErr.Details)
+	}
+	out := make(map[string]FieldError, len(fields))
+	for _, f := range fields {
+		out[f.Field] = f
+	}
+	return out
+}
+
+func TestStruct(t *testing.T) {
+	valid := signup{Username: "alice.b-2", Email: "alice@example.com", Password: "secret", Code: "123456"}
+	if err := Struct(&valid); err != nil {
+		t.Fatalf("valid request rejected: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(s *signup)
+		field  string
+		rule   string
+	}{
+		{"missing username", func(s *signup) { s.Username = "" }, "username", "required"},
+		{"short username", func(s *signup) { s.Username = "al" }, "username", "username"},
+		{"username with spaces", func(s *signup) { s.Username = "al ice" }, "username", "username"},
+		{"username starting with dash", func(s *signup) { s.Username = "-alice" }, "username", "username"},
+		{"long username", func(s *signup) { s.Username = strings.Repeat("a", MaxUsernameLength+1) }, "username", "username"},
+		{"invalid email", func(s *signup) { s.Email = "alice" }, "email", "email"},
+		{"short password", func(s *signup) { s.Password = "x" }, "password", "min"},
+		{"long password", func(s *signup) { s.Password = strings.Repeat("x", 73) }, "password", "max"},
+		{"blank content", func(s *signup) { s.Content = "   " }, "content", "notblank"},
+		{"long content", func(s *signup) { s.Content = "ééééééééééé" }, "content", "max"},
+		{"too many tags", func(s *signup) { s.Tags = []string{"a", "b", "c"} }, "tags", "max"},
+		{"long tag", func(s *signup) { s.Tags = []string{"a", "long"} }, "tags[1]", "max"},
+		{"no code", func(s *signup) { s.Code = "" }, "code", "required_without"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+			fields := fieldErrors(t, Struct(&req))
+			got, ok := fields[tt.field]
+			if !ok || got.Rule != tt.rule || got.Message == "" {
+				t.Fatalf("fields = %+v, want %s failing %s", fields, tt.field, tt.rule)
+			}
+		})
+	}
+
+	t.Run("multi-byte content within limit", func(t *testing.T) {
+		req := valid
+		req.Content = "éééééééééé"
+		if err := Struct(&req); err != nil {
+			t.Fatalf("10 runes rejected: %v", err)
+		}
+	})
+
+	t.Run("all failures reported", func(t *testing.T) {
+		err := Struct(&signup{})
+		fields := fieldErrors(t, err)
+		for _, name := range []string{"username", "email", "password", "code"} {
+			if _, ok := fields[name]; !ok {
+				t.Errorf("%s missing from %+v", name, fields)
+			}
+		}
+		if msg := err.(*apierror.Error).Message; !strings.Contains(msg, "email is required") {
+			t.Errorf("message = %q", msg)
+		}
+		if got := fields["code"].Message; got != "is required unless recovery_code is set" {
+			t.Errorf("code message = %q", got)
+		}
+	})
+}
+
+func TestSanitizeUsername(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"octocat", "octocat"},
+		{"alice.b-2", "alice.b-2"},
+		{"John Smith", "John_Smith"},
+		{"josé", "jos_"},
+		{"_hidden", "hidden"},
+		{"-.-", "user"},
+		{"", "user"},
+		{"ab", "user_ab"},
+		{"a+tag", "a_tag"},
+		{strings.Repeat("x", MaxUsernameLength+10), strings.Repeat("x", MaxUsernameLength)},
+	}
+	for _, tt := range tests {
+		got := SanitizeUsername(tt.in)
+		if got != tt.want {
+			t.Errorf("SanitizeUsername(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if !IsUsername(got) {
+			t.Errorf("SanitizeUsername(%q) = %q, which fails the username rule", tt.in, got)
+		}
+	}
+}
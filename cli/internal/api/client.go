@@ -5,16 +5,37 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Client knows how to talk to the WindGo backend API.
+// Client knows how to talk to the WindGo backend API. It holds the current
+// access/refresh token pair and renews it transparently when the server reports
+// that the access token has expired.
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// DeviceName labels the sessions this client opens (sent as X-Device-Name).
+	DeviceName string
+	// InviteCode is sent when starting a GitHub login on servers that require
+	// an invite to sign up (WINDGO_INVITE_CODE).
+	InviteCode string
+
+	mu           sync.RWMutex
+	token        string
+	refreshToken string
+	onRefresh    func(token, refreshToken string)
+
+	// refreshMu serializes refreshes: refresh tokens are single-use, so two
+	// concurrent refreshes would look like token reuse to the server.
+	refreshMu sync.Mutex
 }
 
 // NewClient constructs a client using WINDGO_BASE_URL or the local default.
@@ -24,34 +45,54 @@ func NewClient() *Client {
 		base = "http://localhost:8080"
 	}
 	base = strings.TrimRight(base, "/")
+	device := "WindGo CLI"
+	if host, err := os.Hostname(); err == nil && host != "" {
+		device += " on " + host
+	}
 	return &Client{
-		BaseURL: base,
+		BaseURL:    base,
+		DeviceName: device,
+		InviteCode: os.Getenv("WINDGO_INVITE_CODE"),
 		HTTPClient: &http.Client{
 			Timeout: 15 * time.Second,
 		},
 	}
 }
 
-// AuthResponse mirrors the backend login payload.
+// The types below mirror schemas in the backend's OpenAPI document
+// (chat-backend-go/openapi/openapi.json); TestClientMatchesOpenAPI checks them.
+
+// AuthResponse is the token pair the backend issues on sign-in and refresh.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         User   `json:"user"`
+}
+
+// LoginResponse is the reply to a password login. When the account has
+// two-factor authentication enabled, it carries only TwoFactorRequired and a
+// ChallengeToken to pass to VerifyTwoFactor.
+type LoginResponse struct {
+	AuthResponse
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
 }
 
 // User is a trimmed down view for the CLI.
 type User struct {
-	ID           uint       `json:"id"`
-	Username     string     `json:"username"`
-	Email        string     `json:"email"`
-	Role         string     `json:"role"`
-	Provider     string     `json:"provider"`
-	GitHubID     string     `json:"github_id"`
-	AvatarURL    string     `json:"avatar_url"`
-	LastActiveAt *time.Time `json:"last_active_at"` // NEW: Track user activity
-	IsOnline     bool       `json:"is_online"`      // NEW: Online status
-	Status       string     `json:"status"`         // NEW: User status (online/away/busy/offline)
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID              uint       `json:"id"`
+	Username        string     `json:"username"`
+	Email           string     `json:"email"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	Role            string     `json:"role"`
+	Provider        string     `json:"provider"`
+	AvatarURL       string     `json:"avatar_url"`
+	LastActiveAt    *time.Time `json:"last_active_at"` // NEW: Track user activity
+	IsOnline        bool       `json:"is_online"`      // NEW: Online status
+	Status          string     `json:"status"`         // NEW: User status (online/away/busy/offline)
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 }
 
 // Room represents a chat room from the API.
@@ -73,6 +114,18 @@ type Message struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Session is one of the user's active logins.
+type Session struct {
+	ID         string    `json:"id"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	Current    bool      `json:"current"`
+}
+
 // DeviceStartResponse is returned when initiating a GitHub device flow.
 type DeviceStartResponse struct {
 	DeviceCode              string `json:"device_code"`
@@ -83,183 +136,351 @@ type DeviceStartResponse struct {
 	Interval                int    `json:"interval"`
 }
 
-// APIError captures {"error":"..."} replies.
+// DevicePollResponse is the result of one device flow poll. Status is
+// "authorization_pending" or "slow_down" while the user hasn't finished
+// authorizing; poll again after Interval seconds. Once Status is empty the
+// embedded AuthResponse holds the issued tokens.
+type DevicePollResponse struct {
+	AuthResponse
+	Status   string `json:"status"`
+	Interval int    `json:"interval"`
+}
+
+// Pending reports whether the user has not yet authorized the device.
+func (r *DevicePollResponse) Pending() bool {
+	return r.Status != ""
+}
+
+// APIError is returned for replies with an HTTP error status. The backend
+// sends {"code", "message", "details", "request_id"}; branch on Code.
 type APIError struct {
-	Error string `json:"error"`
+	StatusCode int            `json:"-"`
+	Code       string         `json:"code"`
+	Message    string         `json:"message"`
+	Details    map[string]any `json:"details"`
+	RequestID  string         `json:"request_id"`
+	// RetryAfter is how long the server asked us to wait (429 responses)
+	RetryAfter time.Duration `json:"-"`
 }
 
-func (c *Client) postJSON(path string, reqBody any, v any) error {
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
+func (e *APIError) Error() string {
+	return e.Message
+}
 
-	req, err := http.NewRequest(http.MethodPost, c.BaseURL+path, bytes.NewReader(body))
-	if err != nil {
-		return err
+// Error codes the client reacts to. The server's full list is in the Error
+// schema of its OpenAPI document.
+const (
+	CodeTokenExpired        = "auth.token_expired"
+	CodeInvalidToken        = "auth.invalid_token"
+	CodeSessionRevoked      = "auth.session_revoked"
+	CodeUnauthenticated     = "auth.unauthenticated"
+	CodeInvalidRefreshToken = "auth.invalid_refresh_token"
+	CodeRefreshTokenReused  = "auth.refresh_token_reused"
+	CodeRateLimited         = "rate_limit.exceeded"
+	CodeAccountLocked       = "auth.account_locked"
+	CodeMessageThrottled    = "message.throttled"
+)
+
+// IsCode reports whether err is an *APIError with one of the given codes.
+func IsCode(err error, codes ...string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && slices.Contains(codes, apiErr.Code)
+}
+
+// NeedsLogin reports whether err means the stored session is gone and the
+// user has to sign in again. Expired access tokens are renewed by the client,
+// so they only surface here when the refresh itself failed.
+func NeedsLogin(err error) bool {
+	return errors.Is(err, ErrSessionExpired) || IsCode(err,
+		CodeTokenExpired, CodeInvalidToken, CodeSessionRevoked, CodeUnauthenticated,
+		CodeInvalidRefreshToken, CodeRefreshTokenReused)
+}
+
+// ErrSessionExpired is returned when a request needs a refresh but the client
+// has no refresh token.
+var ErrSessionExpired = errors.New("session expired, please sign in again")
+
+// SetTokens installs the access and refresh tokens used for authenticated calls.
+func (c *Client) SetTokens(token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.refreshToken = refreshToken
+}
+
+// Tokens returns the current access and refresh tokens.
+func (c *Client) Tokens() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token, c.refreshToken
+}
+
+// OnTokenRefresh registers a callback invoked after tokens are renewed, so
+// callers can persist the new pair.
+func (c *Client) OnTokenRefresh(fn func(token, refreshToken string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRefresh = fn
+}
+
+// do sends a JSON request and decodes the JSON reply into v. Authenticated
+// requests rejected with auth.token_expired are retried once after refreshing
+// the tokens; other 401s (revoked session, bad token) are returned as is.
+func (c *Client) do(method, path string, reqBody any, v any, authenticated bool) error {
+	var body []byte
+	if reqBody != nil {
+		var err error
+		if body, err = json.Marshal(reqBody); err != nil {
+			return err
+		}
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.HTTPClient.Do(req)
+	token, refreshToken := c.Tokens()
+	resp, err := c.send(method, path, body, token, authenticated)
 	if err != nil {
 		return err
 	}
+	if authenticated && resp.StatusCode == http.StatusUnauthorized && refreshToken != "" {
+		apiErr := decodeError(resp)
+		resp.Body.Close()
+		if !IsCode(apiErr, CodeTokenExpired) {
+			return apiErr
+		}
+		if err := c.refresh(token); err != nil {
+			return err
+		}
+		token, _ = c.Tokens()
+		if resp, err = c.send(method, path, body, token, authenticated); err != nil {
+			return err
+		}
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		var apiErr APIError
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
-			return fmt.Errorf("api error: %s", resp.Status)
-		}
-		return errors.New(apiErr.Error)
+		return decodeError(resp)
 	}
-
 	if v != nil {
 		return json.NewDecoder(resp.Body).Decode(v)
 	}
 	return nil
 }
 
-// Login performs email/password authentication.
-func (c *Client) Login(email, password string) (*AuthResponse, error) {
-	var resp AuthResponse
-	err := c.postJSON("/api/auth/login", map[string]string{
-		"email":    email,
-		"password": password,
-	}, &resp)
+func (c *Client) send(method, path string, body []byte, token string, authenticated bool) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
 	if err != nil {
 		return nil, err
 	}
-	return &resp, nil
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", "windgo-cli")
+	if c.DeviceName != "" {
+		req.Header.Set("X-Device-Name", c.DeviceName)
+	}
+	if authenticated {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.HTTPClient.Do(req)
 }
 
-// StartDeviceFlow kicks off the GitHub OAuth device flow.
-func (c *Client) StartDeviceFlow() (*DeviceStartResponse, error) {
-	var resp DeviceStartResponse
-	err := c.postJSON("/api/auth/github/device/start", map[string]any{}, &resp)
-	if err != nil {
-		return nil, err
+func decodeError(resp *http.Response) error {
+	apiErr := &APIError{}
+	if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil {
+		apiErr = &APIError{}
+	}
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.Message == "" {
+		apiErr.Message = "api error: " + resp.Status
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
 	}
-	return &resp, nil
+	return apiErr
 }
 
-// PollDevice waits for the GitHub device flow to complete.
-func (c *Client) PollDevice(deviceCode string, timeoutSeconds int) (*AuthResponse, error) {
-	if timeoutSeconds <= 0 {
-		timeoutSeconds = 90
+// refresh exchanges the refresh token for a new pair. staleToken is the access
+// token that was rejected; if another goroutine already replaced it, the new
+// token is reused instead of spending the refresh token again.
+func (c *Client) refresh(staleToken string) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	token, refreshToken := c.Tokens()
+	if token != staleToken {
+		return nil
 	}
-	var resp AuthResponse
-	err := c.postJSON("/api/auth/github/device/poll", map[string]any{
-		"device_code": deviceCode,
-		"timeout":     timeoutSeconds,
-		"interval":    5,
-	}, &resp)
-	if err != nil {
-		return nil, err
+	if refreshToken == "" {
+		return ErrSessionExpired
 	}
-	return &resp, nil
-}
 
-// Profile fetches the authenticated user using a bearer token.
-func (c *Client) Profile(token string) (*User, error) {
-	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/api/auth/profile", nil)
-	if err != nil {
-		return nil, err
+	var resp AuthResponse
+	if err := c.do(http.MethodPost, "/api/auth/refresh", map[string]string{
+		"refresh_token": refreshToken,
+	}, &resp, false); err != nil {
+		// Only a rejected refresh token ends the session; timeouts and server
+		// errors leave it in place for the next attempt
+		if refreshRejected(err) {
+			c.SetTokens("", "")
+		}
+		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
+	c.SetTokens(resp.Token, resp.RefreshToken)
 
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
+	c.mu.RLock()
+	onRefresh := c.onRefresh
+	c.mu.RUnlock()
+	if onRefresh != nil {
+		onRefresh(resp.Token, resp.RefreshToken)
 	}
-	defer resp.Body.Close()
+	return nil
+}
 
-	if resp.StatusCode >= 400 {
-		var apiErr APIError
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
-			return nil, fmt.Errorf("api error: %s", resp.Status)
-		}
-		return nil, errors.New(apiErr.Error)
+// refreshRejected reports whether err means the server refused the refresh
+// token itself, as opposed to the request failing.
+func refreshRejected(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
 	}
+	return apiErr.StatusCode == http.StatusUnauthorized || IsCode(err, CodeInvalidRefreshToken, CodeRefreshTokenReused)
+}
 
-	var user User
-	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+// call sends a request and decodes the JSON reply into a new T.
+func call[T any](c *Client, method, path string, reqBody any, authenticated bool) (*T, error) {
+	var out T
+	if err := c.do(method, path, reqBody, &out, authenticated); err != nil {
 		return nil, err
 	}
-	return &user, nil
+	return &out, nil
 }
 
-// GetRooms fetches the list of available chat rooms using a bearer token.
-func (c *Client) GetRooms(token string) ([]Room, error) {
-	req, err := http.NewRequest(http.MethodGet, c.BaseURL+"/api/v1/rooms", nil)
-	if err != nil {
-		return nil, err
+// Reply envelopes of the list and send endpoints.
+type (
+	roomsResponse struct {
+		Rooms []Room `json:"rooms"`
+	}
+	usersResponse struct {
+		Users []User `json:"users"`
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
+	messagesResponse struct {
+		Messages []Message `json:"messages"`
+	}
+	sentMessageResponse struct {
+		Message string  `json:"message"`
+		Data    Message `json:"data"`
+	}
+	sessionsResponse struct {
+		Sessions []Session `json:"sessions"`
+	}
+)
 
-	resp, err := c.HTTPClient.Do(req)
+// Login performs email/password authentication and stores the issued tokens.
+// If resp.TwoFactorRequired is set, no tokens are issued until VerifyTwoFactor succeeds.
+func (c *Client) Login(email, password string) (*LoginResponse, error) {
+	resp, err := call[LoginResponse](c, http.MethodPost, "/api/auth/login", map[string]string{
+		"email":    email,
+		"password": password,
+	}, false)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		var apiErr APIError
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
-			return nil, fmt.Errorf("api error: %s", resp.Status)
-		}
-		return nil, errors.New(apiErr.Error)
+	if !resp.TwoFactorRequired {
+		c.SetTokens(resp.Token, resp.RefreshToken)
 	}
+	return resp, nil
+}
 
-	var response struct {
-		Rooms []Room `json:"rooms"`
+// VerifyTwoFactor completes a two-factor login with an authenticator code or,
+// if the code contains a dash, a recovery code, and stores the issued tokens.
+func (c *Client) VerifyTwoFactor(challengeToken, code string) (*AuthResponse, error) {
+	reqBody := map[string]string{"challenge_token": challengeToken}
+	if strings.Contains(code, "-") {
+		reqBody["recovery_code"] = code
+	} else {
+		reqBody["code"] = code
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+
+	resp, err := call[AuthResponse](c, http.MethodPost, "/api/auth/2fa/verify", reqBody, false)
+	if err != nil {
 		return nil, err
 	}
-	return response.Rooms, nil
+	c.SetTokens(resp.Token, resp.RefreshToken)
+	return resp, nil
 }
 
-// GetUsers fetches the list of available users using a bearer token.
-// Optionally filters by search query.
-func (c *Client) GetUsers(token, search string) ([]User, error) {
-	url := c.BaseURL + "/api/v1/users"
-	if search != "" {
-		url += "?search=" + search
+// Logout revokes the refresh token server-side and forgets the local tokens.
+func (c *Client) Logout() error {
+	_, refreshToken := c.Tokens()
+	c.SetTokens("", "")
+	if refreshToken == "" {
+		return nil
 	}
+	return c.do(http.MethodPost, "/api/auth/logout", map[string]string{
+		"refresh_token": refreshToken,
+	}, nil, false)
+}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+// StartDeviceFlow kicks off the GitHub OAuth device flow.
+func (c *Client) StartDeviceFlow() (*DeviceStartResponse, error) {
+	body := map[string]any{}
+	if c.InviteCode != "" {
+		body["invite_code"] = c.InviteCode
+	}
+	return call[DeviceStartResponse](c, http.MethodPost, "/api/auth/github/device/start", body, false)
+}
+
+// PollDevice checks once whether the GitHub device flow has completed. It
+// returns immediately; when the response is no longer pending the issued tokens
+// are stored.
+func (c *Client) PollDevice(deviceCode string) (*DevicePollResponse, error) {
+	resp, err := call[DevicePollResponse](c, http.MethodPost, "/api/auth/github/device/poll", map[string]string{
+		"device_code": deviceCode,
+	}, false)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
+	if !resp.Pending() {
+		c.SetTokens(resp.Token, resp.RefreshToken)
+	}
+	return resp, nil
+}
+
+// Profile fetches the authenticated user.
+func (c *Client) Profile() (*User, error) {
+	return call[User](c, http.MethodGet, "/api/auth/profile", nil, true)
+}
 
-	resp, err := c.HTTPClient.Do(req)
+// GetRooms fetches the list of available chat rooms.
+func (c *Client) GetRooms() ([]Room, error) {
+	resp, err := call[roomsResponse](c, http.MethodGet, "/api/v1/rooms", nil, true)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return resp.Rooms, nil
+}
 
-	if resp.StatusCode >= 400 {
-		var apiErr APIError
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
-			return nil, fmt.Errorf("api error: %s", resp.Status)
-		}
-		return nil, errors.New(apiErr.Error)
+// GetUsers fetches the list of available users.
+// Optionally filters by search query.
+func (c *Client) GetUsers(search string) ([]User, error) {
+	path := "/api/v1/users"
+	if search != "" {
+		path += "?search=" + url.QueryEscape(search)
 	}
 
-	var response struct {
-		Users []User `json:"users"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	resp, err := call[usersResponse](c, http.MethodGet, path, nil, true)
+	if err != nil {
 		return nil, err
 	}
-	return response.Users, nil
+	return resp.Users, nil
 }
 
-// GetMessages fetches messages for a specific room using a bearer token.
+// GetMessages fetches messages for a specific room.
 // Supports pagination with page and limit parameters.
-func (c *Client) GetMessages(token string, roomID uint, page, limit int) ([]Message, error) {
+func (c *Client) GetMessages(roomID uint, page, limit int) ([]Message, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -267,76 +488,41 @@ func (c *Client) GetMessages(token string, roomID uint, page, limit int) ([]Mess
 		limit = 50
 	}
 
-	url := fmt.Sprintf("%s/api/v1/rooms/%d/messages?page=%d&limit=%d", c.BaseURL, roomID, page, limit)
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	path := fmt.Sprintf("/api/v1/rooms/%d/messages?page=%d&limit=%d", roomID, page, limit)
+	resp, err := call[messagesResponse](c, http.MethodGet, path, nil, true)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		var apiErr APIError
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
-			return nil, fmt.Errorf("api error: %s", resp.Status)
-		}
-		return nil, errors.New(apiErr.Error)
-	}
-
-	var response struct {
-		Messages []Message `json:"messages"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
-	}
-	return response.Messages, nil
+	return resp.Messages, nil
 }
 
-// SendMessage sends a new message to a room using a bearer token.
-func (c *Client) SendMessage(token string, roomID uint, content string) (*Message, error) {
-	reqBody := map[string]any{
+// SendMessage sends a new message to a room.
+func (c *Client) SendMessage(roomID uint, content string) (*Message, error) {
+	resp, err := call[sentMessageResponse](c, http.MethodPost, "/api/v1/messages", map[string]any{
 		"room_id": roomID,
 		"content": content,
-	}
-
-	body, err := json.Marshal(reqBody)
+	}, true)
 	if err != nil {
 		return nil, err
 	}
+	return &resp.Data, nil
+}
 
-	req, err := http.NewRequest(http.MethodPost, c.BaseURL+"/api/v1/messages", bytes.NewReader(body))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := c.HTTPClient.Do(req)
+// ListSessions returns the user's active sessions.
+func (c *Client) ListSessions() ([]Session, error) {
+	resp, err := call[sessionsResponse](c, http.MethodGet, "/api/auth/sessions", nil, true)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return resp.Sessions, nil
+}
 
-	if resp.StatusCode >= 400 {
-		var apiErr APIError
-		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil || apiErr.Error == "" {
-			return nil, fmt.Errorf("api error: %s", resp.Status)
-		}
-		return nil, errors.New(apiErr.Error)
-	}
+// RevokeSession signs out one of the user's sessions.
+func (c *Client) RevokeSession(id string) error {
+	return c.do(http.MethodDelete, "/api/auth/sessions/"+url.PathEscape(id), nil, nil, true)
+}
 
-	var response struct {
-		Message string  `json:"message"`
-		Data    Message `json:"data"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, err
-	}
-	return &response.Data, nil
+// RevokeOtherSessions signs out every session except this client's.
+func (c *Client) RevokeOtherSessions() error {
+	return c.do(http.MethodDelete, "/api/auth/sessions", nil, nil, true)
 }
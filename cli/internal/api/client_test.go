@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// errorServer answers /api/v1/rooms with a 401 carrying code until the access
+// token has been refreshed, and counts the refreshes.
+func errorServer(t *testing.T, code string, refreshes *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/auth/refresh":
+			*refreshes++
+			w.Write([]byte(`{"token":"fresh","refresh_token":"refresh-2","expires_in":900}`))
+		case "/api/v1/rooms":
+			if r.Header.Get("Authorization") == "Bearer fresh" {
+				w.Write([]byte(`{"rooms":[]}`))
+				return
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"` + code + `","message":"rejected","details":{},"request_id":"req-1"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientRefreshesOnlyExpiredTokens(t *testing.T) {
+	t.Run("expired token is refreshed", func(t *testing.T) {
+		var refreshes int
+		c := NewClient()
+		c.BaseURL = errorServer(t, CodeTokenExpired, &refreshes).URL
+		c.SetTokens("stale", "refresh-1")
+
+		if _, err := c.GetRooms(); err != nil {
+			t.Fatalf("GetRooms: %v", err)
+		}
+		if token, refresh := c.Tokens(); refreshes != 1 || token != "fresh" || refresh != "refresh-2" {
+			t.Fatalf("refreshes = %d, tokens = %q %q", refreshes, token, refresh)
+		}
+	})
+
+	t.Run("revoked session needs login", func(t *testing.T) {
+		var refreshes int
+		c := NewClient()
+		c.BaseURL = errorServer(t, CodeSessionRevoked, &refreshes).URL
+		c.SetTokens("stale", "refresh-1")
+
+		_, err := c.GetRooms()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("GetRooms error = %v, want *APIError", err)
+		}
+		if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != CodeSessionRevoked || apiErr.RequestID != "req-1" {
+			t.Fatalf("APIError = %+v", apiErr)
+		}
+		if refreshes != 0 || !NeedsLogin(err) {
+			t.Fatalf("refreshes = %d, NeedsLogin = %v", refreshes, NeedsLogin(err))
+		}
+	})
+}
+
+func TestFailedRefreshKeepsTokensUnlessRejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		keep   bool
+	}{
+		{"server error", http.StatusServiceUnavailable, `{"code":"internal","message":"down"}`, true},
+		{"invalid refresh token", http.StatusUnauthorized, `{"code":"` + CodeInvalidRefreshToken + `","message":"invalid"}`, false},
+		{"reused refresh token", http.StatusUnauthorized, `{"code":"` + CodeRefreshTokenReused + `","message":"reused"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.URL.Path == "/api/auth/refresh" {
+					w.WriteHeader(tt.status)
+					w.Write([]byte(tt.body))
+					return
+				}
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"code":"` + CodeTokenExpired + `","message":"expired"}`))
+			}))
+			defer server.Close()
+			c := NewClient()
+			c.BaseURL = server.URL
+			c.SetTokens("stale", "refresh-1")
+
+			if _, err := c.GetRooms(); err == nil {
+				t.Fatal("GetRooms succeeded")
+			}
+			token, refresh := c.Tokens()
+			if kept := token == "stale" && refresh == "refresh-1"; kept != tt.keep {
+				t.Fatalf("tokens = %q %q, want kept = %v", token, refresh, tt.keep)
+			}
+		})
+	}
+
+	// A refresh that never reaches the server keeps the tokens too
+	c := NewClient()
+	c.BaseURL = "http://127.0.0.1:1"
+	c.SetTokens("stale", "refresh-1")
+	if err := c.refresh("stale"); err == nil {
+		t.Fatal("refresh succeeded")
+	}
+	if _, refresh := c.Tokens(); refresh != "refresh-1" {
+		t.Fatalf("refresh token = %q after a network error", refresh)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Retry-After", "7")
+	rec.WriteHeader(http.StatusTooManyRequests)
+	rec.Body.WriteString(`{"code":"message.throttled","message":"Slow mode is on","details":{"reason":"slow_mode","retry_after":7},"request_id":"abc"}`)
+
+	err := decodeError(rec.Result())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("decodeError = %T", err)
+	}
+	if apiErr.Code != CodeMessageThrottled || apiErr.Message != "Slow mode is on" || apiErr.Details["reason"] != "slow_mode" || apiErr.RetryAfter != 7*time.Second {
+		t.Fatalf("APIError = %+v", apiErr)
+	}
+
+	rec = httptest.NewRecorder()
+	rec.WriteHeader(http.StatusBadGateway)
+	rec.Body.WriteString("<html>bad gateway</html>")
+	if err := decodeError(rec.Result()); err.Error() != "api error: 502 Bad Gateway" || IsCode(err, CodeTokenExpired) {
+		t.Fatalf("non-JSON reply: %v", err)
+	}
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// specPath is the backend's OpenAPI document, relative to this package.
+const specPath = "../../../chat-backend-go/openapi/openapi.json"
+
+type specSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Format     string                 `json:"format"`
+	Properties map[string]*specSchema `json:"properties"`
+	Items      *specSchema            `json:"items"`
+	OneOf      []*specSchema          `json:"oneOf"`
+	Enum       []string               `json:"enum"`
+}
+
+type specContent map[string]struct {
+	Schema *specSchema `json:"schema"`
+}
+
+type specOperation struct {
+	RequestBody *struct {
+		Content specContent `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content specContent `json:"content"`
+	} `json:"responses"`
+}
+
+type specDoc struct {
+	Paths      map[string]map[string]specOperation `json:"paths"`
+	Components struct {
+		Schemas map[string]*specSchema `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) *specDoc {
+	t.Helper()
+	raw, err := os.ReadFile(specPath)
+	if os.IsNotExist(err) {
+		t.Skip("backend OpenAPI document not found; run from the full repository")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc specDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("parse %s: %v", specPath, err)
+	}
+	return &doc
+}
+
+// resolve follows $ref and flattens oneOf into the list of candidate schemas.
+func (d *specDoc) resolve(s *specSchema) []*specSchema {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		return d.resolve(d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")])
+	}
+	if len(s.OneOf) > 0 {
+		var out []*specSchema
+		for _, o := range s.OneOf {
+			out = append(out, d.resolve(o)...)
+		}
+		return out
+	}
+	return []*specSchema{s}
+}
+
+// operation finds the documented operation for a concrete request path.
+func (d *specDoc) operation(method, path string) (*specOperation, bool) {
+	segments := strings.Split(path, "/")
+	for template, ops := range d.Paths {
+		parts := strings.Split(template, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		match := true
+		for i, p := range parts {
+			if !strings.HasPrefix(p, "{") && p != segments[i] {
+				match = false
+				break
+			}
+		}
+		if op, ok := ops[strings.ToLower(method)]; match && ok {
+			return &op, true
+		}
+	}
+	return nil, false
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// checkType reports fields of typ that none of the candidate schemas document,
+// or whose JSON type disagrees with the schema.
+func (d *specDoc) checkType(where string, typ reflect.Type, candidates []*specSchema) []string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var schemas []*specSchema
+	for _, c := range candidates {
+		schemas = append(schemas, d.resolve(c)...)
+	}
+	if len(schemas) == 0 {
+		return []string{where + ": not documented"}
+	}
+
+	want := ""
+	switch {
+	case typ == timeType:
+		want = "string"
+	case typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map:
+		want = "object"
+	case typ.Kind() == reflect.Slice:
+		want = "array"
+	case typ.Kind() == reflect.String:
+		want = "string"
+	case typ.Kind() == reflect.Bool:
+		want = "boolean"
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		want = "integer"
+	}
+	var matching []*specSchema
+	for _, s := range schemas {
+		if s.Type == want {
+			matching = append(matching, s)
+		}
+	}
+	if len(matching) == 0 {
+		return []string{where + ": documented as " + schemas[0].Type + ", decoded as " + want}
+	}
+
+	var problems []string
+	switch {
+	case typ == timeType:
+		for _, s := range matching {
+			if s.Format == "date-time" {
+				return nil
+			}
+		}
+		return []string{where + ": documented without format date-time"}
+	case typ.Kind() == reflect.Slice:
+		var items []*specSchema
+		for _, s := range matching {
+			items = append(items, s.Items)
+		}
+		problems = d.checkType(where+"[]", typ.Elem(), items)
+	case typ.Kind() == reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if field.Anonymous {
+				problems = append(problems, d.checkType(where, field.Type, matching)...)
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			var props []*specSchema
+			for _, s := range matching {
+				if p, ok := s.Properties[name]; ok {
+					props = append(props, p)
+				}
+			}
+			if len(props) == 0 {
+				problems = append(problems, where+"."+name+": not documented")
+				continue
+			}
+			problems = append(problems, d.checkType(where+"."+name, field.Type, props)...)
+		}
+	}
+	return problems
+}
+
+type recordedRequest struct {
+	method, path string
+	body         map[string]any
+}
+
+// TestClientMatchesOpenAPI calls every Client method against a stub server and
+// checks the requests and reply types against the backend's OpenAPI document.
+func TestClientMatchesOpenAPI(t *testing.T) {
+	doc := loadSpec(t)
+
+	var mu sync.Mutex
+	var last *recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &recordedRequest{method: r.Method, path: r.URL.Path}
+		if raw, _ := io.ReadAll(r.Body); len(raw) > 0 {
+			json.Unmarshal(raw, &rec.body)
+		}
+		mu.Lock()
+		last = rec
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	calls := []struct {
+		name string
+		call func(c *Client) error
+		// reply is the type the method decodes the response into (nil for none)
+		reply any
+	}{
+		{"Login", func(c *Client) error { _, err := c.Login("a@example.com", "pw"); return err }, LoginResponse{}},
+		{"VerifyTwoFactor", func(c *Client) error { _, err := c.VerifyTwoFactor("challenge", "123456"); return err }, AuthResponse{}},
+		{"VerifyTwoFactor recovery", func(c *Client) error { _, err := c.VerifyTwoFactor("challenge", "abcd-efgh"); return err }, AuthResponse{}},
+		{"Logout", func(c *Client) error { return c.Logout() }, nil},
+		{"StartDeviceFlow", func(c *Client) error { _, err := c.StartDeviceFlow(); return err }, DeviceStartResponse{}},
+		{"PollDevice", func(c *Client) error { _, err := c.PollDevice("code"); return err }, DevicePollResponse{}},
+		{"Profile", func(c *Client) error { _, err := c.Profile(); return err }, User{}},
+		{"GetRooms", func(c *Client) error { _, err := c.GetRooms(); return err }, roomsResponse{}},
+		{"GetUsers", func(c *Client) error { _, err := c.GetUsers("al"); return err }, usersResponse{}},
+		{"GetMessages", func(c *Client) error { _, err := c.GetMessages(7, 2, 20); return err }, messagesResponse{}},
+		{"SendMessage", func(c *Client) error { _, err := c.SendMessage(7, "hi"); return err }, sentMessageResponse{}},
+		{"ListSessions", func(c *Client) error { _, err := c.ListSessions(); return err }, sessionsResponse{}},
+		{"RevokeSession", func(c *Client) error { return c.RevokeSession("b7c1") }, nil},
+		{"RevokeOtherSessions", func(c *Client) error { return c.RevokeOtherSessions() }, nil},
+		{"refresh", func(c *Client) error { return c.refresh("access") }, AuthResponse{}},
+	}
+
+	for _, tc := range calls {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewClient()
+			c.BaseURL = server.URL
+			c.InviteCode = "invite"
+			c.SetTokens("access", "refresh")
+			mu.Lock()
+			last = nil
+			mu.Unlock()
+
+			if err := tc.call(c); err != nil {
+				t.Fatal(err)
+			}
+			mu.Lock()
+			rec := last
+			mu.Unlock()
+			if rec == nil {
+				t.Fatal("no request sent")
+			}
+
+			op, ok := doc.operation(rec.method, rec.path)
+			if !ok {
+				t.Fatalf("%s %s is not in the OpenAPI document", rec.method, rec.path)
+			}
+			if len(rec.body) > 0 {
+				if op.RequestBody == nil {
+					t.Fatalf("%s %s sends a body the API doesn't document", rec.method, rec.path)
+				}
+				schemas := doc.resolve(op.RequestBody.Content["application/json"].Schema)
+				for key := range rec.body {
+					found := false
+					for _, s := range schemas {
+						_, found = s.Properties[key]
+						if found {
+							break
+						}
+					}
+					if !found {
+						t.Errorf("request field %q is not documented", key)
+					}
+				}
+			}
+
+			if tc.reply == nil {
+				return
+			}
+			var replies []*specSchema
+			for code, r := range op.Responses {
+				if strings.HasPrefix(code, "2") {
+					if content, ok := r.Content["application/json"]; ok {
+						replies = append(replies, content.Schema)
+					}
+				}
+			}
+			for _, p := range doc.checkType(reflect.TypeOf(tc.reply).Name(), reflect.TypeOf(tc.reply), replies) {
+				t.Error(p)
+			}
+		})
+	}
+}
+
+// TestAPIErrorMatchesOpenAPI checks APIError against the error envelope and
+// that every code the client reacts to is one the server can send.
+func TestAPIErrorMatchesOpenAPI(t *testing.T) {
+	doc := loadSpec(t)
+	schema := doc.Components.Schemas["Error"]
+	if schema == nil {
+		t.Fatal("Error schema is not documented")
+	}
+	for _, p := range doc.checkType("APIError", reflect.TypeOf(APIError{}), []*specSchema{schema}) {
+		t.Error(p)
+	}
+
+	codes := schema.Properties["code"]
+	if codes == nil {
+		t.Fatal("Error.code is not documented")
+	}
+	for _, code := range []string{
+		CodeTokenExpired, CodeInvalidToken, CodeSessionRevoked, CodeUnauthenticated,
+		CodeInvalidRefreshToken, CodeRefreshTokenReused, CodeRateLimited, CodeAccountLocked,
+		CodeMessageThrottled,
+	} {
+		if !slices.Contains(codes.Enum, code) {
+			t.Errorf("error code %q is not documented", code)
+		}
+	}
+}
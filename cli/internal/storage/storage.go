@@ -14,10 +14,11 @@ var ErrNoCredentials = errors.New("windgo: no stored credentials")
 
 // Credentials is what we persist between sessions.
 type Credentials struct {
-	Token    string `json:"token"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Provider string `json:"provider"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Provider     string `json:"provider"`
 }
 
 func configDir() (string, error) {
@@ -74,6 +75,18 @@ func Load() (*Credentials, error) {
 	return &creds, nil
 }
 
+// UpdateTokens replaces the stored token pair after a refresh, keeping the
+// rest of the saved profile.
+func UpdateTokens(token, refreshToken string) error {
+	creds, err := Load()
+	if err != nil {
+		return err
+	}
+	creds.Token = token
+	creds.RefreshToken = refreshToken
+	return Save(*creds)
+}
+
 // Clear removes stored credentials.
 func Clear() error {
 	dir, err := configDir()
@@ -3,6 +3,7 @@ package ui
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"runtime"
@@ -24,11 +25,12 @@ const (
 	stateLoading viewState = iota
 	stateLoginMenu
 	stateEmailLogin
-	stateDeviceSetup
+	stateTwoFactor
 	stateDeviceWaiting
 	stateMainMenu
 	stateChatLobby
 	stateConversation
+	stateSessions
 )
 
 type lobbyView int
@@ -91,6 +93,7 @@ var loginOptions = []string{
 var mainMenuOptions = []string{
 	"Chat Lobby",
 	"My Profile",
+	"Sessions",
 	"Settings",
 	"Logout",
 }
@@ -104,7 +107,6 @@ type Model struct {
 	focusIndex int
 	err        error
 	status     string
-	token      string
 	user       *api.User
 	creds      *storage.Credentials
 	submitting bool
@@ -112,8 +114,20 @@ type Model struct {
 	emailInput    textinput.Model
 	passwordInput textinput.Model
 
+	// Two-factor login step
+	challengeToken string
+	codeInput      textinput.Model
+
 	deviceInfo *api.DeviceStartResponse
 
+	// Device flow polling schedule. deviceFlowID ties ticks to the flow that
+	// scheduled them so a cancelled flow's ticks are ignored.
+	deviceFlowID    int
+	deviceInterval  time.Duration
+	deviceNextPoll  time.Time
+	deviceExpiresAt time.Time
+	devicePolling   bool
+
 	// Chat lobby data
 	rooms         []api.Room
 	filteredRooms []api.Room
@@ -135,6 +149,8 @@ type Model struct {
 	currentDMUser    *api.User
 	messages         []api.Message
 	messageInput     textinput.Model
+	// sendCooldownUntil is set when the server throttles sending (429)
+	sendCooldownUntil time.Time
 	messageViewport  viewport.Model
 	lastMessageID    uint
 	pollingActive    bool
@@ -147,6 +163,10 @@ type Model struct {
 	// User status polling
 	userPollingActive bool      // Whether user status polling is active
 	lastUserPollTime  time.Time // Last time we polled for user status
+
+	// Sessions screen
+	sessions     []api.Session
+	sessionIndex int
 }
 
 // openBrowser opens the specified URL in the user's default browser
@@ -182,6 +202,11 @@ func NewModel(client *api.Client) Model {
 	password.EchoCharacter = '•'
 	password.CharLimit = 256
 
+	code := textinput.New()
+	code.Placeholder = "123456 or recovery code"
+	code.Prompt = "Code> "
+	code.CharLimit = 32
+
 	search := textinput.New()
 	search.Placeholder = "Search..."
 	search.CharLimit = 50
@@ -192,11 +217,17 @@ func NewModel(client *api.Client) Model {
 	messageInput.CharLimit = 1000
 	messageInput.Width = 80
 
+	// Persist renewed tokens so the next launch picks up the latest pair
+	client.OnTokenRefresh(func(token, refreshToken string) {
+		_ = storage.UpdateTokens(token, refreshToken)
+	})
+
 	return Model{
 		client:        client,
 		state:         stateLoading,
 		emailInput:    email,
 		passwordInput: password,
+		codeInput:     code,
 		searchInput:   search,
 		messageInput:  messageInput,
 		currentView:   lobbyViewRooms,
@@ -220,6 +251,10 @@ type authSuccessMsg struct {
 	resp *api.AuthResponse
 }
 
+type twoFactorRequiredMsg struct {
+	challengeToken string
+}
+
 type credsSavedMsg struct {
 	err error
 }
@@ -232,6 +267,15 @@ type deviceStartMsg struct {
 	resp *api.DeviceStartResponse
 }
 
+type devicePollMsg struct {
+	resp *api.DevicePollResponse
+	err  error
+}
+
+type deviceTickMsg struct {
+	flowID int
+}
+
 type roomsLoadedMsg struct {
 	rooms []api.Room
 	err   error
@@ -252,12 +296,25 @@ type messageSentMsg struct {
 	err     error
 }
 
+// sendCooldownTickMsg refreshes the "can send again in" countdown.
+type sendCooldownTickMsg struct{}
+
 type moreMessagesLoadedMsg struct {
 	messages []api.Message
 	page     int
 	err      error
 }
 
+type sessionsLoadedMsg struct {
+	sessions []api.Session
+	err      error
+}
+
+type sessionRevokedMsg struct {
+	status string
+	err    error
+}
+
 type pollTickMsg time.Time
 
 type userPollTickMsg time.Time
@@ -279,9 +336,9 @@ func loadStoredCredentials() tea.Cmd {
 	}
 }
 
-func verifyTokenCmd(client *api.Client, token string) tea.Cmd {
+func verifyTokenCmd(client *api.Client) tea.Cmd {
 	return func() tea.Msg {
-		user, err := client.Profile(token)
+		user, err := client.Profile()
 		if err != nil {
 			return profileLoadedMsg{err: err}
 		}
@@ -295,6 +352,19 @@ func loginCmd(client *api.Client, email, password string) tea.Cmd {
 		if err != nil {
 			return errMsg{err: err}
 		}
+		if resp.TwoFactorRequired {
+			return twoFactorRequiredMsg{challengeToken: resp.ChallengeToken}
+		}
+		return authSuccessMsg{resp: &resp.AuthResponse}
+	}
+}
+
+func verifyTwoFactorCmd(client *api.Client, challengeToken, code string) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.VerifyTwoFactor(challengeToken, code)
+		if err != nil {
+			return errMsg{err: err}
+		}
 		return authSuccessMsg{resp: resp}
 	}
 }
@@ -311,29 +381,49 @@ func startDeviceFlowCmd(client *api.Client) tea.Cmd {
 
 func pollDeviceCmd(client *api.Client, deviceCode string) tea.Cmd {
 	return func() tea.Msg {
-		resp, err := client.PollDevice(deviceCode, 120)
-		if err != nil {
-			return errMsg{err: err}
-		}
-		return authSuccessMsg{resp: resp}
+		resp, err := client.PollDevice(deviceCode)
+		return devicePollMsg{resp: resp, err: err}
 	}
 }
 
+// sendCooldownTickCmd updates the send cooldown countdown once per second.
+func sendCooldownTickCmd() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return sendCooldownTickMsg{}
+	})
+}
+
+// deviceTickCmd drives the device flow countdown once per second.
+func deviceTickCmd(flowID int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return deviceTickMsg{flowID: flowID}
+	})
+}
+
 func saveCredentialsCmd(resp *api.AuthResponse) tea.Cmd {
 	creds := storage.Credentials{
-		Token:    resp.Token,
-		Username: resp.User.Username,
-		Email:    resp.User.Email,
-		Provider: resp.User.Provider,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		Username:     resp.User.Username,
+		Email:        resp.User.Email,
+		Provider:     resp.User.Provider,
 	}
 	return func() tea.Msg {
 		return credsSavedMsg{err: storage.Save(creds)}
 	}
 }
 
-func loadRoomsCmd(client *api.Client, token string) tea.Cmd {
+func logoutCmd(client *api.Client) tea.Cmd {
 	return func() tea.Msg {
-		rooms, err := client.GetRooms(token)
+		// Best effort: local credentials are already cleared
+		_ = client.Logout()
+		return nil
+	}
+}
+
+func loadRoomsCmd(client *api.Client) tea.Cmd {
+	return func() tea.Msg {
+		rooms, err := client.GetRooms()
 		if err != nil {
 			return roomsLoadedMsg{err: err}
 		}
@@ -341,9 +431,9 @@ func loadRoomsCmd(client *api.Client, token string) tea.Cmd {
 	}
 }
 
-func loadUsersCmd(client *api.Client, token string) tea.Cmd {
+func loadUsersCmd(client *api.Client) tea.Cmd {
 	return func() tea.Msg {
-		users, err := client.GetUsers(token, "")
+		users, err := client.GetUsers("")
 		if err != nil {
 			return usersLoadedMsg{err: err}
 		}
@@ -351,9 +441,9 @@ func loadUsersCmd(client *api.Client, token string) tea.Cmd {
 	}
 }
 
-func loadMessagesCmd(client *api.Client, token string, roomID uint) tea.Cmd {
+func loadMessagesCmd(client *api.Client, roomID uint) tea.Cmd {
 	return func() tea.Msg {
-		messages, err := client.GetMessages(token, roomID, 1, 50)
+		messages, err := client.GetMessages(roomID, 1, 50)
 		if err != nil {
 			return messagesLoadedMsg{err: err}
 		}
@@ -361,9 +451,9 @@ func loadMessagesCmd(client *api.Client, token string, roomID uint) tea.Cmd {
 	}
 }
 
-func loadMoreMessagesCmd(client *api.Client, token string, roomID uint, page int) tea.Cmd {
+func loadMoreMessagesCmd(client *api.Client, roomID uint, page int) tea.Cmd {
 	return func() tea.Msg {
-		messages, err := client.GetMessages(token, roomID, page, 50)
+		messages, err := client.GetMessages(roomID, page, 50)
 		if err != nil {
 			return moreMessagesLoadedMsg{page: page, err: err}
 		}
@@ -371,9 +461,9 @@ func loadMoreMessagesCmd(client *api.Client, token string, roomID uint, page int
 	}
 }
 
-func sendMessageCmd(client *api.Client, token string, roomID uint, content string) tea.Cmd {
+func sendMessageCmd(client *api.Client, roomID uint, content string) tea.Cmd {
 	return func() tea.Msg {
-		message, err := client.SendMessage(token, roomID, content)
+		message, err := client.SendMessage(roomID, content)
 		if err != nil {
 			return messageSentMsg{err: err}
 		}
@@ -381,6 +471,31 @@ func sendMessageCmd(client *api.Client, token string, roomID uint, content strin
 	}
 }
 
+func loadSessionsCmd(client *api.Client) tea.Cmd {
+	return func() tea.Msg {
+		sessions, err := client.ListSessions()
+		return sessionsLoadedMsg{sessions: sessions, err: err}
+	}
+}
+
+func revokeSessionCmd(client *api.Client, id string) tea.Cmd {
+	return func() tea.Msg {
+		if err := client.RevokeSession(id); err != nil {
+			return sessionRevokedMsg{err: err}
+		}
+		return sessionRevokedMsg{status: "Session revoked."}
+	}
+}
+
+func revokeOtherSessionsCmd(client *api.Client) tea.Cmd {
+	return func() tea.Msg {
+		if err := client.RevokeOtherSessions(); err != nil {
+			return sessionRevokedMsg{err: err}
+		}
+		return sessionRevokedMsg{status: "Signed out all other sessions."}
+	}
+}
+
 func pollMessagesCmd() tea.Cmd {
 	return tea.Tick(3*time.Second, func(t time.Time) tea.Msg {
 		return pollTickMsg(t)
@@ -433,6 +548,24 @@ func (m *Model) applyFilters() {
 	}
 }
 
+// sessionEnded forgets the stored credentials and returns to the login menu
+// after the server reports that the session can no longer be used.
+func (m Model) sessionEnded() (Model, tea.Cmd) {
+	m.client.SetTokens("", "")
+	_ = storage.Save(storage.Credentials{})
+	m.user = nil
+	m.rooms = nil
+	m.users = nil
+	m.currentRoom = nil
+	m.pollingActive = false
+	m.userPollingActive = false
+	m.err = nil
+	m.state = stateLoginMenu
+	m.menuIndex = 0
+	m.status = "Your session has ended. Please sign in again."
+	return m, nil
+}
+
 func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 	var (
 		keyMsg tea.KeyMsg
@@ -452,9 +585,9 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		if msg.creds != nil {
 			m.creds = msg.creds
-			m.token = msg.creds.Token
+			m.client.SetTokens(msg.creds.Token, msg.creds.RefreshToken)
 			m.status = "Found stored session, validating..."
-			return m, verifyTokenCmd(m.client, m.token)
+			return m, verifyTokenCmd(m.client)
 		}
 		m.state = stateLoginMenu
 		m.status = "Choose how you want to sign in."
@@ -463,7 +596,7 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 	case profileLoadedMsg:
 		if msg.err != nil {
 			m.status = "Stored credentials expired. Please sign in again."
-			m.token = ""
+			m.client.SetTokens("", "")
 			m.state = stateLoginMenu
 			return m, nil
 		}
@@ -480,27 +613,99 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		m.submitting = false
 		m.err = nil
 		m.deviceInfo = msg.resp
-		m.state = stateDeviceSetup
+		m.state = stateDeviceWaiting
+		// Poll on the schedule GitHub asked for until the code expires
+		m.deviceFlowID++
+		m.deviceInterval = time.Duration(msg.resp.Interval) * time.Second
+		if m.deviceInterval <= 0 {
+			m.deviceInterval = 5 * time.Second
+		}
+		m.deviceNextPoll = time.Now().Add(m.deviceInterval)
+		m.deviceExpiresAt = time.Now().Add(time.Duration(msg.resp.ExpiresIn) * time.Second)
+		m.devicePolling = false
 		// Try to automatically open the browser
 		if m.deviceInfo.VerificationURIComplete != "" {
 			if err := openBrowser(m.deviceInfo.VerificationURIComplete); err == nil {
-				m.status = "Browser opened! Authorize the app, then press Enter to continue."
+				m.status = "Browser opened! Authorize the app and you'll be signed in automatically."
 			} else {
-				m.status = "Enter the code in your browser, then press Enter to continue."
+				m.status = "Enter the code in your browser; you'll be signed in automatically."
 			}
 		} else {
 			if err := openBrowser(m.deviceInfo.VerificationURI); err == nil {
-				m.status = "Browser opened! Enter the code, then press Enter to continue."
+				m.status = "Browser opened! Enter the code and you'll be signed in automatically."
 			} else {
-				m.status = "Enter the code in your browser, then press Enter to continue."
+				m.status = "Enter the code in your browser; you'll be signed in automatically."
 			}
 		}
+		return m, deviceTickCmd(m.deviceFlowID)
+
+	case deviceTickMsg:
+		if msg.flowID != m.deviceFlowID || m.state != stateDeviceWaiting || m.deviceInfo == nil {
+			return m, nil
+		}
+		now := time.Now()
+		if now.After(m.deviceExpiresAt) {
+			m.deviceInfo = nil
+			m.state = stateLoginMenu
+			m.status = "Choose how you want to sign in."
+			m.err = errors.New("the GitHub code expired, please try again")
+			return m, nil
+		}
+		if !m.devicePolling && !now.Before(m.deviceNextPoll) {
+			m.devicePolling = true
+			return m, tea.Batch(pollDeviceCmd(m.client, m.deviceInfo.DeviceCode), deviceTickCmd(m.deviceFlowID))
+		}
+		return m, deviceTickCmd(m.deviceFlowID)
+
+	case devicePollMsg:
+		m.devicePolling = false
+		if m.state != stateDeviceWaiting || m.deviceInfo == nil {
+			return m, nil
+		}
+		if msg.err != nil {
+			// Client errors (expired, denied, unverified) end the flow; anything
+			// else is likely transient, so keep polling.
+			var apiErr *api.APIError
+			if errors.As(msg.err, &apiErr) && apiErr.StatusCode < 500 {
+				m.deviceInfo = nil
+				m.state = stateLoginMenu
+				m.status = "Choose how you want to sign in."
+				m.err = msg.err
+				return m, nil
+			}
+			m.err = msg.err
+			m.deviceNextPoll = time.Now().Add(m.deviceInterval)
+			return m, nil
+		}
+		if msg.resp.Pending() {
+			m.err = nil
+			if msg.resp.Interval > 0 {
+				m.deviceInterval = time.Duration(msg.resp.Interval) * time.Second
+			}
+			m.deviceNextPoll = time.Now().Add(m.deviceInterval)
+			return m, nil
+		}
+		m.deviceInfo = nil
+		auth := msg.resp.AuthResponse
+		return m, func() tea.Msg { return authSuccessMsg{resp: &auth} }
+
+	case twoFactorRequiredMsg:
+		m.submitting = false
+		m.err = nil
+		m.challengeToken = msg.challengeToken
+		m.state = stateTwoFactor
+		m.status = "Two-factor authentication is enabled for this account."
+		m.passwordInput.SetValue("")
+		m.passwordInput.Blur()
+		m.codeInput.SetValue("")
+		m.codeInput.Focus()
 		return m, nil
 
 	case authSuccessMsg:
 		m.submitting = false
 		m.err = nil
-		m.token = msg.resp.Token
+		m.challengeToken = ""
+		m.codeInput.Blur()
 		m.user = &msg.resp.User
 		m.state = stateMainMenu
 		m.menuIndex = 0
@@ -518,9 +723,8 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 
 	case errMsg:
 		m.submitting = false
-		if m.deviceInfo != nil {
-			m.state = stateDeviceSetup
-		} else if m.state == stateDeviceWaiting {
+		if m.state == stateDeviceWaiting {
+			m.deviceInfo = nil
 			m.state = stateLoginMenu
 		}
 		m.err = msg.err
@@ -530,6 +734,9 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case roomsLoadedMsg:
+		if api.NeedsLogin(msg.err) {
+			return m.sessionEnded()
+		}
 		if msg.err != nil {
 			m.err = msg.err
 			m.status = "Failed to load chat rooms"
@@ -541,7 +748,7 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		m.roomIndex = 0
 		m.state = stateChatLobby
 		m.status = "Loading users..."
-		return m, loadUsersCmd(m.client, m.token)
+		return m, loadUsersCmd(m.client)
 
 	case usersLoadedMsg:
 		if msg.err != nil {
@@ -583,6 +790,9 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case messagesLoadedMsg:
+		if api.NeedsLogin(msg.err) {
+			return m.sessionEnded()
+		}
 		if msg.err != nil {
 			m.err = msg.err
 			m.status = "Failed to load messages"
@@ -601,9 +811,19 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 
 	case messageSentMsg:
 		if msg.err != nil {
+			if api.NeedsLogin(msg.err) {
+				return m.sessionEnded()
+			}
+			var apiErr *api.APIError
+			if errors.As(msg.err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+				m.sendCooldownUntil = time.Now().Add(max(apiErr.RetryAfter, time.Second))
+				m.status = cooldownStatus(apiErr.Message, m.sendCooldownUntil)
+				return m, sendCooldownTickCmd()
+			}
 			m.status = errorStyle.Render(fmt.Sprintf("Failed to send message: %v", msg.err))
 			return m, nil
 		}
+		m.sendCooldownUntil = time.Time{}
 		// Clear input on success
 		m.messageInput.SetValue("")
 		m.status = ""
@@ -622,6 +842,20 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case sendCooldownTickMsg:
+		if m.sendCooldownUntil.IsZero() {
+			return m, nil
+		}
+		if secondsUntil(m.sendCooldownUntil) == 0 {
+			m.sendCooldownUntil = time.Time{}
+			m.status = ""
+			return m, nil
+		}
+		if m.state == stateConversation {
+			m.status = cooldownStatus("", m.sendCooldownUntil)
+		}
+		return m, sendCooldownTickCmd()
+
 	case moreMessagesLoadedMsg:
 		m.loadingMore = false
 		if msg.err != nil {
@@ -655,6 +889,35 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		m.status = helpStyle.Render(fmt.Sprintf("Loaded %d older messages (page %d)", len(msg.messages), msg.page))
 		return m, nil
 
+	case sessionsLoadedMsg:
+		if api.NeedsLogin(msg.err) {
+			return m.sessionEnded()
+		}
+		if msg.err != nil {
+			m.err = msg.err
+			m.status = "Failed to load sessions"
+			return m, nil
+		}
+		m.err = nil
+		m.sessions = msg.sessions
+		if m.sessionIndex >= len(m.sessions) {
+			m.sessionIndex = 0
+		}
+		if m.status == "Loading sessions..." {
+			m.status = fmt.Sprintf("%d active sessions.", len(m.sessions))
+		}
+		return m, nil
+
+	case sessionRevokedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			m.status = ""
+			return m, nil
+		}
+		m.err = nil
+		m.status = msg.status
+		return m, loadSessionsCmd(m.client)
+
 	case pollTickMsg:
 		// Only poll if we're in conversation state and polling is active
 		if m.state == stateConversation && m.pollingActive && m.currentRoom != nil {
@@ -662,7 +925,7 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 			if time.Since(m.lastPollTime) >= 2*time.Second {
 				m.lastPollTime = time.Now()
 				return m, tea.Batch(
-					loadMessagesCmd(m.client, m.token, m.currentRoom.ID),
+					loadMessagesCmd(m.client, m.currentRoom.ID),
 					pollMessagesCmd(),
 				)
 			}
@@ -678,7 +941,7 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 			if time.Since(m.lastUserPollTime) >= 25*time.Second {
 				m.lastUserPollTime = time.Now()
 				return m, tea.Batch(
-					loadUsersCmd(m.client, m.token),
+					loadUsersCmd(m.client),
 					pollUsersCmd(),
 				)
 			}
@@ -725,6 +988,17 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 		}
+		if m.state == stateTwoFactor {
+			switch keyMsg.String() {
+			case "enter", "esc":
+			default:
+				var cmd tea.Cmd
+				m.codeInput, cmd = m.codeInput.Update(message)
+				if cmd != nil {
+					cmds = append(cmds, cmd)
+				}
+			}
+		}
 		// Handle search input in chat lobby
 		if m.state == stateChatLobby && m.searchActive {
 			skipSearch := false
@@ -773,6 +1047,10 @@ func (m Model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		m.passwordInput, cmd = m.passwordInput.Update(message)
 		cmds = append(cmds, cmd)
 		return m, tea.Batch(cmds...)
+	case stateTwoFactor:
+		var cmd tea.Cmd
+		m.codeInput, cmd = m.codeInput.Update(message)
+		return m, cmd
 	}
 
 	return m, nil
@@ -859,27 +1137,38 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			return m, loginCmd(m.client, email, password)
 		}
 
-	case stateDeviceSetup:
+	case stateTwoFactor:
 		switch msg.String() {
-		case "enter", "p":
-			if m.deviceInfo == nil || m.submitting {
+		case "esc":
+			m.state = stateEmailLogin
+			m.status = ""
+			m.err = nil
+			m.submitting = false
+			m.challengeToken = ""
+			m.codeInput.Blur()
+			m.focusIndex = 1
+			m.passwordInput.Focus()
+			return m, nil
+		case "enter":
+			if m.submitting {
+				return m, nil
+			}
+			code := strings.TrimSpace(m.codeInput.Value())
+			if code == "" {
+				m.err = errors.New("code is required")
 				return m, nil
 			}
 			m.submitting = true
-			m.status = "Waiting for GitHub authorization..."
-			m.state = stateDeviceWaiting
-			return m, pollDeviceCmd(m.client, m.deviceInfo.DeviceCode)
-		case "esc":
-			m.deviceInfo = nil
-			m.state = stateLoginMenu
-			m.status = "Choose how you want to sign in."
 			m.err = nil
+			m.status = "Verifying code..."
+			return m, verifyTwoFactorCmd(m.client, m.challengeToken, code)
 		}
 
 	case stateDeviceWaiting:
 		switch msg.String() {
 		case "esc":
 			m.submitting = false
+			m.deviceInfo = nil
 			m.state = stateLoginMenu
 			m.status = "Choose how you want to sign in."
 			m.err = nil
@@ -901,24 +1190,30 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				m.status = "Loading chat rooms..."
 				m.state = stateChatLobby
 				return m, tea.Batch(
-					loadRoomsCmd(m.client, m.token),
-					loadUsersCmd(m.client, m.token),
+					loadRoomsCmd(m.client),
+					loadUsersCmd(m.client),
 				)
 			case 1: // My Profile
 				m.status = "Profile view coming soon..."
-			case 2: // Settings
+			case 2: // Sessions
+				m.state = stateSessions
+				m.sessionIndex = 0
+				m.err = nil
+				m.status = "Loading sessions..."
+				return m, loadSessionsCmd(m.client)
+			case 3: // Settings
 				m.status = "Settings coming soon..."
-			case 3: // Logout
-				m.token = ""
+			case 4: // Logout
 				m.user = nil
 				m.rooms = nil
 				m.users = nil
 				m.state = stateLoginMenu
 				m.menuIndex = 0
 				m.status = "Logged out successfully. Choose how you want to sign in."
-				// Stop polling and clear stored credentials
+				// Stop polling, clear stored credentials and revoke the session
 				m.userPollingActive = false
 				_ = storage.Save(storage.Credentials{})
+				return m, logoutCmd(m.client)
 			}
 		case "ctrl+c", "q":
 			return m, tea.Quit
@@ -980,7 +1275,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 					m.pollingActive = true
 					m.lastPollTime = time.Now()
 					return m, tea.Batch(
-						loadMessagesCmd(m.client, m.token, selectedRoom.ID),
+						loadMessagesCmd(m.client, selectedRoom.ID),
 						pollMessagesCmd(),
 					)
 				} else if m.currentView == lobbyViewPeople && len(m.filteredUsers) > 0 {
@@ -1001,6 +1296,42 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			}
 		}
 
+	case stateSessions:
+		switch msg.String() {
+		case "up", "k":
+			if m.sessionIndex > 0 {
+				m.sessionIndex--
+			}
+		case "down", "j":
+			if m.sessionIndex < len(m.sessions)-1 {
+				m.sessionIndex++
+			}
+		case "d", "x", "delete":
+			if len(m.sessions) == 0 {
+				return m, nil
+			}
+			selected := m.sessions[m.sessionIndex]
+			if selected.Current {
+				m.status = "That's this session. Use Logout from the main menu instead."
+				return m, nil
+			}
+			m.status = "Revoking session..."
+			return m, revokeSessionCmd(m.client, selected.ID)
+		case "a":
+			m.status = "Signing out other sessions..."
+			return m, revokeOtherSessionsCmd(m.client)
+		case "r":
+			m.status = "Loading sessions..."
+			return m, loadSessionsCmd(m.client)
+		case "m", "esc":
+			m.state = stateMainMenu
+			m.menuIndex = 0
+			m.err = nil
+			m.status = ""
+		case "q":
+			return m, tea.Quit
+		}
+
 	case stateConversation:
 		switch msg.String() {
 		case "esc":
@@ -1020,8 +1351,10 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 				// Check for commands
 				if strings.HasPrefix(content, "/") {
 					m.handleCommand(content)
+				} else if secondsUntil(m.sendCooldownUntil) > 0 {
+					m.status = cooldownStatus("", m.sendCooldownUntil)
 				} else {
-					return m, sendMessageCmd(m.client, m.token, m.currentRoom.ID, content)
+					return m, sendMessageCmd(m.client, m.currentRoom.ID, content)
 				}
 			}
 		case "up", "k":
@@ -1030,7 +1363,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			if m.messageViewport.AtTop() && !m.loadingMore && m.hasMoreMessages && m.currentRoom != nil {
 				m.loadingMore = true
 				m.status = helpStyle.Render("Loading older messages...")
-				return m, loadMoreMessagesCmd(m.client, m.token, m.currentRoom.ID, m.currentPage+1)
+				return m, loadMoreMessagesCmd(m.client, m.currentRoom.ID, m.currentPage+1)
 			}
 		case "down", "j":
 			m.messageViewport.LineDown(1)
@@ -1040,7 +1373,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (Model, tea.Cmd) {
 			if m.messageViewport.AtTop() && !m.loadingMore && m.hasMoreMessages && m.currentRoom != nil {
 				m.loadingMore = true
 				m.status = helpStyle.Render("Loading older messages...")
-				return m, loadMoreMessagesCmd(m.client, m.token, m.currentRoom.ID, m.currentPage+1)
+				return m, loadMoreMessagesCmd(m.client, m.currentRoom.ID, m.currentPage+1)
 			}
 		case "pgdown":
 			m.messageViewport.ViewDown()
@@ -1150,7 +1483,18 @@ func (m Model) View() string {
 		}
 		b.WriteString("Tab to switch fields, Enter to submit, Esc to go back.")
 
-	case stateDeviceSetup:
+	case stateTwoFactor:
+		b.WriteString("Two-factor authentication\n\n")
+		b.WriteString("Enter the 6-digit code from your authenticator app,\n")
+		b.WriteString("or one of your recovery codes (xxxxx-xxxxx).\n\n")
+		b.WriteString(m.codeInput.View())
+		b.WriteString("\n\n")
+		if m.submitting {
+			b.WriteString("Verifying...\n")
+		}
+		b.WriteString("Enter to submit, Esc to go back.")
+
+	case stateDeviceWaiting:
 		if m.deviceInfo == nil {
 			b.WriteString("Preparing GitHub device flow...")
 			break
@@ -1161,10 +1505,14 @@ func (m Model) View() string {
 		if m.deviceInfo.VerificationURIComplete != "" {
 			b.WriteString(fmt.Sprintf("Or open: %s\n\n", m.deviceInfo.VerificationURIComplete))
 		}
-		b.WriteString("Press Enter after authorizing to continue, or Esc to cancel.")
-
-	case stateDeviceWaiting:
-		b.WriteString("Waiting for GitHub authorization...\nPress Esc to cancel.")
+		if m.devicePolling {
+			b.WriteString("Waiting for authorization... checking now")
+		} else {
+			b.WriteString(fmt.Sprintf("Waiting for authorization... checking again in %ds", secondsUntil(m.deviceNextPoll)))
+		}
+		remaining := secondsUntil(m.deviceExpiresAt)
+		b.WriteString(fmt.Sprintf(" (code expires in %d:%02d)\n", remaining/60, remaining%60))
+		b.WriteString("Press Esc to cancel.")
 
 	case stateMainMenu:
 		b.WriteString(titleStyle.Render("WindGo Chat"))
@@ -1341,6 +1689,35 @@ func (m Model) View() string {
 		b.WriteString("\n")
 		b.WriteString(helpStyle.Render("Tab: switch view | ↑/↓: navigate | Enter: select | /: search | m/Esc: menu | q: quit"))
 
+	case stateSessions:
+		b.WriteString(titleStyle.Render("Active Sessions"))
+		b.WriteString(" ")
+		b.WriteString(statusStyle.Render("- " + m.user.Username))
+		b.WriteString("\n\n")
+
+		if len(m.sessions) == 0 {
+			b.WriteString("No active sessions.\n")
+		}
+		for i, session := range m.sessions {
+			line := session.DeviceName
+			if session.Current {
+				line += " " + onlineStyle.Render("(this device)")
+			}
+			details := fmt.Sprintf("%s · last used %s · signed in %s",
+				session.IPAddress,
+				session.LastUsedAt.Local().Format("Jan 2 15:04"),
+				session.CreatedAt.Local().Format("Jan 2 15:04"))
+			if i == m.sessionIndex {
+				b.WriteString(selectedItem.Render("> " + line))
+			} else {
+				b.WriteString("  " + line)
+			}
+			b.WriteString("\n    " + helpStyle.Render(details) + "\n")
+		}
+
+		b.WriteString("\n")
+		b.WriteString(helpStyle.Render("↑/↓: navigate | d: revoke selected | a: revoke all others | r: refresh | m/Esc: menu | q: quit"))
+
 	case stateConversation:
 		if m.currentRoom != nil {
 			b.WriteString(titleStyle.Render("# " + m.currentRoom.Name))
@@ -1378,3 +1755,21 @@ func (m Model) View() string {
 
 	return menuStyle.Render(b.String())
 }
+
+// cooldownStatus renders the throttling notice shown above the message input.
+func cooldownStatus(reason string, until time.Time) string {
+	text := fmt.Sprintf("You can send again in %ds", secondsUntil(until))
+	if reason != "" {
+		text = reason + ". " + text
+	}
+	return errorStyle.Render(text)
+}
+
+// secondsUntil rounds the time remaining until t up to whole seconds, never below zero.
+func secondsUntil(t time.Time) int {
+	d := time.Until(t)
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}